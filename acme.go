@@ -0,0 +1,100 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Path prefix of ACME HTTP-01 challenges, RFC 8555.
+const ACMEChallengePrefix = "/.well-known/acme-challenge/"
+
+// In-memory store of ACME HTTP-01 key authorizations by token.
+type ACMETokens struct {
+	mutex  sync.RWMutex
+	tokens map[string]string
+	// Webroot directory of token files written by external clients such as certbot, empty is not used.
+	dir string
+}
+
+// Set key authorization of token.
+func (s *ACMETokens) Set(token, keyAuth string) {
+	s.mutex.Lock()
+	if s.tokens == nil {
+		s.tokens = make(map[string]string)
+	}
+	s.tokens[token] = keyAuth
+	s.mutex.Unlock()
+}
+
+// Delete token after the challenge is validated.
+func (s *ACMETokens) Delete(token string) {
+	s.mutex.Lock()
+	delete(s.tokens, token)
+	s.mutex.Unlock()
+}
+
+// Return key authorization of token, tokens in memory are looked up before files in dir.
+func (s *ACMETokens) Get(token string) (string, bool) {
+	s.mutex.RLock()
+	keyAuth, ok := s.tokens[token]
+	s.mutex.RUnlock()
+	if ok || s.dir == "" {
+		return keyAuth, ok
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, token))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Can be use as HandlerFunc.
+// Token is the last param of route, response 404 if it is malformed or not found.
+func (s *ACMETokens) Handle(c *Context) bool {
+	var token string
+	if len(c.Param) > 0 {
+		token = c.Param[len(c.Param)-1]
+	}
+	keyAuth, ok := "", validACMEToken(token)
+	if ok {
+		keyAuth, ok = s.Get(token)
+	}
+	if !ok {
+		c.Res.WriteHeader(http.StatusNotFound)
+		return true
+	}
+	c.Res.Header().Set("Content-Type", "application/octet-stream")
+	c.Res.Header().Set("Cache-Control", "no-store")
+	c.Res.WriteHeader(http.StatusOK)
+	c.Res.Write([]byte(keyAuth))
+	return true
+}
+
+// Token is base64url without padding.
+func validACMEToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		b := token[i]
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-' || b == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Add GET route "/.well-known/acme-challenge/:", serve key authorizations of HTTP-01 challenges,
+// so external clients such as certbot can renew certificates through the router.
+// Files in dir are read on each request, it is the webroot of certbot, such as "/var/www/.well-known/acme-challenge".
+// If dir is empty, only tokens set to the returned ACMETokens are served.
+func (r *Router) AddACMEChallenge(dir string) (*ACMETokens, error) {
+	s := &ACMETokens{dir: dir}
+	_, err := r.AddGet(ACMEChallengePrefix+":", s.Handle)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"fmt"
+)
+
+// Add newPath of method as an alias of the route of existingPath, such as the old path of a renamed endpoint.
+// Alias shares handlers and metadata of the route, such as headers, stats and metrics of the route.
+// Params of both paths must be in the same order.
+// Remove the alias does not change the route, remove the route removes its aliases.
+func (r *Router) Alias(method, newPath, existingPath string) (*Route, error) {
+	table := r.table(method)
+	if table == nil {
+		return nil, fmt.Errorf("invalid http method '%s'", method)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.Frozen() {
+		return nil, ErrFrozen
+	}
+	target := table.Find(existingPath)
+	if target == nil || len(target.Handler) < 1 {
+		return nil, fmt.Errorf("route %s %s not found", method, existingPath)
+	}
+	if target.meta.alias != nil {
+		target = target.meta.alias
+	}
+	if route := table.Find(newPath); route != nil && len(route.Handler) > 0 {
+		return nil, fmt.Errorf("route %s %s exists", method, newPath)
+	}
+	route, err := table.Add(newPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(route.Params()) != len(target.Params()) {
+		discardRoute(table, newPath, route)
+		return nil, fmt.Errorf("alias %s has %d params, route %s has %d", newPath, len(route.Params()), existingPath, len(target.Params()))
+	}
+	route.Handler = []HandlerFunc{target.Handle}
+	route.meta.alias = target
+	return route, nil
+}
+
+// Return the route of alias r, or r if it is not an alias.
+func (r *Route) resolveAlias() *Route {
+	if r.meta.alias != nil {
+		return r.meta.alias
+	}
+	return r
+}
+
+// Remove aliases of route from table.
+func removeAliases(table Matcher, route *Route) {
+	var paths []string
+	table.Walk(func(r *Route) {
+		if r.meta.alias == route {
+			paths = append(paths, r.path)
+		}
+	})
+	for _, path := range paths {
+		table.Remove(path)
+	}
+}
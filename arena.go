@@ -0,0 +1,66 @@
+package router
+
+import (
+	"unsafe"
+)
+
+// Initial size of arena of Context.
+const arenaSize = 1024
+
+// Per-request byte arena of Context, reused by the next request after Context is put back to pool.
+// A new chunk is allocated if space is not enough, old chunk is left to GC,
+// so memory returned before is never overwritten in the same request.
+type arena struct {
+	buf []byte
+}
+
+// Reset for a new request.
+func (a *arena) reset() {
+	a.buf = a.buf[:0]
+}
+
+// Return n bytes from arena.
+func (a *arena) alloc(n int) []byte {
+	if cap(a.buf)-len(a.buf) < n {
+		size := 2 * cap(a.buf)
+		if size < arenaSize {
+			size = arenaSize
+		}
+		if size < n {
+			size = n
+		}
+		a.buf = make([]byte, 0, size)
+	}
+	i := len(a.buf)
+	a.buf = a.buf[:i+n]
+	return a.buf[i : i+n : i+n]
+}
+
+// Return a string of b which is copied into arena.
+func (a *arena) string(b ...string) string {
+	n := 0
+	for _, s := range b {
+		n += len(s)
+	}
+	if n == 0 {
+		return ""
+	}
+	p := a.alloc(n)[:0]
+	for _, s := range b {
+		p = append(p, s...)
+	}
+	return unsafe.String(&p[0], n)
+}
+
+// Return n bytes from arena of c, they are valid until the request ends.
+// Use it for small temporary data such as decoded params, normalized paths and response fragments.
+func (c *Context) Alloc(n int) []byte {
+	return c.arena.alloc(n)
+}
+
+// Return concatenation of s which is copied into arena of c, it is valid until the request ends.
+// Values from arena, including c.Param matched by a normalized path, must be cloned if they are kept after the request,
+// such as strings.Clone(c.Param[0]).
+func (c *Context) AllocString(s ...string) string {
+	return c.arena.string(s...)
+}
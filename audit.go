@@ -0,0 +1,191 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome of audit event.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// Structured audit event of a request.
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Who did it, subject of claims by default.
+	Actor string `json:"actor"`
+	// What did, set by route.Audit.
+	Action string `json:"action"`
+	// Route path with params filled, such as "/users/1".
+	Resource  string            `json:"resource"`
+	Method    string            `json:"method"`
+	Status    int               `json:"status"`
+	Outcome   string            `json:"outcome"`
+	ClientIP  string            `json:"client_ip"`
+	RequestID string            `json:"request_id,omitempty"`
+	Header    map[string]string `json:"header,omitempty"`
+	// Added by c.AuditField.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Receive audit events, such as a database or a message queue.
+type AuditSink interface {
+	Audit(*AuditEvent) error
+}
+
+// Write audit events as JSON lines.
+type AuditWriter struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+// Return an AuditWriter writes to w.
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *AuditWriter) Audit(e *AuditEvent) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.enc.Encode(e)
+}
+
+// Emit audit events of routes marked by route.Audit.
+type Auditor struct {
+	Sink AuditSink
+	// Request headers copied to event.
+	Header []string
+	// Header and field names whose values are replaced by "[REDACTED]", case insensitive.
+	Redact []string
+	// Return actor of request, default is subject of claims.
+	Actor func(*Context) string
+}
+
+// Return an Auditor emits events to sink.
+func NewAuditor(sink AuditSink) *Auditor {
+	a := new(Auditor)
+	a.Sink = sink
+	a.Redact = []string{"Authorization", "Cookie", "Proxy-Authorization", "password", "token", "secret"}
+	return a
+}
+
+func (a *Auditor) redacted(name string) bool {
+	for _, s := range a.Redact {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build and emit event, errors are logged.
+func (a *Auditor) emit(c *Context) {
+	e := new(AuditEvent)
+	e.Time = c.start
+	if a.Actor != nil {
+		e.Actor = a.Actor(c)
+	} else {
+		e.Actor = c.claims.Subject()
+	}
+	e.Action = c.route.meta.audit
+	e.Resource = c.route.resource(c.Param)
+	e.Method = c.Req.Method
+	e.Status = c.Status()
+	e.Outcome = AuditSuccess
+	if e.Status >= http.StatusBadRequest {
+		e.Outcome = AuditFailure
+	}
+	e.ClientIP = c.ClientIP()
+	e.RequestID = c.requestID
+	for _, k := range a.Header {
+		v := c.Req.Header.Get(k)
+		if v == "" {
+			continue
+		}
+		if e.Header == nil {
+			e.Header = make(map[string]string)
+		}
+		if a.redacted(k) {
+			v = redactedValue
+		}
+		e.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if len(c.auditFields) > 0 {
+		e.Fields = make(map[string]interface{})
+		for k, v := range c.auditFields {
+			if a.redacted(k) {
+				v = redactedValue
+			}
+			e.Fields[k] = v
+		}
+		if c.router.redactor != nil {
+			e.Fields = a.redactFields(c.router.redactor, e.Fields)
+		}
+	}
+	err := a.Sink.Audit(e)
+	if err != nil {
+		c.Logger().Error("audit failed", "action", e.Action, "error", err)
+	}
+}
+
+// Return fields masked by redactor, fields are converted to JSON values.
+func (a *Auditor) redactFields(redactor *Redactor, fields map[string]interface{}) map[string]interface{} {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return fields
+	}
+	var v map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return fields
+	}
+	redactor.RedactValue(v)
+	return v
+}
+
+// Set Auditor of router, nil to disable.
+func (r *Router) SetAuditor(a *Auditor) {
+	r.auditor = a
+}
+
+// Mark route audited, events are emitted with action after response.
+// Empty action disables it.
+func (r *Route) Audit(action string) {
+	r.meta.audit = action
+}
+
+// Return path with params filled.
+func (r *Route) resource(params []string) string {
+	var str strings.Builder
+	for _, s := range strings.Split(r.path, "/") {
+		if s == "" {
+			continue
+		}
+		str.WriteByte('/')
+		if (s == ":" || s == "*") && len(params) > 0 {
+			s = params[0]
+			params = params[1:]
+		}
+		str.WriteString(s)
+	}
+	if str.Len() < 1 {
+		return "/"
+	}
+	return str.String()
+}
+
+// Add a field to audit event of current request.
+func (c *Context) AuditField(key string, value interface{}) {
+	if c.auditFields == nil {
+		c.auditFields = make(map[string]interface{})
+	}
+	c.auditFields[key] = value
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"fmt"
+)
+
+// Route of AddRoutes.
+type RouteSpec struct {
+	Method  string
+	Path    string
+	Handler []HandlerFunc
+}
+
+// Added route of AddRoutes, used to roll back.
+type batchRoute struct {
+	table Matcher
+	path  string
+	route *Route
+	// Route existed before, such as "/a" of "/a/b", restore it instead of removing.
+	existed bool
+	handler []HandlerFunc
+	meta    routeMeta
+}
+
+// Try to add routes atomically, either all routes are added or none.
+// Unlike Add, a path which already has handlers is a conflict, so is a path added twice in specs.
+func (r *Router) AddRoutes(specs []RouteSpec) error {
+	for i := range specs {
+		if r.table(specs[i].Method) == nil {
+			return fmt.Errorf("invalid http method '%s'", specs[i].Method)
+		}
+		if _, err := splitRoute(specs[i].Path); err != nil {
+			return err
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.Frozen() {
+		return ErrFrozen
+	}
+	added := make([]batchRoute, 0, len(specs))
+	for i := range specs {
+		err := r.addBatchRoute(&specs[i], &added)
+		if err != nil {
+			rollbackRoutes(added)
+			return err
+		}
+	}
+	return nil
+}
+
+// Add spec, append the added route to added.
+func (r *Router) addBatchRoute(spec *RouteSpec, added *[]batchRoute) error {
+	table := r.table(spec.Method)
+	b := batchRoute{table: table, path: spec.Path}
+	if route := table.Find(spec.Path); route != nil {
+		b.existed = true
+		b.handler = route.Handler
+		b.meta = route.meta
+	}
+	route, err := table.Add(spec.Path)
+	if err != nil {
+		return err
+	}
+	b.route = route
+	// Only an existing route has handlers, restore its meta modified by Add.
+	if len(route.Handler) > 0 {
+		route.meta = b.meta
+		return fmt.Errorf("%s %s conflicts with route %s", spec.Method, spec.Path, route.Pattern())
+	}
+	route.Handler = spec.Handler
+	*added = append(*added, b)
+	return nil
+}
+
+// Undo added routes in reverse order.
+func rollbackRoutes(added []batchRoute) {
+	for i := len(added) - 1; i >= 0; i-- {
+		b := &added[i]
+		if b.existed {
+			b.route.Handler = b.handler
+			b.route.meta = b.meta
+			continue
+		}
+		discardRoute(b.table, b.path, b.route)
+	}
+}
+
+// Undo Add of path which did not exist before, sub routes added before are kept.
+// Remove can not be used, it removes sub paths, such as "/a/:id" of "/a".
+func discardRoute(table Matcher, path string, route *Route) {
+	if route.param == nil && !route.hasStatic() {
+		table.Remove(path)
+		return
+	}
+	route.Handler = nil
+	route.meta = routeMeta{}
+	root := routeTree(table, path)
+	if root == nil || route == &root.route || route.isParam() || route.param != nil {
+		return
+	}
+	// Route was split from its only static sub route by Add, join them.
+	static := -1
+	for i := 0; i < len(route.static); i++ {
+		if route.static[i] != nil {
+			if static >= 0 {
+				return
+			}
+			static = i
+		}
+	}
+	route.static[static].join()
+	root.buildExact()
+}
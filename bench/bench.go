@@ -0,0 +1,216 @@
+// Package bench generates route sets and benchmarks routers with them,
+// so performance of route tree can be compared between versions.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	router "github.com/qq51529210/http-router"
+)
+
+// Config of Generate.
+type Config struct {
+	// Levels of route path.
+	Depth int
+	// Sub routes of each level.
+	FanOut int
+	// Probability of a level to be a param route, 0 to 1.
+	ParamDensity float64
+	// Seed of random, same seed generates same routes.
+	Seed int64
+}
+
+// Routes and request urls which match them, URLs[i] matches Routes[i].
+type RouteSet struct {
+	Name   string
+	Routes []string
+	URLs   []string
+}
+
+// Return a route set of FanOut^Depth routes.
+// A level has at most one param route, which is shared by its static siblings.
+func Generate(cfg Config) *RouteSet {
+	s := &RouteSet{Name: fmt.Sprintf("depth%d_fanout%d_param%g_seed%d", cfg.Depth, cfg.FanOut, cfg.ParamDensity, cfg.Seed)}
+	if cfg.Depth < 1 || cfg.FanOut < 1 {
+		return s
+	}
+	random := rand.New(rand.NewSource(cfg.Seed))
+	var generate func(level int, route, url string)
+	generate = func(level int, route, url string) {
+		if level == cfg.Depth {
+			s.Routes = append(s.Routes, route)
+			s.URLs = append(s.URLs, url)
+			return
+		}
+		param := false
+		for i := 0; i < cfg.FanOut; i++ {
+			if !param && random.Float64() < cfg.ParamDensity {
+				param = true
+				generate(level+1, fmt.Sprintf("%s/:p%d", route, level), fmt.Sprintf("%s/v%d_%d", url, level, random.Intn(1000)))
+				continue
+			}
+			name := fmt.Sprintf("/s%d_%d", level, i)
+			generate(level+1, route+name, url+name)
+		}
+	}
+	generate(0, "", "")
+	return s
+}
+
+// Return route sets of the classic cases, each has one route of depth levels.
+// Static: /static/static0.../static_n
+// Param: /param/:.../:
+// StaticParam: /static_param/static0/:.../static_n/:
+// ParamStatic: /param_static/:/static0.../:/static_n
+func Classic(depth int) []*RouteSet {
+	sets := []*RouteSet{
+		{Name: "Static"},
+		{Name: "Param"},
+		{Name: "StaticParam"},
+		{Name: "ParamStatic"},
+	}
+	var route, url [4]strings.Builder
+	for i, s := range []string{"/static", "/param", "/static_param", "/param_static"} {
+		route[i].WriteString(s)
+		url[i].WriteString(s)
+	}
+	for i := 0; i < depth; i++ {
+		fmt.Fprintf(&route[0], "/static%d", i)
+		fmt.Fprintf(&url[0], "/static%d", i)
+		route[1].WriteString("/:")
+		fmt.Fprintf(&url[1], "/param%d", i)
+		fmt.Fprintf(&route[2], "/static%d/:", i)
+		fmt.Fprintf(&url[2], "/static%d/param%d", i, i)
+		fmt.Fprintf(&route[3], "/:/static%d", i)
+		fmt.Fprintf(&url[3], "/param%d/static%d", i, i)
+	}
+	for i, s := range sets {
+		s.Routes = []string{route[i].String()}
+		s.URLs = []string{url[i].String()}
+	}
+	return sets
+}
+
+// Return a router of GET routes in sets, handlers do nothing.
+func NewRouter(sets ...*RouteSet) (*router.Router, error) {
+	r := router.New()
+	for _, s := range sets {
+		for _, route := range s.Routes {
+			if r.Route(http.MethodGet, route) != nil {
+				continue
+			}
+			_, err := r.AddGet(route, func(c *router.Context) bool { return true })
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// Benchmark result of a route set.
+type Result struct {
+	Name        string `json:"name"`
+	N           int    `json:"n"`
+	NsPerOp     int64  `json:"nsPerOp"`
+	AllocsPerOp int64  `json:"allocsPerOp"`
+	BytesPerOp  int64  `json:"bytesPerOp"`
+}
+
+// Response writer discards all.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {
+}
+
+// Benchmark GET requests of urls in turn, by testing.Benchmark.
+func Run(name string, h http.Handler, urls []string) Result {
+	reqs := make([]*http.Request, len(urls))
+	for i, u := range urls {
+		reqs[i], _ = http.NewRequest(http.MethodGet, u, nil)
+	}
+	res := testing.Benchmark(func(b *testing.B) {
+		Loop(b, h, reqs)
+	})
+	return Result{
+		Name:        name,
+		N:           res.N,
+		NsPerOp:     res.NsPerOp(),
+		AllocsPerOp: res.AllocsPerOp(),
+		BytesPerOp:  res.AllocedBytesPerOp(),
+	}
+}
+
+// Serve reqs in turn b.N times, used by Run and Benchmark functions.
+func Loop(b *testing.B, h http.Handler, reqs []*http.Request) {
+	if len(reqs) < 1 {
+		return
+	}
+	w := &discardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(w, reqs[i%len(reqs)])
+	}
+}
+
+// Save results to file in JSON, which can be loaded as base of Compare.
+func Save(file string, results []Result) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Load results saved by Save.
+func Load(file string) ([]Result, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	err = json.Unmarshal(data, &results)
+	return results, err
+}
+
+// Write a table of current results compared with base results by name.
+// Return names of results which are slower than base more than threshold, such as 0.1 is 10%.
+func Compare(w io.Writer, base, current []Result, threshold float64) []string {
+	baseMap := make(map[string]Result)
+	for _, r := range base {
+		baseMap[r.Name] = r
+	}
+	var slower []string
+	fmt.Fprintf(w, "%-40s %12s %12s %8s %12s\n", "name", "base ns/op", "ns/op", "delta", "allocs/op")
+	for _, r := range current {
+		b, ok := baseMap[r.Name]
+		if !ok || b.NsPerOp == 0 {
+			fmt.Fprintf(w, "%-40s %12s %12d %8s %12d\n", r.Name, "-", r.NsPerOp, "-", r.AllocsPerOp)
+			continue
+		}
+		delta := float64(r.NsPerOp-b.NsPerOp) / float64(b.NsPerOp)
+		fmt.Fprintf(w, "%-40s %12d %12d %+7.1f%% %12d\n", r.Name, b.NsPerOp, r.NsPerOp, delta*100, r.AllocsPerOp)
+		if delta > threshold {
+			slower = append(slower, r.Name)
+		}
+	}
+	return slower
+}
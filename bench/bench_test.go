@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	router "github.com/qq51529210/http-router"
+)
+
+func testNewRouter(t testing.TB, sets ...*RouteSet) *router.Router {
+	r, err := NewRouter(sets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetNotfound(router.Notfound)
+	return r
+}
+
+func Test_Generate(t *testing.T) {
+	cfg := Config{Depth: 3, FanOut: 4, ParamDensity: 0.3, Seed: 1}
+	s1, s2 := Generate(cfg), Generate(cfg)
+	if len(s1.Routes) != 64 || len(s1.URLs) != 64 {
+		t.Fatal(len(s1.Routes), len(s1.URLs))
+	}
+	// Reproducible.
+	if strings.Join(s1.URLs, ",") != strings.Join(s2.URLs, ",") {
+		t.FailNow()
+	}
+	cfg.Seed = 2
+	if strings.Join(s1.URLs, ",") == strings.Join(Generate(cfg).URLs, ",") {
+		t.FailNow()
+	}
+	// All urls match.
+	r := testNewRouter(t, s1)
+	for _, u := range s1.URLs {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+		if w.Code != http.StatusOK {
+			t.Fatal(u, w.Code)
+		}
+	}
+}
+
+func Test_Classic(t *testing.T) {
+	sets := Classic(10)
+	r := testNewRouter(t, sets...)
+	for _, s := range sets {
+		for _, u := range s.URLs {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+			if w.Code != http.StatusOK {
+				t.Fatal(s.Name, u, w.Code)
+			}
+		}
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "base.json")
+	base := []Result{{Name: "a", NsPerOp: 100}, {Name: "b", NsPerOp: 100}}
+	err := Save(file, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err = Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	slower := Compare(&out, base, []Result{{Name: "a", NsPerOp: 105}, {Name: "b", NsPerOp: 150}, {Name: "c", NsPerOp: 1}}, 0.1)
+	if len(slower) != 1 || slower[0] != "b" {
+		t.Fatal(slower)
+	}
+	if !strings.Contains(out.String(), "+50.0%") {
+		t.Fatal(out.String())
+	}
+}
+
+func testBenchmark(b *testing.B, s *RouteSet) {
+	r := testNewRouter(b, s)
+	reqs := make([]*http.Request, len(s.URLs))
+	for i, u := range s.URLs {
+		reqs[i] = httptest.NewRequest(http.MethodGet, u, nil)
+	}
+	Loop(b, r, reqs)
+}
+
+func Benchmark_Match_My_Static(b *testing.B) {
+	testBenchmark(b, Classic(10)[0])
+}
+
+func Benchmark_Match_My_Param(b *testing.B) {
+	testBenchmark(b, Classic(10)[1])
+}
+
+func Benchmark_Match_My_StaticParam(b *testing.B) {
+	testBenchmark(b, Classic(10)[2])
+}
+
+func Benchmark_Match_My_ParamStatic(b *testing.B) {
+	testBenchmark(b, Classic(10)[3])
+}
+
+func Benchmark_Match_Generated(b *testing.B) {
+	testBenchmark(b, Generate(Config{Depth: 4, FanOut: 5, ParamDensity: 0.2, Seed: 1}))
+}
@@ -0,0 +1,399 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Return query values of request, parsed once per request.
+func (c *Context) query() url.Values {
+	if c.queryValues == nil {
+		c.queryValues = c.Req.URL.Query()
+	}
+	return c.queryValues
+}
+
+// Return first value of query name.
+func (c *Context) Query(name string) string {
+	v := c.query()[name]
+	if len(v) < 1 {
+		return ""
+	}
+	return v[0]
+}
+
+// Return all values of query name, "name[]" is also accepted.
+// Example: "?id=1&id=2" or "?id[]=1&id[]=2" -> ["1", "2"].
+func (c *Context) QueryArray(name string) []string {
+	return arrayValues(c.query(), name)
+}
+
+// Return key-value pairs of query name in "name[key]=value" form.
+// Example: "?filter[status]=active&filter[type]=a" -> {"status": "active", "type": "a"}.
+func (c *Context) QueryMap(name string) map[string]string {
+	return valuesMap(c.query(), name)
+}
+
+func arrayValues(values url.Values, name string) []string {
+	v := values[name]
+	return append(v[:len(v):len(v)], values[name+"[]"]...)
+}
+
+func valuesMap(values url.Values, name string) map[string]string {
+	var m map[string]string
+	prefix := name + "["
+	for k, v := range values {
+		if len(v) < 1 || !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, "]") || len(k) == len(prefix) {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k[len(prefix):len(k)-1]] = v[0]
+	}
+	return m
+}
+
+// Bind query values to struct fields by `query:"name"` tags, see BindHeader for options.
+// Field without tag uses its name. Slice fields accept repeated keys, map fields accept "name[key]" keys.
+func (c *Context) BindQuery(v interface{}) error {
+	return bindValues(v, "query", c.query(), nil)
+}
+
+// Bind path params to struct fields by `uri:"name"` tags, name is the name of param route such as "id" of "/users/:id".
+// Index such as `uri:"0"` is also accepted for unnamed param routes.
+func (c *Context) BindParams(v interface{}) error {
+	values := make(url.Values)
+	var names []string
+	if c.route != nil {
+		names = c.route.meta.params
+	}
+	for i, s := range c.Param {
+		// Param may be in arena, bound struct can be kept after the request.
+		s = strings.Clone(s)
+		values.Set(strconv.Itoa(i), s)
+		if i < len(names) && names[i] != "" {
+			values.Set(names[i], s)
+		}
+	}
+	return bindValues(v, "uri", values, nil)
+}
+
+// Bind request headers to struct fields by `header:"X-Tenant-ID"` tags.
+// Options: `header:"X-Tenant-ID,required"` returns error if header is missing,
+// `header:"Accept-Language,default=en"` uses the default value if header is missing.
+// Slice fields accept repeated headers, their default value is split by ",".
+func (c *Context) BindHeader(v interface{}) error {
+	return bindValues(v, "header", url.Values(c.Req.Header), http.CanonicalHeaderKey)
+}
+
+// Max memory of multipart form, the rest is stored in temporary files.
+var MaxFormMemory int64 = 32 << 20
+
+// Bind form values to struct fields by `form:"name"` tags, see BindHeader for options.
+// Form values include query values, multipart form is parsed too.
+// Pointer fields are set only if value exists, time.Time fields are parsed by `layout=` option,
+// fields of embedded structs are bound as well.
+func (c *Context) BindForm(v interface{}) error {
+	var err error
+	if strings.HasPrefix(c.Req.Header.Get("Content-Type"), "multipart/form-data") {
+		err = c.Req.ParseMultipartForm(MaxFormMemory)
+	} else {
+		err = c.Req.ParseForm()
+	}
+	if err != nil {
+		return fmt.Errorf("bind: %w", err)
+	}
+	return bindValues(v, "form", c.Req.Form, nil)
+}
+
+// Decode JSON body into v.
+func (c *Context) BindJSON(v interface{}) error {
+	err := json.NewDecoder(c.Req.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("bind: %w", err)
+	}
+	return nil
+}
+
+// Bind JSON body if Content-Type is JSON, then query values and path params, later ones override former ones.
+func (c *Context) Bind(v interface{}) error {
+	if c.Req.Body != nil && c.Req.Body != http.NoBody && isJSON(c.Req.Header.Get("Content-Type")) {
+		err := c.BindJSON(v)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.BindQuery(v)
+	if err != nil {
+		return err
+	}
+	return c.BindParams(v)
+}
+
+// Return true if media type of contentType is JSON.
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (t == "application/json" || strings.HasSuffix(t, "+json"))
+}
+
+// Error of a struct field in binding.
+type FieldError struct {
+	// Struct field name, such as "User.Name" for embedded struct.
+	Field string `json:"field"`
+	// Key in request, such as query name.
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// Errors of all invalid fields, returned by binders.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var str strings.Builder
+	str.WriteString("bind: ")
+	for i, f := range e.Fields {
+		if i > 0 {
+			str.WriteString("; ")
+		}
+		fmt.Fprintf(&str, "field %s: %s", f.Field, f.Message)
+	}
+	return str.String()
+}
+
+func (e *ValidationError) add(field, key, msg string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Key: key, Message: msg})
+}
+
+// Default layout of time.Time fields.
+const DefaultTimeLayout = time.RFC3339
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Bind values to struct fields pointed by v, by tag.
+// Tag is `tag:"name,required,layout=2006-01-02,default=value"`, options are optional.
+// If key is not nil, it is used to convert name to key of values.
+// Errors of all fields are returned in a *ValidationError.
+func bindValues(v interface{}, tag string, values url.Values, key func(string) string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T is not a pointer to struct", v)
+	}
+	var errs ValidationError
+	bindStruct(rv.Elem(), "", tag, values, key, &errs)
+	if len(errs.Fields) > 0 {
+		return &errs
+	}
+	return nil
+}
+
+func bindStruct(rv reflect.Value, prefix, tag string, values url.Values, key func(string) string, errs *ValidationError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		opt := parseBindTag(sf.Tag.Get(tag))
+		if opt.name == "-" {
+			continue
+		}
+		field := rv.Field(i)
+		// Embedded struct.
+		if sf.Anonymous && opt.name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if sf.Type.Kind() == reflect.Ptr {
+					if field.IsNil() {
+						if !field.CanSet() {
+							continue
+						}
+						field.Set(reflect.New(ft))
+					}
+					field = field.Elem()
+				}
+				bindStruct(field, prefix+sf.Name+".", tag, values, key, errs)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := opt.name
+		if name == "" {
+			name = sf.Name
+		}
+		if key != nil {
+			name = key(name)
+		}
+		fieldName := prefix + sf.Name
+		var err error
+		switch sf.Type.Kind() {
+		case reflect.Map:
+			m := valuesMap(values, name)
+			if len(m) < 1 && opt.required {
+				errs.add(fieldName, name, "required")
+				continue
+			}
+			err = setMap(field, m, opt.layout)
+		case reflect.Slice:
+			a := arrayValues(values, name)
+			if len(a) < 1 {
+				if opt.required {
+					errs.add(fieldName, name, "required")
+					continue
+				}
+				if opt.hasDefault {
+					a = strings.Split(opt.def, ",")
+				}
+			}
+			err = setSlice(field, a, opt.layout)
+		default:
+			vs := values[name]
+			if len(vs) < 1 {
+				if opt.required {
+					errs.add(fieldName, name, "required")
+					continue
+				}
+				if !opt.hasDefault {
+					continue
+				}
+				vs = []string{opt.def}
+			}
+			err = setValue(field, vs[0], opt.layout)
+		}
+		if err != nil {
+			errs.add(fieldName, name, err.Error())
+		}
+	}
+}
+
+// Options of bind tag.
+type bindTag struct {
+	name       string
+	required   bool
+	layout     string
+	def        string
+	hasDefault bool
+}
+
+// Parse `name,required,layout=value,default=value`, value of default is the rest of tag.
+func parseBindTag(tag string) bindTag {
+	var t bindTag
+	var opts string
+	t.name, opts, _ = strings.Cut(tag, ",")
+	for opts != "" {
+		if strings.HasPrefix(opts, "default=") {
+			t.def = opts[len("default="):]
+			t.hasDefault = true
+			break
+		}
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "required" {
+			t.required = true
+		} else if strings.HasPrefix(opt, "layout=") {
+			t.layout = opt[len("layout="):]
+		}
+	}
+	return t
+}
+
+// Set string to v by its kind, layout is used by time.Time.
+func setValue(v reflect.Value, s, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		e := reflect.New(v.Type().Elem())
+		err := setValue(e.Elem(), s, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+	if v.Type() == timeType {
+		if layout == "" {
+			layout = DefaultTimeLayout
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func setSlice(v reflect.Value, values []string, layout string) error {
+	if len(values) < 1 {
+		return nil
+	}
+	slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, s := range values {
+		err := setValue(slice.Index(i), s, layout)
+		if err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func setMap(v reflect.Value, values map[string]string, layout string) error {
+	if len(values) < 1 {
+		return nil
+	}
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	m := reflect.MakeMapWithSize(v.Type(), len(values))
+	for k, s := range values {
+		e := reflect.New(v.Type().Elem()).Elem()
+		err := setValue(e, s, layout)
+		if err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+	}
+	v.Set(m)
+	return nil
+}
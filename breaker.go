@@ -0,0 +1,186 @@
+package router
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metric names emitted by Breaker.
+const (
+	MetricBreakerState  = "breaker.state"
+	MetricBreakerChange = "breaker.state_change"
+)
+
+// State of Breaker.
+type BreakerState int
+
+const (
+	// Requests are allowed, failures are counted.
+	BreakerClosed BreakerState = iota
+	// Requests are rejected.
+	BreakerOpen
+	// A few trial requests are allowed, decide to close or open again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Circuit breaker, opens when failure rate reaches threshold in a window.
+type Breaker struct {
+	// Name used in metrics tag and OnStateChange.
+	Name string
+	// Open if failure rate >= FailureRate and requests >= MinRequests in Window.
+	FailureRate float64
+	MinRequests int64
+	Window      time.Duration
+	// Duration of open state, then becomes half-open.
+	OpenTimeout time.Duration
+	// Max trial requests in half-open state.
+	HalfOpenRequests int
+	// Called when state changes.
+	OnStateChange func(name string, from, to BreakerState)
+	// If it is not nil, emit state change events.
+	Metrics     MetricsSink
+	mutex       sync.Mutex
+	state       BreakerState
+	requests    int64
+	failures    int64
+	windowStart time.Time
+	openedAt    time.Time
+	trials      int
+}
+
+// Return a Breaker with default settings.
+func NewBreaker(name string) *Breaker {
+	b := new(Breaker)
+	b.Name = name
+	b.FailureRate = 0.5
+	b.MinRequests = 10
+	b.Window = 10 * time.Second
+	b.OpenTimeout = 5 * time.Second
+	b.HalfOpenRequests = 1
+	return b
+}
+
+// Return current state.
+func (b *Breaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.checkOpenTimeout(time.Now())
+	return b.state
+}
+
+// Return whether a request is allowed. If true, Done must be called after request.
+func (b *Breaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.checkOpenTimeout(now)
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trials >= b.HalfOpenRequests {
+			return false
+		}
+		b.trials++
+		return true
+	default:
+		if now.Sub(b.windowStart) > b.Window {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+		return true
+	}
+}
+
+// Report result of an allowed request.
+func (b *Breaker) Done(failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen)
+			return
+		}
+		b.setState(BreakerClosed)
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.MinRequests && float64(b.failures) >= float64(b.requests)*b.FailureRate {
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+// Open state timeout, becomes half-open.
+func (b *Breaker) checkOpenTimeout(now time.Time) {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.OpenTimeout {
+		b.setState(BreakerHalfOpen)
+	}
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	from := b.state
+	if from == state {
+		return
+	}
+	b.state = state
+	now := time.Now()
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerHalfOpen:
+		b.trials = 0
+	case BreakerClosed:
+		b.windowStart = now
+		b.requests = 0
+		b.failures = 0
+	}
+	if b.Metrics != nil {
+		b.Metrics.Count(MetricBreakerChange, 1, "breaker:"+b.Name, "from:"+from.String(), "to:"+state.String())
+		b.Metrics.Gauge(MetricBreakerState, float64(state), "breaker:"+b.Name)
+	}
+	if b.OnStateChange != nil {
+		b.OnStateChange(b.Name, from, state)
+	}
+}
+
+// Return a HandlerFunc that runs funcs if breaker allows, else response 503.
+// Response status code >= 500 or panic is counted as failure.
+func (b *Breaker) Wrap(funcs ...HandlerFunc) HandlerFunc {
+	return func(c *Context) bool {
+		if !b.Allow() {
+			c.Res.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		}
+		failed := true
+		defer func() {
+			b.Done(failed)
+		}()
+		for _, h := range funcs {
+			if !h(c) {
+				failed = c.Status() >= http.StatusInternalServerError
+				return false
+			}
+		}
+		failed = c.Status() >= http.StatusInternalServerError
+		return true
+	}
+}
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header of caller's timeout, value is a duration such as "1.5s", "200ms" or seconds "3".
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// Header of gRPC timeout, value is digits and a unit of H, M, S, m, u or n, such as "100m".
+const GRPCTimeoutHeader = "Grpc-Timeout"
+
+// Set deadline of request context by caller's budget in X-Request-Timeout or Grpc-Timeout header.
+// Downstream calls of Context.HTTPClient and Timeout middleware respect the deadline.
+type RequestBudget struct {
+	// Max budget, longer budget of caller is clamped, 0 is no limit.
+	Max time.Duration
+	// Used if header is missing or malformed, 0 is no deadline.
+	Default time.Duration
+}
+
+// Can be use as HandlerFunc.
+func (b *RequestBudget) Handle(c *Context) bool {
+	d, ok := parseRequestTimeout(c.Req.Header.Get(RequestTimeoutHeader))
+	if !ok {
+		d, ok = parseGRPCTimeout(c.Req.Header.Get(GRPCTimeoutHeader))
+	}
+	if !ok {
+		d = b.Default
+	}
+	if b.Max > 0 && (d <= 0 || d > b.Max) {
+		d = b.Max
+	}
+	if d > 0 {
+		c.SetTimeout(d)
+	}
+	return true
+}
+
+// Return a HandlerFunc set timeout of request, used as per-route timeout.
+// Caller's budget is kept if it is shorter.
+func Timeout(d time.Duration) HandlerFunc {
+	return func(c *Context) bool {
+		c.SetTimeout(d)
+		return true
+	}
+}
+
+// Set deadline of request context to now + d, unless it has an earlier deadline.
+// The context is canceled after after chain.
+func (c *Context) SetTimeout(d time.Duration) {
+	ctx := c.Req.Context()
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) <= d {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	c.cancels = append(c.cancels, cancel)
+	c.Req = c.Req.WithContext(ctx)
+}
+
+// Return remaining time of request deadline, false if request has no deadline.
+func (c *Context) Budget() (time.Duration, bool) {
+	dl, ok := c.Req.Context().Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(dl), true
+}
+
+// Cancel contexts of SetTimeout.
+func (c *Context) cancelTimeout() {
+	for _, cancel := range c.cancels {
+		cancel()
+	}
+	c.cancels = c.cancels[:0]
+}
+
+// Set remaining budget of outbound request to X-Request-Timeout header.
+func propagateBudget(req *http.Request) {
+	if req.Header.Get(RequestTimeoutHeader) != "" {
+		return
+	}
+	dl, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	ms := time.Until(dl).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	req.Header.Set(RequestTimeoutHeader, strconv.FormatInt(ms, 10)+"ms")
+}
+
+// Parse value of X-Request-Timeout, false if it is empty, malformed or not positive.
+func parseRequestTimeout(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		d := time.Duration(f * float64(time.Second))
+		return d, d > 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+// Parse value of Grpc-Timeout, false if it is empty, malformed or not positive.
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
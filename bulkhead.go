@@ -0,0 +1,92 @@
+package router
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// Metric names of pools, tagged by "pool:name".
+const (
+	MetricPoolRunning  = "http.pool.running"
+	MetricPoolWaiting  = "http.pool.waiting"
+	MetricPoolRejected = "http.pool.rejected"
+)
+
+// Named pool of routes, with independent concurrency limit.
+type pool struct {
+	name     string
+	limiter  *Limiter
+	rejected int64
+}
+
+// Statistics of a pool.
+type PoolStats struct {
+	Name     string
+	Running  int
+	Waiting  int
+	Rejected int64
+}
+
+// Set a named pool of routes with its own Limiter, see Route.SetPool.
+// So a slow route such as "/export" can not starve others, call it before serving.
+func (r *Router) SetPool(name string, limiter *Limiter) {
+	if r.pools == nil {
+		r.pools = make(map[string]*pool)
+	}
+	r.pools[name] = &pool{name: name, limiter: limiter}
+}
+
+// Return statistics of pools, sorted by name.
+func (r *Router) Pools() []PoolStats {
+	stats := make([]PoolStats, 0, len(r.pools))
+	for _, p := range r.pools {
+		running, waiting := p.limiter.Load()
+		stats = append(stats, PoolStats{
+			Name:     p.name,
+			Running:  running,
+			Waiting:  waiting,
+			Rejected: atomic.LoadInt64(&p.rejected),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Run handlers of route in the pool of name set by Router.SetPool, empty name means no pool.
+// Requests are rejected with 503 if the pool is full, see Limiter.
+func (r *Route) SetPool(name string) {
+	r.meta.pool = name
+}
+
+// Call handlers in the pool of route if it has one.
+func (r *Router) callPool(c *Context, route *Route, handlers []HandlerFunc) {
+	p := r.pools[route.meta.pool]
+	if p == nil {
+		r.callChain(c, handlers)
+		return
+	}
+	if !p.limiter.acquire(c) {
+		atomic.AddInt64(&p.rejected, 1)
+		if r.metrics != nil {
+			r.metrics.Count(MetricPoolRejected, 1, "pool:"+p.name)
+		}
+		p.limiter.reject(c)
+		return
+	}
+	r.emitPool(p)
+	defer func() {
+		p.limiter.release()
+		r.emitPool(p)
+	}()
+	r.callChain(c, handlers)
+}
+
+// Emit running and waiting gauges of pool.
+func (r *Router) emitPool(p *pool) {
+	if r.metrics == nil {
+		return
+	}
+	running, waiting := p.limiter.Load()
+	r.metrics.Gauge(MetricPoolRunning, float64(running), "pool:"+p.name)
+	r.metrics.Gauge(MetricPoolWaiting, float64(waiting), "pool:"+p.name)
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Return a HandlerFunc that calls h only if pred returns true, otherwise continues the chain.
+// Example: When(PathPrefix("/api/"), jwt.Handle)
+func When(pred func(*Context) bool, h HandlerFunc) HandlerFunc {
+	return func(c *Context) bool {
+		if !pred(c) {
+			return true
+		}
+		return h(c)
+	}
+}
+
+// Return a HandlerFunc that calls h only if pred returns false, otherwise continues the chain.
+// Example: Unless(PathPrefix("/public/"), jwt.Handle)
+func Unless(pred func(*Context) bool, h HandlerFunc) HandlerFunc {
+	return func(c *Context) bool {
+		if pred(c) {
+			return true
+		}
+		return h(c)
+	}
+}
+
+// Return a predicate, true if request path has any of prefixes.
+func PathPrefix(prefixes ...string) func(*Context) bool {
+	return func(c *Context) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(c.Req.URL.Path, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Return a predicate, true if request has header name.
+// If values are given, the header must be one of them.
+func HasHeader(name string, values ...string) func(*Context) bool {
+	return func(c *Context) bool {
+		v, ok := c.Req.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if len(values) < 1 {
+			return true
+		}
+		for _, s := range values {
+			if len(v) > 0 && v[0] == s {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Return a predicate, true if media type of request Content-Type is one of types.
+func ContentTypeIs(types ...string) func(*Context) bool {
+	return func(c *Context) bool {
+		t, _, err := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, s := range types {
+			if strings.EqualFold(t, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Return a HandlerFunc that calls funcs in order, it stops and returns false at the first one returns false.
+// It is used to build a reusable stack and attach it to many routes.
+// Example: auth := Chain(RateLimit, jwt.Handle); router.AddGet("/users", auth, listUsers)
+func Chain(funcs ...HandlerFunc) HandlerFunc {
+	funcs = append([]HandlerFunc(nil), funcs...)
+	return func(c *Context) bool {
+		return c.run(funcs, nil)
+	}
+}
+
+// Return a function that wraps funcs between before and after.
+// Order: before -> funcs -> after.
+// If before returns false, nothing else is called.
+// Otherwise after is always called once funcs are done, even if one of them returns false or panics,
+// and the result is false if any of funcs or after returns false.
+// Example: timing := WrapAround(start, stop); router.AddGet("/", timing(handler))
+func WrapAround(before, after HandlerFunc) func(funcs ...HandlerFunc) HandlerFunc {
+	return func(funcs ...HandlerFunc) HandlerFunc {
+		h := Chain(funcs...)
+		return func(c *Context) (ok bool) {
+			if !before(c) {
+				return false
+			}
+			defer func() {
+				ok = after(c) && ok
+			}()
+			return h(c)
+		}
+	}
+}
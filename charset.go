@@ -0,0 +1,150 @@
+package router
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Encode UTF-8 string to a charset.
+type CharsetEncoder func(s string) []byte
+
+var (
+	charsetMutex    sync.RWMutex
+	charsetEncoders = map[string]CharsetEncoder{
+		"iso-8859-1": encodeLatin1,
+		"us-ascii":   encodeASCII,
+	}
+)
+
+// Register encoder of charset used by text responses, such as "gbk" or "shift_jis".
+func RegisterCharset(name string, encoder CharsetEncoder) {
+	charsetMutex.Lock()
+	charsetEncoders[strings.ToLower(name)] = encoder
+	charsetMutex.Unlock()
+}
+
+func charsetEncoder(name string) CharsetEncoder {
+	charsetMutex.RLock()
+	defer charsetMutex.RUnlock()
+	return charsetEncoders[name]
+}
+
+// Return sorted names of registered charsets, so offers with the same quality are chosen deterministically.
+func charsetNames() []string {
+	charsetMutex.RLock()
+	names := make([]string, 0, len(charsetEncoders))
+	for k := range charsetEncoders {
+		names = append(names, k)
+	}
+	charsetMutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Characters out of the charset are replaced by '?'.
+func encodeLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func encodeASCII(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+// Transcode text responses to the charset preferred by Accept-Charset if it is not utf-8.
+// Charsets are registered by RegisterCharset, "iso-8859-1" and "us-ascii" are built-in.
+func (r *Router) SetTranscodeCharset(transcode bool) {
+	r.transcodeCharset = transcode
+}
+
+// Return the best charset in offers by Accept-Charset, offers[0] if header is empty or nothing is acceptable.
+func (c *Context) NegotiateCharset(offers ...string) string {
+	if len(offers) < 1 {
+		return ""
+	}
+	header := c.Req.Header.Get("Accept-Charset")
+	if header == "" {
+		return offers[0]
+	}
+	best, bestQ := offers[0], -1.0
+	for _, offer := range offers {
+		q := charsetQuality(header, offer)
+		if q > bestQ && q > 0 {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Return quality of charset in Accept-Charset header, -1 if not listed.
+func charsetQuality(header, charset string) float64 {
+	q := -1.0
+	for _, s := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, charset) && name != "*" {
+			continue
+		}
+		v := 1.0
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			f, err := strconv.ParseFloat(params[2:], 64)
+			if err == nil {
+				v = f
+			}
+		}
+		// Exact match takes precedence over "*".
+		if name != "*" {
+			return v
+		}
+		q = v
+	}
+	return q
+}
+
+// Set Content-Type of mediaType with charset and write text,
+// it is transcoded if router enables SetTranscodeCharset.
+func (c *Context) writeText(statusCode int, mediaType, text string) error {
+	charset := "utf-8"
+	data := []byte(nil)
+	if c.router != nil && c.router.transcodeCharset && c.Req.Header.Get("Accept-Charset") != "" {
+		offers := append([]string{charset}, charsetNames()...)
+		charset = c.NegotiateCharset(offers...)
+		if enc := charsetEncoder(charset); enc != nil {
+			data = enc(text)
+		} else {
+			charset = "utf-8"
+		}
+	}
+	c.Res.Header().Set("Content-Type", mediaType+"; charset="+charset)
+	c.Res.WriteHeader(statusCode)
+	var err error
+	if data != nil {
+		_, err = c.Res.Write(data)
+	} else {
+		_, err = io.WriteString(c.Res, text)
+	}
+	return err
+}
+
+// Set Content-Type and statusCode, write text/plain body.
+func (c *Context) WriteText(statusCode int, text string) error {
+	return c.writeText(statusCode, "text/plain", text)
+}
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Outbound HTTP client configuration of router, shared by c.HTTPClient and ProxyHandler.
+type Client struct {
+	// If it is nil, use http.DefaultTransport.
+	Transport http.RoundTripper
+	// Timeout of each call, 0 means no timeout besides the deadline of inbound request.
+	Timeout time.Duration
+}
+
+// Set Client of router, it's Transport is used by ProxyHandler whose Transport is nil.
+func (r *Router) SetClient(client *Client) {
+	r.client = client
+}
+
+// Return transport of router's Client, or http.DefaultTransport.
+func (r *Router) transport() http.RoundTripper {
+	if r != nil && r.client != nil && r.client.Transport != nil {
+		return r.client.Transport
+	}
+	return http.DefaultTransport
+}
+
+// Set request id and trace headers of current request to outbound request.
+func (c *Context) propagate(req *http.Request) {
+	if req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, c.RequestID())
+	}
+	c.InjectTrace(req)
+}
+
+// Implements http.RoundTripper, propagate request id, trace headers and deadline of Context.
+type contextTransport struct {
+	c         *Context
+	ctx       context.Context
+	transport http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Use deadline and cancellation of inbound request if outbound request has no context.
+	ctx := req.Context()
+	if ctx == context.Background() {
+		ctx = t.ctx
+	}
+	// Headers of caller's request are not modified.
+	req = req.Clone(ctx)
+	t.c.propagate(req)
+	propagateBudget(req)
+	return t.transport.RoundTrip(req)
+}
+
+// Return a http.Client bound to current request, it must not be used after handler returns.
+// Outbound requests carry request id, trace headers and remaining budget in X-Request-Timeout,
+// and are canceled when inbound request is done.
+func (c *Context) HTTPClient() *http.Client {
+	client := &http.Client{
+		Transport: &contextTransport{c: c, ctx: c.Req.Context(), transport: c.router.transport()},
+	}
+	if c.router.client != nil {
+		client.Timeout = c.router.client.Timeout
+	}
+	return client
+}
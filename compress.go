@@ -0,0 +1,190 @@
+package router
+
+import (
+	"bufio"
+	"compress/gzip"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Media types compressed by default, a type ends with "/" is a prefix.
+var DefaultCompressionTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// Compress responses of routes by gzip dynamically, see Router.SetCompression.
+// Routes are excluded by Route.SetCompression(false), such as file proxies of compressed data.
+// Responses of "text/event-stream", with Content-Encoding or media type not in Types are not compressed.
+type Compression struct {
+	// Gzip level, 0 is gzip.DefaultCompression.
+	Level int
+	// Media types to compress, empty uses DefaultCompressionTypes.
+	Types []string
+	pool  sync.Pool
+}
+
+// Set dynamic compression of route responses, nil to disable.
+func (r *Router) SetCompression(compression *Compression) {
+	r.compression = compression
+}
+
+// Set whether response of route is compressed by Compression of router, default is true.
+func (r *Route) SetCompression(enable bool) {
+	r.meta.noCompression = !enable
+}
+
+// Return true if response of media type should be compressed.
+func (p *Compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	types := p.Types
+	if len(types) < 1 {
+		types = DefaultCompressionTypes
+	}
+	for _, s := range types {
+		if s == mediaType || (strings.HasSuffix(s, "/") && strings.HasPrefix(mediaType, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Compression) getWriter(w http.ResponseWriter) *gzip.Writer {
+	if g, ok := p.pool.Get().(*gzip.Writer); ok {
+		g.Reset(w)
+		return g
+	}
+	level := p.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	g, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		g = gzip.NewWriter(w)
+	}
+	return g
+}
+
+// Return true if gzip is accepted by client.
+func acceptGzip(header string) bool {
+	for _, s := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		_, q, ok := strings.Cut(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// Return true if response of req can be compressed, gzip is accepted and it is not a tunnel or protocol upgrade.
+func compressRequest(req *http.Request) bool {
+	return req.Method != http.MethodConnect && req.Header.Get("Upgrade") == "" &&
+		acceptGzip(req.Header.Get("Accept-Encoding"))
+}
+
+// Compress response body by gzip, it decides whether to compress when status code is written.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	gzip        *gzip.Writer
+	decided     bool
+}
+
+func (w *compressWriter) reset(res http.ResponseWriter, compression *Compression) {
+	w.ResponseWriter = res
+	w.compression = compression
+	w.gzip = nil
+	w.decided = false
+}
+
+// Decide whether to compress by status code and header.
+func (w *compressWriter) decide(statusCode int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !w.compression.compressible(h.Get("Content-Type")) {
+		return
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	h.Add("Vary", "Accept-Encoding")
+	w.gzip = w.compression.getWriter(w.ResponseWriter)
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	// Informational response is sent immediately.
+	if statusCode >= 100 && statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.decide(statusCode)
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decide(http.StatusOK)
+	}
+	if w.gzip != nil {
+		return w.gzip.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Implements http.Flusher, compressed data so far is written.
+func (w *compressWriter) Flush() {
+	if w.gzip != nil {
+		w.gzip.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Write the rest of compressed data.
+func (w *compressWriter) Finish() {
+	if w.gzip == nil {
+		return
+	}
+	w.gzip.Close()
+	w.compression.pool.Put(w.gzip)
+	w.gzip = nil
+}
+
+// Implements http.Hijacker, only if nothing is compressed.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.gzip != nil {
+		return nil, nil, errHijack
+	}
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijack
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+// Use by http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
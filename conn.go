@@ -0,0 +1,51 @@
+package router
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Key of ConnData in connection context.
+type connDataKey struct{}
+
+// State of a connection, shared by requests on the same keep-alive connection.
+type ConnData struct {
+	Conn   net.Conn
+	mutex  sync.Mutex
+	values map[interface{}]interface{}
+}
+
+// Return value of key.
+func (d *ConnData) Get(key interface{}) interface{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.values[key]
+}
+
+// Set value of key.
+func (d *ConnData) Set(key, value interface{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.values == nil {
+		d.values = make(map[interface{}]interface{})
+	}
+	d.values[key] = value
+}
+
+// Return ConnData of current connection, nil if request is not served by Server.
+func (c *Context) ConnData() *ConnData {
+	d, _ := c.Req.Context().Value(connDataKey{}).(*ConnData)
+	return d
+}
+
+// Return a connection context hook that attaches a ConnData, then calls hook if it is not nil.
+func connContext(hook func(context.Context, net.Conn) context.Context) func(context.Context, net.Conn) context.Context {
+	return func(ctx context.Context, conn net.Conn) context.Context {
+		ctx = context.WithValue(ctx, connDataKey{}, &ConnData{Conn: conn})
+		if hook != nil {
+			ctx = hook(ctx, conn)
+		}
+		return ctx
+	}
+}
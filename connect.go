@@ -0,0 +1,138 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Set the chain of "OPTIONS *" request, it is called after Allow header is set to methods which have routes.
+// Default response status code 204.
+func (r *Router) SetServerOptions(funcs ...HandlerFunc) {
+	r.serverOptions = funcs
+}
+
+// Set the chain of CONNECT request in authority form, such as "CONNECT example.com:443", ConnectHandler.Handle can be used.
+// If it is not set, response 405.
+func (r *Router) SetConnect(funcs ...HandlerFunc) {
+	r.connect = funcs
+}
+
+// Handle "OPTIONS *" request.
+func (r *Router) serveServerOptions(c *Context) {
+	var allow []string
+	r.walk(func(method string, route *Route) {
+		if len(allow) < 1 || allow[len(allow)-1] != method {
+			allow = append(allow, method)
+		}
+	})
+	if len(allow) > 0 {
+		if r.headFallback && !hasString(allow, http.MethodHead) {
+			allow = append(allow, http.MethodHead)
+		}
+		if !hasString(allow, http.MethodOptions) {
+			allow = append(allow, http.MethodOptions)
+		}
+		c.Res.Header().Set("Allow", strings.Join(allow, ", "))
+	}
+	if len(r.serverOptions) > 0 {
+		r.callChain(c, r.serverOptions)
+		return
+	}
+	c.Res.WriteHeader(http.StatusNoContent)
+}
+
+// Handle CONNECT request in authority form.
+func (r *Router) serveConnect(c *Context) {
+	if len(r.connect) > 0 {
+		r.callChain(c, r.connect)
+		return
+	}
+	c.Res.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Tunnel CONNECT request to the target host, for forward proxy.
+// It hijacks the connection, so it only works with HTTP/1.x.
+type ConnectHandler struct {
+	// Return false to response 403, nil allows all targets. host is in form "host:port".
+	Allow func(c *Context, host string) bool
+	// Dial target, nil uses net.Dialer with Timeout.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Timeout of dial, 0 is no timeout.
+	Timeout time.Duration
+}
+
+// Can be use as HandlerFunc.
+func (h *ConnectHandler) Handle(c *Context) bool {
+	host := c.Req.Host
+	if _, port, err := net.SplitHostPort(host); err != nil || port == "" {
+		c.Res.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	if h.Allow != nil && !h.Allow(c, host) {
+		c.Res.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	ctx := c.Req.Context()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	dial := h.Dial
+	if dial == nil {
+		dial = new(net.Dialer).DialContext
+	}
+	target, err := dial(ctx, "tcp", host)
+	if err != nil {
+		c.Res.WriteHeader(http.StatusBadGateway)
+		return false
+	}
+	defer target.Close()
+	hijacker, ok := c.Res.(http.Hijacker)
+	if !ok {
+		c.Res.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		c.Res.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	defer conn.Close()
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	if err != nil {
+		return false
+	}
+	// Bytes read by server after the request.
+	if n := rw.Reader.Buffered(); n > 0 {
+		b, _ := rw.Reader.Peek(n)
+		if _, err = target.Write(b); err != nil {
+			return false
+		}
+	}
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		io.Copy(conn, target)
+		conn.Close()
+	}()
+	io.Copy(target, conn)
+	target.Close()
+	wait.Wait()
+	return false
+}
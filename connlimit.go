@@ -0,0 +1,161 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Metric names of connection limits.
+const (
+	// Count of connections closed by limits, tagged by "reason:total" or "reason:ip".
+	MetricConnRejected = "http.conn.rejected"
+	// Gauge of open connections.
+	MetricConns = "http.conns"
+)
+
+var errConnLimit = errors.New("too many connections of client")
+
+// Default idle timeout of keep-alive connections if connection limits are enabled,
+// so idle connections do not hold the slots.
+const defaultLimitIdleTimeout = time.Minute
+
+// Count connections, total and per client IP.
+type connLimiter struct {
+	maxConns int
+	maxPerIP int
+	metrics  MetricsSink
+	mutex    sync.Mutex
+	conns    int
+	perIP    map[string]int
+}
+
+// Return false if total connections reach the limit.
+func (l *connLimiter) acquire() bool {
+	l.mutex.Lock()
+	ok := l.maxConns < 1 || l.conns < l.maxConns
+	if ok {
+		l.conns++
+	}
+	n := l.conns
+	l.mutex.Unlock()
+	if l.metrics != nil {
+		if ok {
+			l.metrics.Gauge(MetricConns, float64(n))
+		} else {
+			l.metrics.Count(MetricConnRejected, 1, "reason:total")
+		}
+	}
+	return ok
+}
+
+// Return false if connections of ip reach the limit.
+func (l *connLimiter) acquireIP(ip string) bool {
+	l.mutex.Lock()
+	ok := l.perIP[ip] < l.maxPerIP
+	if ok {
+		if l.perIP == nil {
+			l.perIP = make(map[string]int)
+		}
+		l.perIP[ip]++
+	}
+	l.mutex.Unlock()
+	if !ok && l.metrics != nil {
+		l.metrics.Count(MetricConnRejected, 1, "reason:ip")
+	}
+	return ok
+}
+
+func (l *connLimiter) release(ip string) {
+	l.mutex.Lock()
+	l.conns--
+	n := l.conns
+	if ip != "" {
+		l.perIP[ip]--
+		if l.perIP[ip] < 1 {
+			delete(l.perIP, ip)
+		}
+	}
+	l.mutex.Unlock()
+	if l.metrics != nil {
+		l.metrics.Gauge(MetricConns, float64(n))
+	}
+}
+
+// Return count of open connections.
+func (l *connLimiter) count() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.conns
+}
+
+// Listener closes connections over limits.
+type limitListener struct {
+	net.Listener
+	limiter *connLimiter
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.limiter.acquire() {
+			conn.Close()
+			continue
+		}
+		return &limitConn{Conn: conn, limiter: l.limiter}, nil
+	}
+}
+
+// Connection counted by connLimiter.
+// Client IP is checked at the first Read or RemoteAddr, after PROXY protocol header is parsed.
+type limitConn struct {
+	net.Conn
+	limiter   *connLimiter
+	once      sync.Once
+	closeOnce sync.Once
+	ip        string
+	err       error
+}
+
+func (c *limitConn) init() {
+	c.once.Do(func() {
+		if c.limiter.maxPerIP < 1 {
+			return
+		}
+		ip, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String())
+		if err != nil {
+			return
+		}
+		if !c.limiter.acquireIP(ip) {
+			c.err = errConnLimit
+			c.Close()
+			return
+		}
+		c.ip = ip
+	})
+}
+
+func (c *limitConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *limitConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.Conn.RemoteAddr()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.limiter.release(c.ip)
+	})
+	return err
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ANSI colors of console access log.
+const (
+	colorGreen   = "\033[97;42m"
+	colorWhite   = "\033[90;47m"
+	colorYellow  = "\033[90;43m"
+	colorRed     = "\033[97;41m"
+	colorBlue    = "\033[97;44m"
+	colorMagenta = "\033[97;45m"
+	colorCyan    = "\033[97;46m"
+	colorReset   = "\033[0m"
+)
+
+func statusColor(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return colorRed
+	case status >= http.StatusBadRequest:
+		return colorYellow
+	case status >= http.StatusMultipleChoices:
+		return colorWhite
+	default:
+		return colorGreen
+	}
+}
+
+func methodColor(method string) string {
+	switch method {
+	case http.MethodGet:
+		return colorBlue
+	case http.MethodPost:
+		return colorCyan
+	case http.MethodPut:
+		return colorYellow
+	case http.MethodDelete:
+		return colorRed
+	case http.MethodPatch:
+		return colorGreen
+	case http.MethodHead:
+		return colorMagenta
+	default:
+		return colorWhite
+	}
+}
+
+// Return a HandlerFunc writes human-friendly access lines to w, use it in after chain for development.
+// Line: time | status | latency | client ip | method path (route pattern).
+// If color is true, method and status are colored.
+func ConsoleAccessLog(w io.Writer, color bool) HandlerFunc {
+	return func(c *Context) bool {
+		if c.route != nil && !c.route.sampleLog() {
+			return true
+		}
+		status := c.Status()
+		pattern := ""
+		if c.route != nil {
+			pattern = " " + c.route.path
+		}
+		var sc, mc, reset string
+		if color {
+			sc, mc, reset = statusColor(status), methodColor(c.Req.Method), colorReset
+		}
+		line := fmt.Sprintf("[ROUTER] %s |%s %3d %s| %12v | %15s |%s %-7s %s %q%s\n",
+			c.start.Format("2006/01/02 - 15:04:05"),
+			sc, status, reset,
+			time.Since(c.start).Round(time.Microsecond),
+			c.ClientIP(),
+			mc, c.Req.Method, reset,
+			c.Req.URL.Path, pattern)
+		io.WriteString(w, line)
+		return true
+	}
+}
+
+// Return a HandlerFunc uses ConsoleAccessLog(w, color) in dev mode, and AccessLog otherwise,
+// so structured (such as JSON) lines are logged in production.
+func DevAccessLog(w io.Writer, color bool) HandlerFunc {
+	console := ConsoleAccessLog(w, color)
+	return func(c *Context) bool {
+		if c.router != nil && c.router.devMode {
+			return console(c)
+		}
+		return AccessLog(c)
+	}
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -9,10 +10,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"hash"
-	"io"
 	"math/rand"
 	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,8 +25,8 @@ var (
 	// Context pool.
 	contextPool sync.Pool
 	// Content-Type.
-	ContentTypeJSON = mime.TypeByExtension(".json")
-	ContentTypeHTML = mime.TypeByExtension(".html")
+	ContentTypeJSON = "application/json; charset=utf-8"
+	ContentTypeHTML = "text/html; charset=utf-8"
 	ContentTypeJS   = mime.TypeByExtension(".js")
 	ContentTypeCSS  = mime.TypeByExtension(".css")
 	// Hash pool.
@@ -126,30 +128,173 @@ type Context struct {
 	Data interface{}
 	// A cache that you might use.
 	Buff bytes.Buffer
+	// Response wrapper of Res.
+	res responseWriter
+	// Router which is serving.
+	router *Router
+	// Matched route.
+	route *Route
+	// Value of recover() and stack if handler chain panic.
+	panicValue interface{}
+	panicStack []byte
+	// Request id and logger with request-scoped fields.
+	requestID string
+	logger    Logger
+	// Time when router start to serve.
+	start time.Time
+	// Request body prefix read by Recorder.
+	recordBody []byte
+	// Trace context, parsed when first used.
+	trace   TraceContext
+	traceOK bool
+	// Session loaded by SessionManager.
+	session        *Session
+	sessionManager *SessionManager
+	// Flash messages of request and response.
+	flashIn   []FlashMessage
+	flashOut  []FlashMessage
+	flashRead bool
+	// Claims of authenticated token.
+	claims Claims
+	// Parsed query values.
+	queryValues url.Values
+	// Used by HEAD fallback.
+	head HeadWriter
+	// Used by dynamic compression.
+	compress compressWriter
+	// Client location resolved by GeoIP.
+	geo GeoLocation
+	// Fields of audit event.
+	auditFields map[string]interface{}
+	// Dump request and response, nil if disabled, and error of reading request body.
+	dump     *Dump
+	dumpBody []byte
+	dumpErr  error
+	// In-flight counter of matched route.
+	statsCounter *routeCounter
+	// Routes fell through by Fallthrough.
+	fallThrough bool
+	tried       []*Route
+	// Response header before the first matched route, restored when it falls through.
+	fallHeader http.Header
+	// Byte arena of request.
+	arena arena
+	// Request is in notfound chain, and why.
+	notFound       bool
+	notFoundReason NotFoundReason
+	// Trailers set before the first write.
+	trailers map[string]string
+	// Nonce of Content-Security-Policy.
+	cspNonce string
+	// Resolved by Tenancy.
+	tenant string
+	// Locale of alias route.
+	locale string
+	// Random value of sampling, drawn once.
+	sampleValue float64
+	sampleDrawn bool
+	// Termination of streaming response.
+	streamEnd  StreamEnd
+	streamStop time.Time
+	// Cancel funcs of SetTimeout.
+	cancels []context.CancelFunc
+	// Request waited in queue of Limiter, and total wait time.
+	queued    bool
+	queueWait time.Duration
+	// Current chain of Next, index of the next handler, and whether it is stopped.
+	chain   []HandlerFunc
+	tail    HandlerFunc
+	index   int
+	stopped bool
+	// In after or recovery chain, not aborted by disconnect.
+	finishing bool
+	// handleAfter is called, it runs at most once even if after chain panics.
+	afterDone bool
+}
+
+// Reset fields for a new request.
+func (c *Context) reset(r *Router, res http.ResponseWriter, req *http.Request) {
+	c.Req = req
+	c.res.reset(res)
+	c.Res = &c.res
+	c.Param = c.Param[:0]
+	c.Data = nil
+	c.router = r
+	c.route = nil
+	c.panicValue = nil
+	c.panicStack = nil
+	c.requestID = ""
+	c.logger = nil
+	c.traceOK = false
+	c.session = nil
+	c.sessionManager = nil
+	c.flashIn = c.flashIn[:0]
+	c.flashOut = c.flashOut[:0]
+	c.flashRead = false
+	c.claims = nil
+	c.queryValues = nil
+	c.geo = GeoLocation{}
+	c.auditFields = nil
+	c.dump = nil
+	c.dumpErr = nil
+	c.statsCounter = nil
+	c.fallThrough = false
+	c.tried = c.tried[:0]
+	c.fallHeader = nil
+	c.arena.reset()
+	c.notFound = false
+	c.notFoundReason = NotFoundNone
+	c.trailers = nil
+	c.cspNonce = ""
+	c.tenant = ""
+	c.locale = ""
+	c.sampleDrawn = false
+	c.streamEnd = StreamEndNone
+	c.cancels = c.cancels[:0]
+	c.chain = nil
+	c.tail = nil
+	c.index = 0
+	c.stopped = false
+	c.finishing = false
+	c.afterDone = false
+	c.queued = false
+	c.queueWait = 0
+	c.start = time.Now()
+}
+
+// Drop references of request and put c back to pool, arena and buffers are reused by the next request.
+func (c *Context) release() {
+	c.Req = nil
+	c.Res = nil
+	c.res.ResponseWriter = nil
+	c.compress.reset(nil, nil)
+	c.Data = nil
+	c.router = nil
+	c.route = nil
+	c.logger = nil
+	contextPool.Put(c)
 }
 
 // Set Content-Type and statusCode, convert data to JSON and write to body,
 func (c *Context) WriteJSON(statusCode int, data interface{}) error {
-	c.Res.WriteHeader(statusCode)
 	c.Res.Header().Set("Content-Type", ContentTypeJSON)
+	c.Res.WriteHeader(statusCode)
 	enc := json.NewEncoder(c.Res)
 	return enc.Encode(data)
 }
 
 // Set Content-Type and statusCode, write data to body,
 func (c *Context) WriteJSONBytes(statusCode int, data []byte) error {
-	c.Res.WriteHeader(statusCode)
 	c.Res.Header().Set("Content-Type", ContentTypeJSON)
+	c.Res.WriteHeader(statusCode)
 	_, err := c.Res.Write(data)
 	return err
 }
 
 // Set Content-Type and statusCode, write to text body,
+// it is transcoded if router enables SetTranscodeCharset.
 func (c *Context) WriteHTML(statusCode int, text string) error {
-	c.Res.WriteHeader(statusCode)
-	c.Res.Header().Set("Content-Type", ContentTypeHTML)
-	_, err := io.WriteString(c.Res, text)
-	return err
+	return c.writeText(statusCode, "text/html", text)
 }
 
 // Return n length random string in range of randBytes.
@@ -241,6 +386,15 @@ func (c *Context) ParsePageQuery(q *PageQuery) string {
 	return ""
 }
 
+// Return ip of client from Req.RemoteAddr.
+func (c *Context) ClientIP() string {
+	host, _, err := net.SplitHostPort(c.Req.RemoteAddr)
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return host
+}
+
 // Return header["Authorization"] Bearer token.
 func (c *Context) BearerToken() string {
 	// 没有header
@@ -0,0 +1,102 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var handlerFuncType = reflect.TypeOf(HandlerFunc(nil))
+
+// Prefixes of controller method names.
+var controllerMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// Try to add routes of ctrl under basePath atomically, see AddRoutes.
+// Exported methods of signature func(*Context) bool are added by name,
+// method prefix and words in kebab case, such as GetUser -> GET /user, PostUserProfile -> POST /user-profile, Get -> GET basePath.
+// Exported fields of the same signature are added by tag, such as `route:"GET /users/:id"`, nil fields are ignored.
+func (r *Router) AddController(basePath string, ctrl interface{}) error {
+	v := reflect.ValueOf(ctrl)
+	if !v.IsValid() {
+		return errors.New("controller is nil")
+	}
+	var specs []RouteSpec
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		fn := v.Method(i)
+		if !fn.Type().ConvertibleTo(handlerFuncType) {
+			continue
+		}
+		method, name := controllerRoute(m.Name)
+		if method == "" {
+			continue
+		}
+		h := fn.Convert(handlerFuncType).Interface().(HandlerFunc)
+		specs = append(specs, RouteSpec{Method: method, Path: path.Join("/", basePath, name), Handler: []HandlerFunc{h}})
+	}
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		t = v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag, ok := f.Tag.Lookup("route")
+			if !ok || !f.IsExported() || !f.Type.ConvertibleTo(handlerFuncType) || v.Field(i).IsNil() {
+				continue
+			}
+			method, name, ok := strings.Cut(tag, " ")
+			if !ok {
+				return fmt.Errorf("invalid route tag '%s' of field %s", tag, f.Name)
+			}
+			h := v.Field(i).Convert(handlerFuncType).Interface().(HandlerFunc)
+			specs = append(specs, RouteSpec{Method: method, Path: path.Join("/", basePath, strings.TrimSpace(name)), Handler: []HandlerFunc{h}})
+		}
+	}
+	return r.AddRoutes(specs)
+}
+
+// Return http method and route name of controller method name, empty method if it is not a route.
+func controllerRoute(name string) (string, string) {
+	for _, method := range controllerMethods {
+		prefix := method[:1] + strings.ToLower(method[1:])
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		name = name[len(prefix):]
+		if name != "" && !unicode.IsUpper(rune(name[0])) {
+			return "", ""
+		}
+		return method, kebabCase(name)
+	}
+	return "", ""
+}
+
+// Return kebab case of camel case name, acronyms are one word, such as HTTPStatus -> http-status.
+func kebabCase(name string) string {
+	var s strings.Builder
+	r := []rune(name)
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1]))) {
+				s.WriteByte('-')
+			}
+			c = unicode.ToLower(c)
+		}
+		s.WriteRune(c)
+	}
+	return s.String()
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	errNoCookieKey     = errors.New("cookie: router has no cookie key")
+	errInvalidCookie   = errors.New("cookie: invalid value")
+	errCookieValueSize = errors.New("cookie: value too large")
+)
+
+// Max size of encoded cookie value.
+const maxCookieValueSize = 4000
+
+// Set the key used to encrypt and sign cookies, it is hashed by SHA256 into an AES-256 key.
+func (r *Router) SetCookieKey(key []byte) error {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	r.cookieAEAD = aead
+	return nil
+}
+
+func (c *Context) cookieAEAD() cipher.AEAD {
+	if c.router == nil {
+		return nil
+	}
+	return c.router.cookieAEAD
+}
+
+// Encrypt and sign value with AES-GCM, the cookie name is authenticated too.
+// Set cookie.Value to plain value, it is replaced by encoded value.
+func (c *Context) SetSecureCookie(cookie *http.Cookie) error {
+	aead := c.cookieAEAD()
+	if aead == nil {
+		return errNoCookieKey
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(cookie.Value)+aead.Overhead())
+	rand.Read(nonce)
+	data := aead.Seal(nonce, nonce, []byte(cookie.Value), []byte(cookie.Name))
+	value := base64.RawURLEncoding.EncodeToString(data)
+	if len(value) > maxCookieValueSize {
+		return errCookieValueSize
+	}
+	cc := *cookie
+	cc.Value = value
+	setCookie(c.Res.Header(), &cc)
+	return nil
+}
+
+// Return decrypted value of cookie set by SetSecureCookie.
+func (c *Context) SecureCookie(name string) (string, error) {
+	aead := c.cookieAEAD()
+	if aead == nil {
+		return "", errNoCookieKey
+	}
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errInvalidCookie
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	return string(value), nil
+}
+
+// Add Set-Cookie header, replace the previous one with the same name.
+func setCookie(h http.Header, cookie *http.Cookie) {
+	prefix := cookie.Name + "="
+	values := h["Set-Cookie"]
+	for i := 0; i < len(values); i++ {
+		if strings.HasPrefix(values[i], prefix) {
+			values = append(values[:i], values[i+1:]...)
+			i--
+		}
+	}
+	if s := cookie.String(); s != "" {
+		values = append(values, s)
+	}
+	if len(values) > 0 {
+		h["Set-Cookie"] = values
+	} else {
+		delete(h, "Set-Cookie")
+	}
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cross-origin resource sharing, use Handle in before chain.
+// A route can override it by Route.SetCORS, for endpoints with stricter origins.
+type CORS struct {
+	// Allowed origins, "*" allows any origin.
+	AllowOrigins []string
+	// If it is not nil, it is used instead of AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+	// Default is GET, HEAD, POST, PUT, PATCH, DELETE.
+	AllowMethods []string
+	// If it is empty, the request headers of preflight are allowed.
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// Response Access-Control-Allow-Credentials to origins allowed by AllowOriginFunc or listed in AllowOrigins.
+	// Origins allowed only by "*" get "*" without credentials, so not every website can send credentialed requests.
+	AllowCredentials bool
+	// How long the preflight result can be cached, 0 means header is not sent.
+	MaxAge time.Duration
+	// Response Access-Control-Allow-Private-Network to preflight which requests it.
+	AllowPrivateNetwork bool
+}
+
+// Return whether origin is allowed.
+func (cors *CORS) allowOrigin(origin string) bool {
+	if cors.AllowOriginFunc != nil {
+		return cors.AllowOriginFunc(origin)
+	}
+	for _, s := range cors.AllowOrigins {
+		if s == "*" || strings.EqualFold(s, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return CORS of the route matched by method and request path, or cors if route has none.
+func (cors *CORS) routeCORS(c *Context, method string) *CORS {
+	if c.router == nil {
+		return cors
+	}
+	route := c.router.lookup(c, method)
+	if route != nil && route.meta.cors != nil {
+		return route.meta.cors
+	}
+	return cors
+}
+
+// Handle preflight and set CORS headers. Can be use as HandlerFunc in before chain.
+// Preflight request is responded with 204 and the chain stops.
+func (cors *CORS) Handle(c *Context) bool {
+	origin := c.Req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	header := c.Res.Header()
+	header.Add("Vary", "Origin")
+	requestMethod := c.Req.Header.Get("Access-Control-Request-Method")
+	// Preflight.
+	if c.Req.Method == http.MethodOptions && requestMethod != "" {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		cc := cors.routeCORS(c, requestMethod)
+		if !cc.allowOrigin(origin) {
+			c.Res.WriteHeader(http.StatusNoContent)
+			return false
+		}
+		cc.setOriginHeader(header, origin)
+		methods := cc.AllowMethods
+		if len(methods) < 1 {
+			methods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+		}
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(cc.AllowHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(cc.AllowHeaders, ", "))
+		} else if h := c.Req.Header.Get("Access-Control-Request-Headers"); h != "" {
+			header.Set("Access-Control-Allow-Headers", h)
+		}
+		if cc.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(cc.MaxAge/time.Second), 10))
+		}
+		if cc.AllowPrivateNetwork && c.Req.Header.Get("Access-Control-Request-Private-Network") == "true" {
+			header.Set("Access-Control-Allow-Private-Network", "true")
+		}
+		c.Res.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	// Actual request.
+	cc := cors.routeCORS(c, c.Req.Method)
+	if !cc.allowOrigin(origin) {
+		return true
+	}
+	cc.setOriginHeader(header, origin)
+	if len(cc.ExposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(cc.ExposeHeaders, ", "))
+	}
+	return true
+}
+
+func (cors *CORS) setOriginHeader(header http.Header, origin string) {
+	if cors.AllowCredentials && cors.listedOrigin(origin) {
+		header.Set("Access-Control-Allow-Credentials", "true")
+		header.Set("Access-Control-Allow-Origin", origin)
+		return
+	}
+	for _, s := range cors.AllowOrigins {
+		if s == "*" && cors.AllowOriginFunc == nil {
+			header.Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+}
+
+// Return whether origin is allowed by AllowOriginFunc or listed in AllowOrigins, not by "*".
+func (cors *CORS) listedOrigin(origin string) bool {
+	if cors.AllowOriginFunc != nil {
+		return true
+	}
+	for _, s := range cors.AllowOrigins {
+		if strings.EqualFold(s, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set CORS of route, it overrides the CORS in before chain.
+func (r *Route) SetCORS(cors *CORS) {
+	r.meta.cors = cors
+}
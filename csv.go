@@ -0,0 +1,64 @@
+package router
+
+import (
+	"encoding/csv"
+	"mime"
+)
+
+// Content-Type of CSV.
+const ContentTypeCSV = "text/csv; charset=utf-8"
+
+// UTF-8 byte order mark, Excel needs it to detect UTF-8 CSV.
+const utf8BOM = "\xef\xbb\xbf"
+
+// Options of WriteCSV.
+type CSVOptions struct {
+	// If it is not empty, response as attachment with this file name.
+	Filename string
+	// Write UTF-8 BOM first, so Excel can open it correctly.
+	BOM bool
+	// Field delimiter, default is ','.
+	Comma rune
+}
+
+// Set Content-Type, Content-Disposition and statusCode, write headers as the first record,
+// then call rows to write records.
+// Records are streamed to client as csv.Writer's buffer fills, so large exports are not buffered fully in memory.
+// Errors after response header is written can not change status code, they are only returned.
+func (c *Context) WriteCSV(statusCode int, headers []string, rows func(w *csv.Writer) error, opts ...CSVOptions) error {
+	var opt CSVOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	h := c.Res.Header()
+	h.Set("Content-Type", ContentTypeCSV)
+	if opt.Filename != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": opt.Filename}))
+	}
+	c.Res.WriteHeader(statusCode)
+	if opt.BOM {
+		_, err := c.Res.Write([]byte(utf8BOM))
+		if err != nil {
+			return err
+		}
+	}
+	w := csv.NewWriter(c.Res)
+	if opt.Comma != 0 {
+		w.Comma = opt.Comma
+	}
+	if len(headers) > 0 {
+		err := w.Write(headers)
+		if err != nil {
+			return err
+		}
+	}
+	if rows != nil {
+		err := rows(w)
+		if err != nil {
+			w.Flush()
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
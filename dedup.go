@@ -0,0 +1,39 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Share data of h with a CacheHandler of the same content added before, so identical files are stored once.
+// Content is addressed by its ETag, which is served by h too.
+func (r *Router) dedupStatic(h *CacheHandler) {
+	sum := sha256.Sum256(h.Data)
+	h.etag = base64.RawURLEncoding.EncodeToString(sum[:])
+	if r.staticStore == nil {
+		r.staticStore = make(map[string]*CacheHandler)
+	}
+	shared, ok := r.staticStore[h.etag]
+	if !ok {
+		r.staticStore[h.etag] = h
+		return
+	}
+	if !bytes.Equal(shared.Data, h.Data) {
+		return
+	}
+	h.Data = shared.Data
+	h.shared = shared
+}
+
+// Set ETag header of the data of encoding, empty encoding for origin data.
+func (h *CacheHandler) setETag(c *Context, encoding string) {
+	if h.etag == "" {
+		return
+	}
+	if encoding == "" {
+		c.Res.Header().Set("ETag", `"`+h.etag+`"`)
+		return
+	}
+	c.Res.Header().Set("ETag", `"`+h.etag+"-"+encoding+`"`)
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	errNoDigest       = errors.New("digest: no supported algorithm in Digest header")
+	errDigestMismatch = errors.New("digest: mismatch")
+)
+
+// Max request body size read by VerifyDigest.
+var MaxDigestBodySize int64 = 32 << 20
+
+// Return hash sum of b, the result is valid until next call.
+func (h *hashBuffer) Sum(b []byte) []byte {
+	h.hash.Reset()
+	h.hash.Write(b)
+	return h.hash.Sum(h.sum[:0])
+}
+
+// Use hash of p to hash b, return base64 of sum.
+func hashPoolBase64(p *sync.Pool, b []byte) string {
+	h := p.Get().(*hashBuffer)
+	s := base64.StdEncoding.EncodeToString(h.Sum(b))
+	p.Put(h)
+	return s
+}
+
+// Return Digest header value of b, example: "sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=".
+func Digest(b []byte) string {
+	return "sha-256=" + hashPoolBase64(&sha256Pool, b)
+}
+
+// Verify b against Digest header value, sha-256 and sha-512 are supported.
+func VerifyDigest(header string, b []byte) error {
+	found := false
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			continue
+		}
+		var sum string
+		switch strings.ToLower(s[:i]) {
+		case "sha-256":
+			sum = hashPoolBase64(&sha256Pool, b)
+		case "sha-512":
+			sum = hashPoolBase64(&sha512Pool, b)
+		default:
+			continue
+		}
+		found = true
+		if sum != s[i+1:] {
+			return errDigestMismatch
+		}
+	}
+	if !found {
+		return errNoDigest
+	}
+	return nil
+}
+
+// Set Digest header of response body b.
+func (c *Context) SetDigest(b []byte) {
+	c.Res.Header().Set("Digest", Digest(b))
+}
+
+// Read request body and verify it against Digest header.
+// Body can still be read by handlers after verification.
+func (c *Context) VerifyDigest() ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Req.Body, MaxDigestBodySize))
+	if err != nil {
+		return nil, err
+	}
+	c.Req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, VerifyDigest(c.Req.Header.Get("Digest"), body)
+}
+
+// Response 400 if request body does not match Digest header. Can be use as HandlerFunc.
+func DigestHandler(c *Context) bool {
+	_, err := c.VerifyDigest()
+	if err != nil {
+		c.Res.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
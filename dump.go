@@ -0,0 +1,138 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dump full request and response to logger in dev mode, when the request has header or query flag.
+// Use Handle as a before HandlerFunc.
+type Dump struct {
+	// Header name of the flag, such as "X-Debug-Dump: 1".
+	Header string
+	// Query name of the flag, such as "?debug_dump=1".
+	Query string
+	// Max bytes of request and response body to dump.
+	MaxBodySize int
+}
+
+// Return a Dump with flag "X-Debug-Dump" header or "debug_dump" query.
+func NewDump() *Dump {
+	d := new(Dump)
+	d.Header = "X-Debug-Dump"
+	d.Query = "debug_dump"
+	d.MaxBodySize = 64 << 10
+	return d
+}
+
+// Return true if request has the flag.
+func (d *Dump) flagged(c *Context) bool {
+	if d.Header != "" && c.Req.Header.Get(d.Header) != "" {
+		return true
+	}
+	return d.Query != "" && c.Req.URL.Query().Get(d.Query) != ""
+}
+
+// Can be use as HandlerFunc, start to capture request and response if router is in dev mode and request is flagged.
+// Response is dumped after the after chain.
+func (d *Dump) Handle(c *Context) bool {
+	if c.router == nil || !c.router.devMode || !d.flagged(c) {
+		return true
+	}
+	c.dump = d
+	c.dumpBody = c.dumpBody[:0]
+	c.res.body = c.res.body[:0]
+	if c.res.bodyMax < d.MaxBodySize {
+		c.res.bodyMax = d.MaxBodySize
+	}
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return true
+	}
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, c.Req.Body, int64(d.MaxBodySize))
+	c.dumpBody = append(c.dumpBody, buf.Bytes()...)
+	switch err {
+	case nil:
+		c.Req.Body = &recordBody{Reader: io.MultiReader(&buf, c.Req.Body), Closer: c.Req.Body}
+	case io.EOF:
+		c.Req.Body = &recordBody{Reader: &buf, Closer: c.Req.Body}
+	default:
+		c.dumpErr = err
+		c.Req.Body = &recordBody{Reader: io.MultiReader(&buf, &errorReader{err: err}), Closer: c.Req.Body}
+	}
+	return true
+}
+
+// Log request and response.
+func (d *Dump) end(c *Context) {
+	var str strings.Builder
+	fmt.Fprintf(&str, "%s %s %s\n", c.Req.Method, c.Req.URL.RequestURI(), c.Req.Proto)
+	dumpHeader(&str, c.Req.Header)
+	d.dumpBody(&str, c, c.Req.Header, c.dumpBody, c.Req.ContentLength)
+	if c.dumpErr != nil {
+		fmt.Fprintf(&str, "[read error: %v]\n", c.dumpErr)
+	}
+	fmt.Fprintf(&str, "\n%d %s\n", c.Status(), http.StatusText(c.Status()))
+	dumpHeader(&str, c.res.Header())
+	body := c.res.body
+	if len(body) > d.MaxBodySize {
+		body = body[:d.MaxBodySize]
+	}
+	d.dumpBody(&str, c, c.res.Header(), body, c.Size())
+	c.Logger().Info("dump", "dump", str.String())
+}
+
+func dumpHeader(str *strings.Builder, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(str, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// Write body, binary body is written as a summary.
+func (d *Dump) dumpBody(str *strings.Builder, c *Context, h http.Header, body []byte, size int64) {
+	if len(body) < 1 {
+		return
+	}
+	str.WriteByte('\n')
+	if isBinary(h.Get("Content-Type"), body) {
+		fmt.Fprintf(str, "[binary %d bytes]\n", size)
+		return
+	}
+	if c.router.redactor != nil {
+		body = c.router.redactor.Redact(body)
+	}
+	str.Write(body)
+	if size > int64(len(body)) {
+		fmt.Fprintf(str, "\n[truncated %d bytes]", size-int64(len(body)))
+	}
+	str.WriteByte('\n')
+}
+
+// Return true if body looks like binary data.
+func isBinary(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	if strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") || strings.Contains(contentType, "form-urlencoded") ||
+		strings.Contains(contentType, "javascript") {
+		// Body may be truncated in the middle of a rune.
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+		return bytes.IndexByte(body, 0) >= 0 || !utf8.Valid(body)
+	}
+	return true
+}
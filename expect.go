@@ -0,0 +1,30 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Set a hook called before handlers if request has "Expect: 100-continue", nil removes it.
+// If fn returns false, handlers are not called and body is not sent by client,
+// the response is 417 if fn does not write one, such as 401 or 413.
+// If fn returns true, "100 Continue" is sent when handlers read the body.
+// Example: route.SetExpectContinue(func(c *Context) bool { return c.Req.ContentLength <= quota })
+func (r *Route) SetExpectContinue(fn HandlerFunc) {
+	r.meta.expectContinue = fn
+}
+
+// Return false if the hook of route rejects the request which expects 100-continue.
+func (r *Route) checkExpectContinue(c *Context) bool {
+	if r.meta.expectContinue == nil || !strings.EqualFold(c.Req.Header.Get("Expect"), "100-continue") {
+		return true
+	}
+	if r.meta.expectContinue(c) {
+		return true
+	}
+	// Body is not read, so "100 Continue" is not sent and net/http closes the connection.
+	if c.res.status == 0 {
+		c.Res.WriteHeader(http.StatusExpectationFailed)
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package router
+
+// If enable, a handler can return c.Fallthrough() to let the router try the next matching route.
+func (r *Router) SetFallthrough(enable bool) {
+	r.fallThrough = enable
+}
+
+// Stop the handler chain and try the next matching route, such as the param route after a static route.
+// It must be called before writing response, and works only if SetFallthrough(true).
+// Response headers set by the route and its handlers are discarded, headers set before matching are kept.
+// If no other route matches, the phases after PhaseMatch run.
+// Example: return c.Fallthrough()
+func (c *Context) Fallthrough() bool {
+	c.fallThrough = c.router != nil && c.router.fallThrough
+	return false
+}
+
+// Return true if route is tried and fell through.
+func (c *Context) triedRoute(route *Route) bool {
+	for _, r := range c.tried {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if handler fell through, and prepare context for next match.
+func (r *Router) fallNext(c *Context) bool {
+	if !c.fallThrough {
+		return false
+	}
+	c.fallThrough = false
+	if c.res.status != 0 || (c.res.ResponseWriter == &c.head && c.head.status != 0) {
+		return false
+	}
+	c.tried = append(c.tried, c.route)
+	if r.stats != nil {
+		r.stats.done(c)
+	}
+	// Undo dynamic compression, nothing is written.
+	if c.res.ResponseWriter == &c.compress {
+		c.res.ResponseWriter = c.compress.ResponseWriter
+	}
+	// Undo HEAD fallback.
+	if c.res.ResponseWriter == &c.head {
+		c.res.ResponseWriter = c.head.ResponseWriter
+	}
+	c.Res = &c.res
+	// Undo headers set by the route and its handlers.
+	header := c.Res.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, v := range c.fallHeader {
+		header[k] = append(v[:0:0], v...)
+	}
+	c.route = nil
+	c.logger = nil
+	c.Param = c.Param[:0]
+	return true
+}
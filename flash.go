@@ -0,0 +1,51 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Name of flash cookie.
+var FlashCookieName = "flash"
+
+// A one-shot message, shown on the next page, used in post-redirect-get flows.
+type FlashMessage struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Add a flash message, it is saved in an encrypted cookie and can be read in next request.
+// It must be called before response body is written.
+func (c *Context) Flash(kind, message string) error {
+	c.flashOut = append(c.flashOut, FlashMessage{Kind: kind, Message: message})
+	data, err := json.Marshal(c.flashOut)
+	if err != nil {
+		return err
+	}
+	return c.SetSecureCookie(&http.Cookie{
+		Name:     FlashCookieName,
+		Value:    string(data),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Return flash messages of request, and clear the cookie.
+// Invalid cookie is ignored.
+func (c *Context) Flashes() []FlashMessage {
+	if c.flashRead {
+		return c.flashIn
+	}
+	c.flashRead = true
+	value, err := c.SecureCookie(FlashCookieName)
+	if err != nil {
+		return nil
+	}
+	json.Unmarshal([]byte(value), &c.flashIn)
+	// Do not clear the messages added in this request.
+	if len(c.flashOut) < 1 {
+		setCookie(c.Res.Header(), &http.Cookie{Name: FlashCookieName, Path: "/", MaxAge: -1})
+	}
+	return c.flashIn
+}
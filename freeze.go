@@ -0,0 +1,32 @@
+package router
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Returned by Add after Freeze.
+var ErrFrozen = errors.New("router is frozen")
+
+// Lock route tables after startup, later Add returns ErrFrozen and Remove returns false.
+// Before Freeze, routes can be added or removed while serving, and matching is synchronized by a RWMutex.
+// After Freeze, matching skips synchronization.
+func (r *Router) Freeze() {
+	r.mutex.Lock()
+	atomic.StoreInt32(&r.frozen, 1)
+	r.mutex.Unlock()
+}
+
+// Return true if Freeze is called.
+func (r *Router) Frozen() bool {
+	return atomic.LoadInt32(&r.frozen) != 0
+}
+
+// Lock route tables for reading if router is not frozen, return true if locked.
+func (r *Router) rlock() bool {
+	if atomic.LoadInt32(&r.frozen) != 0 {
+		return false
+	}
+	r.mutex.RLock()
+	return true
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Location of a client ip.
+type GeoLocation struct {
+	// ISO 3166-1 alpha-2 code, such as "US".
+	Country string
+	// Region or subdivision name, may be empty.
+	Region string
+}
+
+// Resolve ip to location, such as a MaxMind database wrapper.
+type GeoResolver interface {
+	Resolve(ip string) (GeoLocation, error)
+}
+
+// Return a HandlerFunc that resolves client ip by resolver and saves the location to Context.
+// It is used as a before HandlerFunc, so route.AllowCountries can work.
+// Errors are logged and the location is left empty.
+func GeoIP(resolver GeoResolver) HandlerFunc {
+	return func(c *Context) bool {
+		loc, err := resolver.Resolve(c.ClientIP())
+		if err != nil {
+			c.Logger().Warn("geoip resolve failed", "ip", c.ClientIP(), "error", err)
+			return true
+		}
+		c.geo = loc
+		return true
+	}
+}
+
+// Return location saved by GeoIP, Country is empty if not resolved.
+func (c *Context) Geo() GeoLocation {
+	return c.geo
+}
+
+// Only allow clients from countries to access the route, otherwise response 403.
+// Clients with unknown country are denied too. Call without arguments to allow all.
+func (r *Route) AllowCountries(countries ...string) {
+	r.meta.countries = nil
+	for _, s := range countries {
+		r.meta.countries = append(r.meta.countries, strings.ToUpper(s))
+	}
+}
+
+// Return false and response 403 if client country is not allowed.
+func (r *Route) checkCountry(c *Context) bool {
+	if len(r.meta.countries) < 1 {
+		return true
+	}
+	country := strings.ToUpper(c.geo.Country)
+	for _, s := range r.meta.countries {
+		if s == country {
+			return true
+		}
+	}
+	c.Res.WriteHeader(http.StatusForbidden)
+	return false
+}
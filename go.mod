@@ -1,3 +1,3 @@
 module github.com/qq51529210/http-router
 
-go 1.15
+go 1.21
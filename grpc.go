@@ -0,0 +1,234 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Invoke unary method of a backend gRPC server, such as a wrapper of grpc.ClientConn.Invoke with a raw codec.
+// method is full method name "/package.Service/Method", req and resp are encoded messages,
+// in protobuf or JSON according to codec ("proto" or "json").
+// Return *GRPCError to set status code.
+type GRPCInvoker interface {
+	Invoke(ctx context.Context, method, codec string, md http.Header, req []byte) (resp []byte, trailer http.Header, err error)
+}
+
+// gRPC status codes.
+const (
+	GRPCOK = iota
+	GRPCCanceled
+	GRPCUnknown
+	GRPCInvalidArgument
+	GRPCDeadlineExceeded
+	GRPCNotFound
+	GRPCAlreadyExists
+	GRPCPermissionDenied
+	GRPCResourceExhausted
+	GRPCFailedPrecondition
+	GRPCAborted
+	GRPCOutOfRange
+	GRPCUnimplemented
+	GRPCInternal
+	GRPCUnavailable
+	GRPCDataLoss
+	GRPCUnauthenticated
+)
+
+// Connect error names and http status codes, index is gRPC code.
+var (
+	grpcCodeNames = []string{
+		"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found",
+		"already_exists", "permission_denied", "resource_exhausted", "failed_precondition",
+		"aborted", "out_of_range", "unimplemented", "internal", "unavailable", "data_loss",
+		"unauthenticated",
+	}
+	grpcHTTPStatus = []int{
+		200, 499, 500, 400, 504, 404, 409, 403, 429, 400, 409, 400, 501, 500, 503, 500, 401,
+	}
+)
+
+// Error with gRPC status code.
+type GRPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *GRPCError) Error() string {
+	return fmt.Sprintf("grpc: code %d, %s", e.Code, e.Message)
+}
+
+// Return gRPC code and message of err.
+func grpcStatus(err error) (int, string) {
+	if err == nil {
+		return GRPCOK, ""
+	}
+	var e *GRPCError
+	if errors.As(err, &e) && e.Code >= 0 && e.Code < len(grpcCodeNames) {
+		return e.Code, e.Message
+	}
+	if errors.Is(err, context.Canceled) {
+		return GRPCCanceled, err.Error()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return GRPCDeadlineExceeded, err.Error()
+	}
+	return GRPCUnknown, err.Error()
+}
+
+// Max bytes of request message.
+var MaxGRPCMessageSize int64 = 4 << 20
+
+// Bridge gRPC-Web and Connect unary requests to a GRPCInvoker.
+type GRPCBridge struct {
+	Invoker GRPCInvoker
+}
+
+// Add a POST route "prefix/*" serves "prefix/package.Service/Method" by invoker.
+// Requests can be gRPC-Web (binary or text) or Connect unary (proto or json).
+func (r *Router) AddGRPC(prefix string, invoker GRPCInvoker) (*Route, error) {
+	b := &GRPCBridge{Invoker: invoker}
+	return r.AddPost(strings.TrimSuffix(prefix, "/")+"/*", b.Handle)
+}
+
+// Can be use as HandlerFunc, c.DecodedWildcardPath() is "package.Service/Method".
+func (b *GRPCBridge) Handle(c *Context) bool {
+	method := "/" + c.DecodedWildcardPath()
+	contentType := c.Req.Header.Get("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		b.handleGRPCWeb(c, method, contentType)
+	case contentType == "application/proto":
+		b.handleConnect(c, method, contentType, "proto")
+	case contentType == "application/json":
+		b.handleConnect(c, method, contentType, "json")
+	default:
+		c.Res.WriteHeader(http.StatusUnsupportedMediaType)
+	}
+	return true
+}
+
+// Return request headers as gRPC metadata.
+func grpcMetadata(h http.Header) http.Header {
+	md := make(http.Header)
+	for k, v := range h {
+		switch k {
+		case "Content-Type", "Content-Length", "Accept-Encoding", "Connection",
+			"Connect-Protocol-Version", "X-Grpc-Web", "X-User-Agent":
+			continue
+		}
+		md[k] = v
+	}
+	return md
+}
+
+func (b *GRPCBridge) handleGRPCWeb(c *Context, method, contentType string) {
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	codec := "proto"
+	if strings.HasSuffix(contentType, "+json") {
+		codec = "json"
+	}
+	var body io.Reader = io.LimitReader(c.Req.Body, MaxGRPCMessageSize+5)
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	var resp []byte
+	var trailer http.Header
+	// Read the single data frame.
+	var head [5]byte
+	_, err := io.ReadFull(body, head[:])
+	if err == nil {
+		n := binary.BigEndian.Uint32(head[1:])
+		switch {
+		case head[0]&1 != 0:
+			err = &GRPCError{Code: GRPCUnimplemented, Message: "compressed message is not supported"}
+		case int64(n) > MaxGRPCMessageSize:
+			err = &GRPCError{Code: GRPCResourceExhausted, Message: "message too large"}
+		default:
+			req := make([]byte, n)
+			_, err = io.ReadFull(body, req)
+			if err == nil {
+				resp, trailer, err = b.Invoker.Invoke(c.Req.Context(), method, codec, grpcMetadata(c.Req.Header), req)
+			} else {
+				err = &GRPCError{Code: GRPCInvalidArgument, Message: "invalid message"}
+			}
+		}
+	} else {
+		err = &GRPCError{Code: GRPCInvalidArgument, Message: "invalid message"}
+	}
+	// Response.
+	code, msg := grpcStatus(err)
+	var buf bytes.Buffer
+	if err == nil {
+		binary.BigEndian.PutUint32(head[1:], uint32(len(resp)))
+		head[0] = 0
+		buf.Write(head[:])
+		buf.Write(resp)
+	}
+	var t bytes.Buffer
+	fmt.Fprintf(&t, "grpc-status: %d\r\n", code)
+	if msg != "" {
+		fmt.Fprintf(&t, "grpc-message: %s\r\n", url.PathEscape(msg))
+	}
+	for k, v := range trailer {
+		for _, s := range v {
+			fmt.Fprintf(&t, "%s: %s\r\n", strings.ToLower(k), s)
+		}
+	}
+	head[0] = 0x80
+	binary.BigEndian.PutUint32(head[1:], uint32(t.Len()))
+	buf.Write(head[:])
+	buf.Write(t.Bytes())
+	c.Res.Header().Set("Content-Type", contentType)
+	data := buf.Bytes()
+	if text {
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	c.Res.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	c.Res.WriteHeader(http.StatusOK)
+	c.Res.Write(data)
+}
+
+func (b *GRPCBridge) handleConnect(c *Context, method, contentType, codec string) {
+	var resp []byte
+	var trailer http.Header
+	req, err := io.ReadAll(io.LimitReader(c.Req.Body, MaxGRPCMessageSize+1))
+	if err != nil {
+		err = &GRPCError{Code: GRPCInvalidArgument, Message: "invalid message"}
+	} else if int64(len(req)) > MaxGRPCMessageSize {
+		err = &GRPCError{Code: GRPCResourceExhausted, Message: "message too large"}
+	} else {
+		resp, trailer, err = b.Invoker.Invoke(c.Req.Context(), method, codec, grpcMetadata(c.Req.Header), req)
+	}
+	h := c.Res.Header()
+	for k, v := range trailer {
+		h["Trailer-"+k] = v
+	}
+	if err != nil {
+		code, msg := grpcStatus(err)
+		h.Set("Content-Type", ContentTypeJSON)
+		c.Res.WriteHeader(grpcHTTPStatus[code])
+		json.NewEncoder(c.Res).Encode(map[string]string{
+			"code":    grpcCodeNames[code],
+			"message": msg,
+		})
+		return
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(resp)))
+	c.Res.WriteHeader(http.StatusOK)
+	c.Res.Write(resp)
+}
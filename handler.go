@@ -8,12 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
-	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,7 +26,46 @@ type FileHandler struct {
 
 // Can be use as HandlerFunc
 func (h *FileHandler) Handle(c *Context) bool {
+	setRegisteredContentType(c, filepath.ToSlash(h.File))
 	http.ServeFile(c.Res, c.Req, h.File)
+	logStaticError(c, h.File)
+	return true
+}
+
+// Handle static file in fs.FS, such as embed.FS.
+type FSFileHandler struct {
+	FS fs.FS
+	// File name in FS.
+	Name string
+}
+
+// Can be use as HandlerFunc
+func (h *FSFileHandler) Handle(c *Context) bool {
+	f, err := h.FS.Open(h.Name)
+	if err != nil {
+		c.Logger().Warn("static file", "file", h.Name, "error", err)
+		c.Res.WriteHeader(http.StatusNotFound)
+		return true
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		c.Logger().Warn("static file", "file", h.Name, "error", err)
+		c.Res.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			c.Logger().Warn("static file", "file", h.Name, "error", err)
+			c.Res.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		rs = &cacheSeeker{b: data}
+	}
+	setRegisteredContentType(c, h.Name)
+	http.ServeContent(c.Res, c.Req, fi.Name(), fi.ModTime(), rs)
 	return true
 }
 
@@ -105,8 +145,38 @@ type CacheHandler struct {
 	// Data modify time.
 	ModTime time.Time
 	// Origin data.
-	Data           []byte
+	Data []byte
+	// Emit Digest header of the served data.
+	Digest bool
+	// Compressed data and error of each compression, compressed once.
 	compressedData [3][]byte
+	compressErr    [3]error
+	compressOnce   [3]sync.Once
+	// Digest of compressed data, and origin data at the last.
+	digest     [4]string
+	digestOnce [4]sync.Once
+	// Subresource integrity of origin data, set by Router.WarmStatic.
+	integrity string
+	// Hash of origin data, set by router which shares data of identical files.
+	etag string
+	// Handler of identical file whose Data is shared, compressed data is also taken from it.
+	shared *CacheHandler
+}
+
+// Set Digest header of the data of index n, n is compression or len(compressedData) for origin data.
+func (h *CacheHandler) setDigest(c *Context, n int, data []byte) {
+	if !h.Digest {
+		return
+	}
+	c.Res.Header().Set("Digest", h.digestOf(n, data))
+}
+
+// Return digest of the data of index n, it is computed once.
+func (h *CacheHandler) digestOf(n int, data []byte) string {
+	h.digestOnce[n].Do(func() {
+		h.digest[n] = Digest(data)
+	})
+	return h.digest[n]
 }
 
 // Check client compressions and response compressed data.
@@ -132,32 +202,123 @@ func (h *CacheHandler) Handle(c *Context) bool {
 		}
 	}
 	// Handler does not has client compressions.
+	h.setDigest(c, len(h.compressedData), h.Data)
+	h.setETag(c, "")
 	http.ServeContent(c.Res, c.Req, "", h.ModTime, &cacheSeeker{b: h.Data})
 	return true
 }
 
 // Compress data and response. But if compressed data is bigger than origin data, return origin data.
-// Compression is done when first called, and can not modify the compressed data by modify origin data.
+// Compression is done once when first called, concurrent first requests wait for it.
+// Modify origin data does not modify the compressed data, call Precompute after that.
 func (h *CacheHandler) serveContent(c *Context, n int) {
-	// Compress data if is empty.
-	if len(h.compressedData[n]) < 1 {
-		var buf bytes.Buffer
-		w := compressFunc[n](&buf)
-		w.Write(h.Data)
-		w.Close()
-		h.compressedData[n] = append(h.compressedData[n], buf.Bytes()...)
+	data, err := h.compressed(n)
+	if err != nil {
+		c.Logger().Error("static compress", "encoding", compressName[n], "error", err)
+		h.setDigest(c, len(h.compressedData), h.Data)
+		h.setETag(c, "")
+		http.ServeContent(c.Res, c.Req, "", h.ModTime, &cacheSeeker{b: h.Data})
+		return
 	}
 	// Response compressed data.
-	if len(h.compressedData[n]) < len(h.Data) {
-		c.Res.Header().Set("Content-Encoding", compressName[n])
-		http.ServeContent(c.Res, c.Req, "", h.ModTime, &cacheSeeker{b: h.compressedData[n]})
+	if len(data) < len(h.Data) {
+		h.setDigest(c, n, data)
+		h.setETag(c, compressName[n])
+		// Content-Encoding is set after ServeContent sets Content-Length and checks Range of compressed data.
+		w := &encodingWriter{ResponseWriter: c.Res, encoding: compressName[n]}
+		http.ServeContent(w, c.Req, "", h.ModTime, &cacheSeeker{b: data})
 		return
 	}
 	// Response origin data.
+	h.setDigest(c, len(h.compressedData), h.Data)
+	h.setETag(c, "")
 	http.ServeContent(c.Res, c.Req, "", h.ModTime, &cacheSeeker{b: h.Data})
 }
 
-// Local file into cache.
+// Return data compressed by compression n, it is compressed once.
+func (h *CacheHandler) compressed(n int) ([]byte, error) {
+	h.compressOnce[n].Do(func() {
+		if h.shared != nil {
+			h.compressedData[n], h.compressErr[n] = h.shared.compressed(n)
+			return
+		}
+		h.compressedData[n], h.compressErr[n] = compress(n, h.Data)
+	})
+	return h.compressedData[n], h.compressErr[n]
+}
+
+// Compress data by compression n.
+func compress(n int, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := compressFunc[n](&buf)
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Compress data by all compressions, so lengths are known before serving.
+// Call it before serving if Data is modified, it must not be called concurrently with serving.
+func (h *CacheHandler) Precompute() error {
+	h.discardCompressed()
+	for i := 0; i < len(h.compressedData); i++ {
+		_, err := h.compressed(i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard compressed data and digests after Data is modified, they are computed again lazily.
+func (h *CacheHandler) discardCompressed() {
+	h.compressedData = [len(h.compressedData)][]byte{}
+	h.compressErr = [len(h.compressErr)]error{}
+	h.compressOnce = [len(h.compressOnce)]sync.Once{}
+	h.digestOnce = [len(h.digestOnce)]sync.Once{}
+}
+
+// Return Content-Length of the response body of encoding, such as "gzip" or "" for origin data.
+// It is the length of origin data if compressed data is not smaller.
+// Data is compressed if it is not yet, return -1 if encoding is not supported or compression fails.
+func (h *CacheHandler) ContentLength(encoding string) int64 {
+	if encoding == "" || encoding == "identity" {
+		return int64(len(h.Data))
+	}
+	for i, name := range compressName {
+		if name != encoding {
+			continue
+		}
+		data, err := h.compressed(i)
+		if err != nil || len(data) < 1 {
+			return -1
+		}
+		if len(data) < len(h.Data) {
+			return int64(len(data))
+		}
+		return int64(len(h.Data))
+	}
+	return -1
+}
+
+// Set Content-Encoding when writing success status code.
+type encodingWriter struct {
+	http.ResponseWriter
+	encoding string
+}
+
+func (w *encodingWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusOK || statusCode == http.StatusPartialContent {
+		w.Header().Set("Content-Encoding", w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Local file into cache, data is compressed lazily, see Precompute and Router.WarmStatic.
 func CacheHandlerFromFile(file string) (*CacheHandler, error) {
 	fileInfo, err := os.Stat(file)
 	if err != nil {
@@ -170,9 +331,31 @@ func CacheHandlerFromFile(file string) (*CacheHandler, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &CacheHandler{
-		ContentType: mime.TypeByExtension(filepath.Ext(file)),
+	h := &CacheHandler{
+		ContentType: detectContentType(filepath.ToSlash(file), data),
 		ModTime:     fileInfo.ModTime(),
 		Data:        data,
-	}, nil
+	}
+	return h, nil
+}
+
+// File in fsys into cache, data is compressed lazily, see Precompute and Router.WarmStatic.
+func CacheHandlerFromFS(fsys fs.FS, name string) (*CacheHandler, error) {
+	fileInfo, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	h := &CacheHandler{
+		ContentType: detectContentType(name, data),
+		ModTime:     fileInfo.ModTime(),
+		Data:        data,
+	}
+	return h, nil
 }
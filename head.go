@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Discard body writes for HEAD requests, and set Content-Length by counting body size.
+// Status code and header are delayed until Finish or Flush is called.
+type HeadWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	wrote  bool
+}
+
+// Return a HeadWriter wraps w, Finish must be called after handler.
+func NewHeadWriter(w http.ResponseWriter) *HeadWriter {
+	h := new(HeadWriter)
+	h.reset(w)
+	return h
+}
+
+func (w *HeadWriter) reset(res http.ResponseWriter) {
+	w.ResponseWriter = res
+	w.status = 0
+	w.size = 0
+	w.wrote = false
+}
+
+func (w *HeadWriter) WriteHeader(statusCode int) {
+	// Informational response is sent immediately.
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// Discard b, only count its size.
+func (w *HeadWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += int64(len(b))
+	return len(b), nil
+}
+
+func (w *HeadWriter) writeHeader() {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if h.Get("Content-Length") == "" && w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		w.status >= http.StatusOK && (w.size > 0 || w.status == http.StatusOK) {
+		h.Set("Content-Length", strconv.FormatInt(w.size, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Implements http.Flusher, header is written and Content-Length is the size so far.
+func (w *HeadWriter) Flush() {
+	w.writeHeader()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Write status code and header with Content-Length.
+func (w *HeadWriter) Finish() {
+	w.writeHeader()
+}
+
+// Use by http.ResponseController.
+func (w *HeadWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// If enable, HEAD requests that do not match a HEAD route are handled by the GET route,
+// and response body is discarded by HeadWriter.
+func (r *Router) SetHeadFallback(enable bool) {
+	r.headFallback = enable
+}
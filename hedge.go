@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of latency samples used to compute hedge delay.
+const hedgeSamples = 128
+
+// Hedged request options of ProxyHandler.
+type proxyHedge struct {
+	percentile float64
+	maxDelay   time.Duration
+	mutex      sync.Mutex
+	samples    [hedgeSamples]time.Duration
+	n          int
+}
+
+// Enable hedged requests for idempotent requests without body (GET, HEAD and OPTIONS).
+// If the upstream does not response within the percentile (such as 0.95) of recent latencies,
+// a second request is sent to another upstream, the first response is used and the other is canceled.
+// maxDelay is the delay before enough latencies are collected, and the max delay.
+// percentile <= 0 disables hedging.
+func (h *ProxyHandler) SetHedging(percentile float64, maxDelay time.Duration) {
+	if percentile <= 0 {
+		h.hedge = nil
+		return
+	}
+	if percentile > 1 {
+		percentile = 1
+	}
+	h.hedge = &proxyHedge{percentile: percentile, maxDelay: maxDelay}
+}
+
+func (p *proxyHedge) observe(d time.Duration) {
+	p.mutex.Lock()
+	p.samples[p.n%hedgeSamples] = d
+	p.n++
+	p.mutex.Unlock()
+}
+
+// Return delay before hedged request.
+func (p *proxyHedge) delay() time.Duration {
+	p.mutex.Lock()
+	n := p.n
+	if n > hedgeSamples {
+		n = hedgeSamples
+	}
+	// Too few samples.
+	if n < 10 {
+		p.mutex.Unlock()
+		return p.maxDelay
+	}
+	s := make([]time.Duration, n)
+	copy(s, p.samples[:n])
+	p.mutex.Unlock()
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	d := s[int(float64(n-1)*p.percentile)]
+	if p.maxDelay > 0 && d > p.maxDelay {
+		d = p.maxDelay
+	}
+	return d
+}
+
+// Return true if request can be hedged.
+func canHedge(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0
+	}
+	return false
+}
+
+// Implements http.RoundTripper, send hedged requests of one proxy request.
+type hedgeTransport struct {
+	handler *ProxyHandler
+	c       *Context
+	// Inbound request.
+	in *http.Request
+	// Upstream index of the first and hedged request, hedge is -1 if not sent.
+	primary int
+	hedge   int
+	// Upstream index of used response, -1 if all failed.
+	winner int
+}
+
+type hedgeResult struct {
+	i   int
+	res *http.Response
+	err error
+}
+
+// Call cancel when body is closed.
+type hedgeBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *hedgeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (t *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.handler.transport(t.c)
+	ch := make(chan hedgeResult, 2)
+	var cancel [2]context.CancelFunc
+	send := func(n, i int, r *http.Request) {
+		var ctx context.Context
+		ctx, cancel[n] = context.WithCancel(r.Context())
+		r = r.WithContext(ctx)
+		go func() {
+			start := time.Now()
+			res, err := transport.RoundTrip(r)
+			if err == nil {
+				t.handler.hedge.observe(time.Since(start))
+			}
+			ch <- hedgeResult{i: n, res: res, err: err}
+		}()
+	}
+	t.hedge = -1
+	t.winner = -1
+	send(0, t.primary, req)
+	timer := time.NewTimer(t.handler.hedge.delay())
+	defer timer.Stop()
+	pending := 1
+	var err error
+	for pending > 0 {
+		select {
+		case r := <-ch:
+			pending--
+			if r.err != nil {
+				err = r.err
+				cancel[r.i]()
+				continue
+			}
+			if r.i == 0 {
+				t.winner = t.primary
+			} else {
+				t.winner = t.hedge
+			}
+			// Cancel the other.
+			if pending > 0 {
+				cancel[1-r.i]()
+				go func() {
+					r := <-ch
+					if r.res != nil {
+						r.res.Body.Close()
+					}
+				}()
+			}
+			r.res.Body = &hedgeBody{ReadCloser: r.res.Body, cancel: cancel[r.i]}
+			return r.res, nil
+		case <-timer.C:
+			if t.hedge >= 0 {
+				continue
+			}
+			t.hedge = t.handler.hedgeUpstream(t.primary)
+			if t.hedge < 0 {
+				continue
+			}
+			out := req.Clone(req.Context())
+			u := *t.in.URL
+			out.URL = &u
+			pr := &httputil.ProxyRequest{In: t.in, Out: out}
+			pr.SetURL(t.handler.Upstream[t.hedge])
+			pending++
+			send(1, t.hedge, out)
+		}
+	}
+	return nil, err
+}
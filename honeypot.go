@@ -0,0 +1,110 @@
+package router
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IP deny list, entries expire after their duration.
+// Use Handle as a before HandlerFunc.
+type DenyList struct {
+	mutex sync.Mutex
+	// Expire time of ip, zero time means forever.
+	ip map[string]time.Time
+}
+
+// Return a new DenyList.
+func NewDenyList() *DenyList {
+	d := new(DenyList)
+	d.ip = make(map[string]time.Time)
+	return d
+}
+
+// Deny ip for duration d, 0 means forever.
+func (l *DenyList) Deny(ip string, d time.Duration) {
+	var expire time.Time
+	if d > 0 {
+		expire = time.Now().Add(d)
+	}
+	l.mutex.Lock()
+	l.ip[ip] = expire
+	l.mutex.Unlock()
+}
+
+// Remove ip from the list.
+func (l *DenyList) Allow(ip string) {
+	l.mutex.Lock()
+	delete(l.ip, ip)
+	l.mutex.Unlock()
+}
+
+// Return true if ip is denied and not expired.
+func (l *DenyList) Denied(ip string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	expire, ok := l.ip[ip]
+	if !ok {
+		return false
+	}
+	if !expire.IsZero() && time.Now().After(expire) {
+		delete(l.ip, ip)
+		return false
+	}
+	return true
+}
+
+// Can be use as HandlerFunc, response 403 if client ip is denied.
+func (l *DenyList) Handle(c *Context) bool {
+	if l.Denied(c.ClientIP()) {
+		c.Res.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// Options of honeypot routes.
+type Honeypot struct {
+	// Called when a honeypot route is hit.
+	OnHit func(*Context)
+	// If not nil, the client ip is added to it for DenyFor.
+	DenyList *DenyList
+	// Deny duration, 0 means forever.
+	DenyFor time.Duration
+	// Response status code, default is 404, looks like nothing there.
+	Status int
+}
+
+// Set options of honeypot routes added by AddHoneypot.
+func (r *Router) SetHoneypot(h Honeypot) {
+	r.honeypot = h
+}
+
+// Add decoy routes such as "/wp-login.php" for all methods.
+// Hitters are reported to Honeypot.OnHit and added to Honeypot.DenyList.
+// Either all routes are added or none, see AddRoutes.
+func (r *Router) AddHoneypot(paths ...string) error {
+	specs := make([]RouteSpec, 0, len(paths)*len(methods))
+	for _, p := range paths {
+		for _, m := range methods {
+			specs = append(specs, RouteSpec{Method: m, Path: p, Handler: []HandlerFunc{r.handleHoneypot}})
+		}
+	}
+	return r.AddRoutes(specs)
+}
+
+func (r *Router) handleHoneypot(c *Context) bool {
+	h := &r.honeypot
+	if h.OnHit != nil {
+		h.OnHit(c)
+	}
+	if h.DenyList != nil {
+		h.DenyList.Deny(c.ClientIP(), h.DenyFor)
+	}
+	status := h.Status
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	c.Res.WriteHeader(status)
+	return true
+}
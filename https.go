@@ -0,0 +1,112 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Redirect HTTP requests to HTTPS, and requests of non-canonical hosts to the canonical host, such as "www.example.com" to "example.com".
+// Use Handle as a before handler.
+type HTTPSRedirect struct {
+	// Canonical host, such as "example.com", empty keeps the host of request.
+	Host string
+	// Port of HTTPS, empty is 443.
+	Port string
+	// Status code of redirect, 0 is 301 for GET and HEAD, 308 for other methods.
+	Code int
+	// Return true if X-Forwarded-Proto of request is set by a trusted proxy, nil ignores the header.
+	// TrustedProxies can be used.
+	TrustForwarded func(c *Context) bool
+}
+
+// Can be use as HandlerFunc, return false if request is redirected.
+func (h *HTTPSRedirect) Handle(c *Context) bool {
+	host, port, err := net.SplitHostPort(c.Req.Host)
+	if err != nil {
+		host, port = c.Req.Host, ""
+	}
+	secure := h.secure(c)
+	canonical := h.Host == "" || strings.EqualFold(host, h.Host)
+	if secure && canonical {
+		return true
+	}
+	if h.Host != "" {
+		host = h.Host
+	}
+	// Keep port of HTTPS request behind the canonical host.
+	if !secure {
+		port = h.Port
+	}
+	if port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	c.Res.Header().Set("Location", "https://"+host+c.Req.URL.RequestURI())
+	code := h.Code
+	if code == 0 {
+		code = http.StatusPermanentRedirect
+		if c.Req.Method == http.MethodGet || c.Req.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+	}
+	c.Res.WriteHeader(code)
+	return false
+}
+
+// Return true if request is HTTPS.
+func (h *HTTPSRedirect) secure(c *Context) bool {
+	if c.Req.TLS != nil {
+		return true
+	}
+	if h.TrustForwarded == nil || !h.TrustForwarded(c) {
+		return false
+	}
+	proto, _, _ := strings.Cut(c.Req.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// Return a function returns true if client IP of request is in networks, such as "10.0.0.0/8" or "127.0.0.1".
+func TrustedProxies(networks ...string) (func(c *Context) bool, error) {
+	var nets []*net.IPNet
+	for _, s := range networks {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %s", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return func(c *Context) bool {
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// Return a Server listen on addr, redirect all requests by h, such as ":80" beside the HTTPS server.
+// Example: go NewRedirectServer(":80", h).Run("", "")
+func NewRedirectServer(addr string, h *HTTPSRedirect) *Server {
+	r := New()
+	r.SetBefore(h.Handle)
+	r.SetNotfound(Notfound)
+	return NewServer(addr, r)
+}
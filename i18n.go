@@ -0,0 +1,103 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Add localized aliases of route, such as {"en": "/en/about", "de": "/de/ueber-uns"}.
+// Each alias is added as a route of method, it records its locale on Context and calls handlers of route.
+// Aliases must have the same count of params as route, see Context.URLFor for reverse routing.
+func (r *Router) AddAliases(method string, route *Route, aliases map[string]string) error {
+	locales := make([]string, 0, len(aliases))
+	for locale := range aliases {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	params := len(route.Params())
+	specs := make([]RouteSpec, 0, len(locales))
+	for _, locale := range locales {
+		if n := len(splitParams(aliases[locale])); n != params {
+			return fmt.Errorf("alias '%s' has %d params, route '%s' has %d", aliases[locale], n, route.path, params)
+		}
+		specs = append(specs, RouteSpec{Method: method, Path: aliases[locale], Handler: []HandlerFunc{aliasHandler(route, locale)}})
+	}
+	// All aliases are added or none.
+	err := r.AddRoutes(specs)
+	if err != nil {
+		return err
+	}
+	paths := make(map[string]string)
+	for _, locale := range locales {
+		paths[locale] = r.Route(method, aliases[locale]).path
+	}
+	r.mutex.Lock()
+	if route.meta.aliases == nil {
+		route.meta.aliases = paths
+	} else {
+		for locale, path := range paths {
+			route.meta.aliases[locale] = path
+		}
+	}
+	r.mutex.Unlock()
+	return nil
+}
+
+// Return param segments of route path, such as [":id", "*"] of "/a/:id/*".
+func splitParams(_path string) []string {
+	var params []string
+	for _, s := range strings.Split(path.Clean("/"+_path), "/") {
+		if s != "" && (s[0] == ':' || s[0] == '*') {
+			params = append(params, s)
+		}
+	}
+	return params
+}
+
+// Return handler of alias, it sets locale and calls handlers of route.
+func aliasHandler(route *Route, locale string) HandlerFunc {
+	return func(c *Context) bool {
+		c.locale = locale
+		return route.Handle(c)
+	}
+}
+
+// Return locale of request, it is set by alias routes or SetLocale.
+func (c *Context) Locale() string {
+	return c.locale
+}
+
+// Set locale of request, such as the one negotiated by Accept-Language, it is used by URLFor.
+func (c *Context) SetLocale(locale string) {
+	c.locale = locale
+}
+
+// Return path of route with params filled in order, use the alias of Locale if route has one.
+// Params of ":" are escaped, params of "*" are not.
+// Example: "/de/produkte/1" of route "/products/:id" with alias {"de": "/de/produkte/:id"}.
+func (c *Context) URLFor(route *Route, params ...string) string {
+	path := route.path
+	if c.router != nil && c.router.rlock() {
+		defer c.router.mutex.RUnlock()
+	}
+	if s, ok := route.meta.aliases[c.locale]; ok {
+		path = s
+	}
+	part := strings.Split(path, "/")
+	n := 0
+	for i, s := range part {
+		if (s != ":" && s != "*") || n >= len(params) {
+			continue
+		}
+		if s == ":" {
+			part[i] = url.PathEscape(params[n])
+		} else {
+			part[i] = params[n]
+		}
+		n++
+	}
+	return strings.Join(part, "/")
+}
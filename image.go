@@ -0,0 +1,227 @@
+package router
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serve images in fs.FS with resize and crop by query "?w=200&h=200".
+// If both w and h are given, image is scaled to cover the box and center cropped,
+// if only one is given, the aspect ratio is kept.
+// Transformed images are cached in a LRUCache.
+type ImageHandler struct {
+	FS fs.FS
+	// Max value of w and h, also the bound of the side computed by aspect ratio.
+	MaxWidth  int
+	MaxHeight int
+	// Max pixels of source image, larger ones are rejected before decoding, 0 is no limit.
+	MaxSourcePixels int
+	// JPEG quality.
+	Quality int
+	cache   *LRUCache
+}
+
+// Transformed image in cache.
+type imageCache struct {
+	contentType string
+	modTime     time.Time
+	data        []byte
+}
+
+// Return an ImageHandler serves fsys, cacheSize is the max number of cached images.
+func NewImageHandler(fsys fs.FS, cacheSize int) *ImageHandler {
+	h := new(ImageHandler)
+	h.FS = fsys
+	h.MaxWidth = 2048
+	h.MaxHeight = 2048
+	h.MaxSourcePixels = 50 << 20
+	h.Quality = 85
+	h.cache = NewLRUCache(cacheSize)
+	return h
+}
+
+// Can be use as HandlerFunc, c.DecodedWildcardPath() is the file name, such as route "/images/*".
+func (h *ImageHandler) Handle(c *Context) bool {
+	name := strings.TrimPrefix(path.Clean("/"+c.DecodedWildcardPath()), "/")
+	w, errW := imageSize(c.Req.URL.Query().Get("w"), h.MaxWidth)
+	hh, errH := imageSize(c.Req.URL.Query().Get("h"), h.MaxHeight)
+	if errW || errH {
+		c.Res.WriteHeader(http.StatusBadRequest)
+		return true
+	}
+	// Origin image.
+	if w == 0 && hh == 0 {
+		fh := FSFileHandler{FS: h.FS, Name: name}
+		return fh.Handle(c)
+	}
+	key := name + "?w=" + strconv.Itoa(w) + "&h=" + strconv.Itoa(hh)
+	v, ok := h.cache.Get(key)
+	if !ok {
+		ic, status := h.transform(c, name, w, hh)
+		if ic == nil {
+			c.Res.WriteHeader(status)
+			return true
+		}
+		h.cache.Set(key, ic)
+		v = ic
+	}
+	ic := v.(*imageCache)
+	c.Res.Header().Set("Content-Type", ic.contentType)
+	http.ServeContent(c.Res, c.Req, "", ic.modTime, &cacheSeeker{b: ic.data})
+	return true
+}
+
+// Parse size in query, 0 means not set.
+func imageSize(s string, max int) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || (max > 0 && n > max) {
+		return 0, true
+	}
+	return n, false
+}
+
+// Load, resize and encode image, return nil and status code if failed.
+func (h *ImageHandler) transform(c *Context, name string, w, hh int) (*imageCache, int) {
+	fi, err := fs.Stat(h.FS, name)
+	if err != nil || fi.IsDir() {
+		return nil, http.StatusNotFound
+	}
+	data, err := fs.ReadFile(h.FS, name)
+	if err != nil {
+		c.Logger().Warn("image", "file", name, "error", err)
+		return nil, http.StatusInternalServerError
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType
+	}
+	if h.MaxSourcePixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(h.MaxSourcePixels) {
+		c.Logger().Warn("image too large", "file", name, "width", cfg.Width, "height", cfg.Height)
+		return nil, http.StatusUnsupportedMediaType
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType
+	}
+	dst := resizeImage(src, w, hh, h.MaxWidth, h.MaxHeight)
+	var buf bytes.Buffer
+	ic := &imageCache{modTime: fi.ModTime()}
+	switch format {
+	case "jpeg":
+		ic.contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: h.Quality})
+	case "gif":
+		ic.contentType = "image/gif"
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		ic.contentType = "image/png"
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		c.Logger().Error("image encode", "file", name, "error", err)
+		return nil, http.StatusInternalServerError
+	}
+	ic.data = buf.Bytes()
+	return ic, 0
+}
+
+// Scale src to w x h with bilinear interpolation.
+// If both are given, src is center cropped to the aspect ratio first.
+// If only one is given, the other one is computed and bounded by maxW or maxH, 0 is no bound.
+func resizeImage(src image.Image, w, h, maxW, maxH int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw < 1 || sh < 1 {
+		return src
+	}
+	switch {
+	case w == 0:
+		w = sw * h / sh
+		if maxW > 0 && w > maxW {
+			w, h = maxW, sh*maxW/sw
+		}
+	case h == 0:
+		h = sh * w / sw
+		if maxH > 0 && h > maxH {
+			w, h = sw*maxH/sh, maxH
+		}
+	default:
+		// Crop, at least 1 pixel is kept for extreme aspect ratio.
+		if sw*h > sh*w {
+			cw := sh * w / h
+			if cw < 1 {
+				cw = 1
+			}
+			b.Min.X += (sw - cw) / 2
+			b.Max.X = b.Min.X + cw
+		} else {
+			ch := sw * h / w
+			if ch < 1 {
+				ch = 1
+			}
+			b.Min.Y += (sh - ch) / 2
+			b.Max.Y = b.Min.Y + ch
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh = b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		fy := (float64(y)+0.5)*float64(sh)/float64(h) - 0.5
+		y0, wy := splitFloat(fy, sh)
+		for x := 0; x < w; x++ {
+			fx := (float64(x)+0.5)*float64(sw)/float64(w) - 0.5
+			x0, wx := splitFloat(fx, sw)
+			x1, y1 := x0+1, y0+1
+			if x1 >= sw {
+				x1 = sw - 1
+			}
+			if y1 >= sh {
+				y1 = sh - 1
+			}
+			i00 := rgba.PixOffset(x0, y0)
+			i10 := rgba.PixOffset(x1, y0)
+			i01 := rgba.PixOffset(x0, y1)
+			i11 := rgba.PixOffset(x1, y1)
+			j := dst.PixOffset(x, y)
+			for k := 0; k < 4; k++ {
+				top := float64(rgba.Pix[i00+k])*(1-wx) + float64(rgba.Pix[i10+k])*wx
+				bottom := float64(rgba.Pix[i01+k])*(1-wx) + float64(rgba.Pix[i11+k])*wx
+				dst.Pix[j+k] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// Return integer part of f in [0,n) and the fraction.
+func splitFloat(f float64, n int) (int, float64) {
+	if f < 0 {
+		return 0, 0
+	}
+	i := int(f)
+	if i >= n-1 {
+		return n - 1, 0
+	}
+	return i, f - float64(i)
+}
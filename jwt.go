@@ -0,0 +1,291 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errInvalidToken   = errors.New("jwt: invalid token")
+	errTokenExpired   = errors.New("jwt: token expired")
+	errTokenRevoked   = errors.New("jwt: token revoked")
+	errNotRefreshType = errors.New("jwt: not a refresh token")
+)
+
+// Header of HS256 token, it is constant.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims of JWT.
+type Claims map[string]interface{}
+
+// Return string claim.
+func (c Claims) String(key string) string {
+	s, _ := c[key].(string)
+	return s
+}
+
+// Return "sub" claim.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Return numeric date claim such as "exp".
+func (c Claims) Time(key string) time.Time {
+	switch v := c[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int64:
+		return time.Unix(v, 0)
+	case json.Number:
+		n, _ := v.Int64()
+		return time.Unix(n, 0)
+	}
+	return time.Time{}
+}
+
+// Store of revoked token ids.
+type RevocationList interface {
+	// Revoke id, it can be forgotten after until.
+	Revoke(ctx context.Context, id string, until time.Time) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	// Revoke id atomically if it is not revoked, return false if it is already revoked.
+	TryRevoke(ctx context.Context, id string, until time.Time) (bool, error)
+}
+
+// In-memory RevocationList.
+type MemoryRevocationList struct {
+	mutex sync.Mutex
+	ids   map[string]time.Time
+}
+
+func (l *MemoryRevocationList) Revoke(ctx context.Context, id string, until time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.revoke(id, until)
+	return nil
+}
+
+func (l *MemoryRevocationList) TryRevoke(ctx context.Context, id string, until time.Time) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.ids[id]; ok {
+		return false, nil
+	}
+	l.revoke(id, until)
+	return true, nil
+}
+
+// Remove expired ids and add id, must hold the lock.
+func (l *MemoryRevocationList) revoke(id string, until time.Time) {
+	if l.ids == nil {
+		l.ids = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for k, t := range l.ids {
+		if now.After(t) {
+			delete(l.ids, k)
+		}
+	}
+	l.ids[id] = until
+}
+
+func (l *MemoryRevocationList) IsRevoked(ctx context.Context, id string) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, ok := l.ids[id]
+	return ok, nil
+}
+
+// Issue and verify HS256 JWT, and rotate refresh tokens.
+type JWT struct {
+	// HMAC key.
+	Key []byte
+	// Value of "iss" claim, checked when verifying if it is not empty.
+	Issuer string
+	// Lifetime of refresh token.
+	RefreshTTL time.Duration
+	// If it is nil, tokens can not be revoked and refresh token reuse can not be detected.
+	Revocations RevocationList
+}
+
+// Return a JWT with key and default settings.
+func NewJWT(key []byte) *JWT {
+	j := new(JWT)
+	j.Key = key
+	j.RefreshTTL = 30 * 24 * time.Hour
+	j.Revocations = new(MemoryRevocationList)
+	return j
+}
+
+func newTokenID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (j *JWT) sign(s string) string {
+	h := hmac.New(sha256.New, j.Key)
+	h.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Return a signed token expires after ttl.
+// Claims "iat", "exp", "jti" and "iss" are set if not exist.
+func (j *JWT) IssueToken(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c := make(Claims, len(claims)+4)
+	for k, v := range claims {
+		c[k] = v
+	}
+	c["iat"] = now.Unix()
+	c["exp"] = now.Add(ttl).Unix()
+	if _, ok := c["jti"]; !ok {
+		c["jti"] = newTokenID()
+	}
+	if _, ok := c["iss"]; !ok && j.Issuer != "" {
+		c["iss"] = j.Issuer
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	s := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return s + "." + j.sign(s), nil
+}
+
+// Verify signature, "exp", "nbf", "iss" and revocation, return claims.
+func (j *JWT) VerifyToken(ctx context.Context, token string) (Claims, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !strings.HasPrefix(token, jwtHeader+".") {
+		return nil, errInvalidToken
+	}
+	if !hmac.Equal([]byte(j.sign(token[:i])), []byte(token[i+1:])) {
+		return nil, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[len(jwtHeader)+1 : i])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims Claims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	now := time.Now()
+	if exp := claims.Time("exp"); exp.IsZero() || !now.Before(exp) {
+		return nil, errTokenExpired
+	}
+	if nbf := claims.Time("nbf"); !nbf.IsZero() && now.Before(nbf) {
+		return nil, errInvalidToken
+	}
+	if j.Issuer != "" && claims.String("iss") != j.Issuer {
+		return nil, errInvalidToken
+	}
+	if j.Revocations != nil {
+		for _, key := range []string{"jti", "fam"} {
+			id := claims.String(key)
+			if id == "" {
+				continue
+			}
+			revoked, err := j.Revocations.IsRevoked(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				return nil, errTokenRevoked
+			}
+		}
+	}
+	return claims, nil
+}
+
+// Revoke the token by its "jti" until it expires.
+func (j *JWT) RevokeToken(ctx context.Context, claims Claims) error {
+	if j.Revocations == nil {
+		return nil
+	}
+	return j.Revocations.Revoke(ctx, claims.String("jti"), claims.Time("exp"))
+}
+
+// Return a refresh token of subject, it starts a new token family.
+func (j *JWT) IssueRefreshToken(subject string) (string, error) {
+	return j.IssueToken(Claims{"sub": subject, "typ": "refresh", "fam": newTokenID()}, j.RefreshTTL)
+}
+
+// Verify refresh token, revoke it and return a new one in the same family.
+// If a revoked refresh token is reused, the whole family is revoked.
+// Check and revoke are atomic by RevocationList.TryRevoke, so concurrent rotations of a token are also reuse.
+func (j *JWT) RotateRefreshToken(ctx context.Context, token string) (string, Claims, error) {
+	claims, err := j.VerifyToken(ctx, token)
+	if err == errTokenRevoked {
+		// Reuse detected, maybe stolen.
+		claims, err = j.verifyIgnoreRevoked(token)
+		if err == nil {
+			j.revokeFamily(ctx, claims)
+		}
+		return "", nil, errTokenRevoked
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if claims.String("typ") != "refresh" {
+		return "", nil, errNotRefreshType
+	}
+	if j.Revocations != nil {
+		ok, err := j.Revocations.TryRevoke(ctx, claims.String("jti"), claims.Time("exp"))
+		if err != nil {
+			return "", nil, err
+		}
+		if !ok {
+			// Rotated concurrently.
+			j.revokeFamily(ctx, claims)
+			return "", nil, errTokenRevoked
+		}
+	}
+	newToken, err := j.IssueToken(Claims{"sub": claims.Subject(), "typ": "refresh", "fam": claims.String("fam")}, j.RefreshTTL)
+	if err != nil {
+		return "", nil, err
+	}
+	return newToken, claims, nil
+}
+
+// Revoke token family of refresh token claims.
+func (j *JWT) revokeFamily(ctx context.Context, claims Claims) {
+	if fam := claims.String("fam"); fam != "" {
+		j.Revocations.Revoke(ctx, fam, time.Now().Add(j.RefreshTTL))
+	}
+}
+
+func (j *JWT) verifyIgnoreRevoked(token string) (Claims, error) {
+	jj := *j
+	jj.Revocations = nil
+	return jj.VerifyToken(context.Background(), token)
+}
+
+// Verify Bearer token and save claims to context, response 401 if failed.
+// Can be use as HandlerFunc.
+func (j *JWT) Handle(c *Context) bool {
+	claims, err := j.VerifyToken(c.Req.Context(), c.BearerToken())
+	if err != nil || claims.String("typ") == "refresh" {
+		c.Res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Res.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	c.claims = claims
+	return true
+}
+
+// Return claims saved by authentication handler, nil if not authenticated.
+func (c *Context) Claims() Claims {
+	return c.claims
+}
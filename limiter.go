@@ -0,0 +1,115 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Limit concurrent requests, with a bounded wait queue.
+// When the queue is full, or waiting timeout, it responses 503 with Retry-After.
+type Limiter struct {
+	// Max wait time in queue, 0 means wait until the client is gone.
+	Timeout time.Duration
+	// Value of Retry-After header, in seconds.
+	RetryAfter time.Duration
+	// Concurrent slots.
+	sem chan struct{}
+	// Max and current waiting requests.
+	queue   int64
+	waiting int64
+}
+
+// Return a Limiter allows concurrency requests running and queue requests waiting.
+func NewLimiter(concurrency, queue int, timeout time.Duration) *Limiter {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	l := new(Limiter)
+	l.Timeout = timeout
+	l.RetryAfter = time.Second
+	l.sem = make(chan struct{}, concurrency)
+	l.queue = int64(queue)
+	return l
+}
+
+// Return number of running and waiting requests.
+func (l *Limiter) Load() (running, waiting int) {
+	return len(l.sem), int(atomic.LoadInt64(&l.waiting))
+}
+
+// Try to acquire a slot, return false if queue is full, timeout or client is gone.
+func (l *Limiter) acquire(c *Context) bool {
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	default:
+	}
+	if atomic.AddInt64(&l.waiting, 1) > l.queue {
+		atomic.AddInt64(&l.waiting, -1)
+		return false
+	}
+	defer atomic.AddInt64(&l.waiting, -1)
+	// Queue depth of route and wait time, see RouteStats.
+	if rc := c.statsCounter; rc != nil {
+		atomic.AddInt64(&rc.waiting, 1)
+		defer atomic.AddInt64(&rc.waiting, -1)
+	}
+	start := time.Now()
+	defer func() {
+		c.queued = true
+		c.queueWait += time.Since(start)
+	}()
+	var timeout <-chan time.Time
+	if l.Timeout > 0 {
+		timer := time.NewTimer(l.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-timeout:
+		return false
+	case <-c.Req.Context().Done():
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.sem
+}
+
+// Response 503 with Retry-After.
+func (l *Limiter) reject(c *Context) {
+	if l.RetryAfter > 0 {
+		c.Res.Header().Set("Retry-After", strconv.Itoa(int((l.RetryAfter+time.Second-1)/time.Second)))
+	}
+	c.Res.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// Return a HandlerFunc that runs funcs in a slot, used for per-route limit.
+func (l *Limiter) Wrap(funcs ...HandlerFunc) HandlerFunc {
+	return func(c *Context) bool {
+		if !l.acquire(c) {
+			l.reject(c)
+			return false
+		}
+		defer l.release()
+		for _, h := range funcs {
+			if !h(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Set a global Limiter applied to all requests, nil to disable.
+func (r *Router) SetLimiter(limiter *Limiter) {
+	r.limiter = limiter
+}
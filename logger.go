@@ -0,0 +1,129 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Structured logger used by router, args are key-value pairs like log/slog.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+	// Return a Logger that includes the given attributes in each output.
+	With(args ...interface{}) Logger
+}
+
+// Return a Logger use l. If l is nil, use slog.Default().
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{Logger: l}
+}
+
+// Logger of router which has no Logger set, it uses slog.Default() of each call.
+type defaultLogger struct{}
+
+func (defaultLogger) Debug(msg string, args ...interface{}) { slog.Default().Debug(msg, args...) }
+func (defaultLogger) Info(msg string, args ...interface{})  { slog.Default().Info(msg, args...) }
+func (defaultLogger) Warn(msg string, args ...interface{})  { slog.Default().Warn(msg, args...) }
+func (defaultLogger) Error(msg string, args ...interface{}) { slog.Default().Error(msg, args...) }
+
+func (defaultLogger) With(args ...interface{}) Logger {
+	return NewSlogLogger(slog.Default().With(args...))
+}
+
+// Implements Logger by *slog.Logger.
+type slogLogger struct {
+	*slog.Logger
+}
+
+func (l *slogLogger) With(args ...interface{}) Logger {
+	return &slogLogger{Logger: l.Logger.With(args...)}
+}
+
+// Implements io.Writer, used by log.Logger of http.Server.
+type logWriter struct {
+	logger Logger
+}
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w.logger.Error(strings.TrimSpace(string(b)))
+	return len(b), nil
+}
+
+// Header name of request id.
+var RequestIDHeader = "X-Request-Id"
+
+// Return request id from header[RequestIDHeader].
+// If it is empty, generate a random one and set to response header.
+func (c *Context) RequestID() string {
+	if c.requestID == "" {
+		c.requestID = c.Req.Header.Get(RequestIDHeader)
+		if c.requestID == "" {
+			c.requestID = newRequestID()
+			c.Res.Header().Set(RequestIDHeader, c.requestID)
+		}
+	}
+	return c.requestID
+}
+
+// Return a random request id, crypto/rand is safe for concurrent requests.
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Return router's Logger with request id and route pattern attached.
+func (c *Context) Logger() Logger {
+	if c.logger == nil {
+		var l Logger
+		if c.router != nil {
+			l = c.router.Logger()
+		} else {
+			l = NewSlogLogger(nil)
+		}
+		args := []interface{}{"request_id", c.RequestID()}
+		if c.route != nil {
+			args = append(args, "route", c.route.path)
+		}
+		c.logger = l.With(args...)
+	}
+	return c.logger
+}
+
+// Log method, path, status, size and latency of request. Use it in after chain.
+// If router has a Redactor and request body is captured by Recorder, the masked body is logged too.
+// Requests of routes are sampled by Route.SetLogSampling.
+func AccessLog(c *Context) bool {
+	if c.route != nil && !c.route.sampleLog() {
+		return true
+	}
+	args := []interface{}{
+		"method", c.Req.Method,
+		"path", c.Req.URL.Path,
+		"status", c.Status(),
+		"size", c.Size(),
+		"latency", time.Since(c.start),
+		"remote", c.Req.RemoteAddr,
+	}
+	if c.router.redactor != nil && len(c.recordBody) > 0 {
+		args = append(args, "body", string(c.router.redactor.Redact(c.recordBody)))
+	}
+	c.Logger().Info("access", args...)
+	return true
+}
+
+// Log static file response error.
+func logStaticError(c *Context, file string) {
+	if c.res.status == http.StatusNotFound || c.res.status >= http.StatusInternalServerError {
+		c.Logger().Warn("static file", "file", file, "status", c.res.status)
+	}
+}
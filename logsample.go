@@ -0,0 +1,119 @@
+package router
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Decide whether a log line is written, key identifies identical lines by level, route and message.
+type LogSampler interface {
+	Sample(key string) bool
+}
+
+// Write lines with probability in range [0,1].
+type ProbabilitySampler float64
+
+func (s ProbabilitySampler) Sample(key string) bool {
+	return s > 0 && rand.Float64() < float64(s)
+}
+
+// Write the first N identical lines in each interval, drop the rest.
+type FirstNSampler struct {
+	n        int
+	interval time.Duration
+	mutex    sync.Mutex
+	start    time.Time
+	count    map[string]int
+}
+
+// Return a FirstNSampler writes n identical lines per interval.
+func NewFirstNSampler(n int, interval time.Duration) *FirstNSampler {
+	s := new(FirstNSampler)
+	s.n = n
+	s.interval = interval
+	s.count = make(map[string]int)
+	return s
+}
+
+func (s *FirstNSampler) Sample(key string) bool {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.start) >= s.interval {
+		s.start = now
+		for k := range s.count {
+			delete(s.count, k)
+		}
+	}
+	s.count[key]++
+	return s.count[key] <= s.n
+}
+
+// Samplers of SamplingLogger.
+type LogSampling struct {
+	// Sampler of level "debug", "info", "warn" and "error", no sampling if not found.
+	Levels map[string]LogSampler
+	// Sampler of route pattern, such as "/users/:", it overrides Levels.
+	// Route is known by the "route" attribute added by c.Logger().
+	Routes map[string]LogSampler
+}
+
+// Implements Logger, drop lines by samplers.
+type samplingLogger struct {
+	logger   Logger
+	sampling *LogSampling
+	route    string
+}
+
+// Return a Logger writes lines to l sampled by sampling.
+func NewSamplingLogger(l Logger, sampling *LogSampling) Logger {
+	return &samplingLogger{logger: l, sampling: sampling}
+}
+
+func (l *samplingLogger) sample(level, msg string) bool {
+	s, ok := l.sampling.Routes[l.route]
+	if !ok || l.route == "" {
+		s, ok = l.sampling.Levels[level]
+		if !ok {
+			return true
+		}
+	}
+	return s.Sample(level + "\x00" + l.route + "\x00" + msg)
+}
+
+func (l *samplingLogger) Debug(msg string, args ...interface{}) {
+	if l.sample("debug", msg) {
+		l.logger.Debug(msg, args...)
+	}
+}
+
+func (l *samplingLogger) Info(msg string, args ...interface{}) {
+	if l.sample("info", msg) {
+		l.logger.Info(msg, args...)
+	}
+}
+
+func (l *samplingLogger) Warn(msg string, args ...interface{}) {
+	if l.sample("warn", msg) {
+		l.logger.Warn(msg, args...)
+	}
+}
+
+func (l *samplingLogger) Error(msg string, args ...interface{}) {
+	if l.sample("error", msg) {
+		l.logger.Error(msg, args...)
+	}
+}
+
+func (l *samplingLogger) With(args ...interface{}) Logger {
+	n := &samplingLogger{logger: l.logger.With(args...), sampling: l.sampling, route: l.route}
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == "route" {
+			if v, ok := args[i+1].(string); ok {
+				n.route = v
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A concurrency safe LRU cache, limited by number of entries.
+type LRUCache struct {
+	mutex sync.Mutex
+	size  int
+	list  *list.List
+	item  map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	value interface{}
+}
+
+// Return a LRUCache keeps at most size entries, size < 1 means 1.
+func NewLRUCache(size int) *LRUCache {
+	if size < 1 {
+		size = 1
+	}
+	c := new(LRUCache)
+	c.size = size
+	c.list = list.New()
+	c.item = make(map[string]*list.Element)
+	return c
+}
+
+// Return value of key and mark it recently used.
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.item[key]
+	if !ok {
+		return nil, false
+	}
+	c.list.MoveToFront(e)
+	return e.Value.(*lruItem).value, true
+}
+
+// Set value of key, the least recently used entry is removed if cache is full.
+func (c *LRUCache) Set(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.item[key]; ok {
+		e.Value.(*lruItem).value = value
+		c.list.MoveToFront(e)
+		return
+	}
+	c.item[key] = c.list.PushFront(&lruItem{key: key, value: value})
+	for c.list.Len() > c.size {
+		e := c.list.Back()
+		c.list.Remove(e)
+		delete(c.item, e.Value.(*lruItem).key)
+	}
+}
+
+// Remove key.
+func (c *LRUCache) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.item[key]; ok {
+		c.list.Remove(e)
+		delete(c.item, key)
+	}
+}
+
+// Return number of entries.
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.list.Len()
+}
@@ -0,0 +1,64 @@
+package router
+
+// Route table of a method, rootRoute (a radix tree) is the default one.
+// Alternative implementations, such as a hash map of exact paths, can be set by Router.SetMatcher.
+// Slash policy, case folding, priority and Router.Candidates only work with the default one.
+type Matcher interface {
+	// Add a route by path, return the added one if path exists.
+	// Route should be created by NewRoute.
+	Add(path string) (*Route, error)
+	// Remove the route by path, return false if not found.
+	Remove(path string) bool
+	// Return the route added by path, nil if not found.
+	Find(path string) *Route
+	// Return the route matches c.Req.URL.Path and append values of param routes to c.Param, nil if not found.
+	Match(c *Context) *Route
+	// Call fn with all routes.
+	Walk(fn func(*Route))
+}
+
+// Return a Route of path, used by Matcher implementations.
+// Param routes in path are ":name" and "*name", same as Router.Add.
+func NewRoute(path string) (*Route, error) {
+	part, err := splitRoute(path)
+	if err != nil {
+		return nil, err
+	}
+	route := new(Route)
+	for i, s := range part {
+		// '/' after param route is not in part.
+		if i > 0 && (part[i-1] == ":" || part[i-1] == "*") {
+			route.path += "/"
+		}
+		route.path += s
+	}
+	route.name = part[len(part)-1]
+	route.meta.params = paramNames(path)
+	return route, nil
+}
+
+// Use matchers created by newMatcher for all methods, nil to use the default one.
+// Routes added before are dropped, so call it before adding routes.
+func (r *Router) SetMatcher(newMatcher func() Matcher) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := range r.matchers {
+		r.rootRoute[i] = rootRoute{}
+		r.matchers[i] = nil
+		if newMatcher != nil {
+			r.matchers[i] = newMatcher()
+		}
+	}
+}
+
+// Return route table of method, nil if method is not supported.
+func (r *Router) table(method string) Matcher {
+	i := methodIndex(method)
+	if i < 0 {
+		return nil
+	}
+	if r.matchers[i] != nil {
+		return r.matchers[i]
+	}
+	return &r.rootRoute[i]
+}
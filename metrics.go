@@ -0,0 +1,136 @@
+package router
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metric names emitted by router.
+const (
+	MetricRequests = "http.requests"
+	MetricLatency  = "http.latency"
+	// Wait time in queue of Limiter, only emitted for queued requests.
+	MetricQueueWait = "http.queue.wait"
+)
+
+// Backend of metrics. Tags are "key:value" strings.
+type MetricsSink interface {
+	// Add n to counter.
+	Count(name string, n int64, tags ...string)
+	// Record a duration.
+	Timing(name string, d time.Duration, tags ...string)
+	// Set a gauge value.
+	Gauge(name string, v float64, tags ...string)
+}
+
+// Send metrics to all sinks.
+type MultiSink []MetricsSink
+
+func (s MultiSink) Count(name string, n int64, tags ...string) {
+	for _, sink := range s {
+		sink.Count(name, n, tags...)
+	}
+}
+
+func (s MultiSink) Timing(name string, d time.Duration, tags ...string) {
+	for _, sink := range s {
+		sink.Timing(name, d, tags...)
+	}
+}
+
+func (s MultiSink) Gauge(name string, v float64, tags ...string) {
+	for _, sink := range s {
+		sink.Gauge(name, v, tags...)
+	}
+}
+
+// Set metrics sink, nil to disable metrics.
+func (r *Router) SetMetrics(sink MetricsSink) {
+	r.metrics = sink
+}
+
+// Emit request count and latency with route, method and status tags.
+func (r *Router) emitMetrics(c *Context, latency time.Duration) {
+	route := "notfound"
+	if c.route != nil {
+		route = c.route.path
+	}
+	tags := []string{
+		"route:" + route,
+		"method:" + c.Req.Method,
+		"status:" + strconv.Itoa(c.Status()),
+	}
+	r.metrics.Count(MetricRequests, 1, tags...)
+	r.metrics.Timing(MetricLatency, latency, tags...)
+	if c.queued {
+		r.metrics.Timing(MetricQueueWait, c.queueWait, tags...)
+	}
+}
+
+// Send metrics over UDP in StatsD line protocol, with Datadog style tags.
+type StatsDSink struct {
+	// Prepend to each metric name, example: "myapp.".
+	Prefix string
+	// If it is true, tags are not sent, for plain StatsD server.
+	NoTags bool
+	conn   net.Conn
+	pool   sync.Pool
+}
+
+// Return a StatsDSink sending to addr, example: "127.0.0.1:8125".
+func NewStatsDSink(addr string) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := new(StatsDSink)
+	s.conn = conn
+	s.pool.New = func() interface{} {
+		return new(bytes.Buffer)
+	}
+	return s, nil
+}
+
+// Close the UDP connection.
+func (s *StatsDSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsDSink) Count(name string, n int64, tags ...string) {
+	s.send(name, strconv.FormatInt(n, 10), "c", tags)
+}
+
+func (s *StatsDSink) Timing(name string, d time.Duration, tags ...string) {
+	s.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+func (s *StatsDSink) Gauge(name string, v float64, tags ...string) {
+	s.send(name, strconv.FormatFloat(v, 'f', -1, 64), "g", tags)
+}
+
+// Format: "<prefix><name>:<value>|<type>|#<tag>,<tag>".
+func (s *StatsDSink) send(name, value, typ string, tags []string) {
+	buf := s.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.WriteString(s.Prefix)
+	buf.WriteString(name)
+	buf.WriteByte(':')
+	buf.WriteString(value)
+	buf.WriteByte('|')
+	buf.WriteString(typ)
+	if !s.NoTags && len(tags) > 0 {
+		buf.WriteString("|#")
+		for i, tag := range tags {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(tag)
+		}
+	}
+	// UDP, ignore error.
+	s.conn.Write(buf.Bytes())
+	s.pool.Put(buf)
+}
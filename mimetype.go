@@ -0,0 +1,67 @@
+package router
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+var (
+	// Content types of extensions, checked before mime.TypeByExtension,
+	// which depends on the mime files of system.
+	contentTypes = map[string]string{
+		".avif":        "image/avif",
+		".webp":        "image/webp",
+		".wasm":        "application/wasm",
+		".webmanifest": "application/manifest+json",
+		".mjs":         "text/javascript; charset=utf-8",
+		".map":         "application/json",
+		".woff2":       "font/woff2",
+	}
+	contentTypesMutex sync.RWMutex
+)
+
+// Register content type of file extension, such as ".glb" -> "model/gltf-binary".
+// It is used by static handlers, and overrides the system mime types.
+func RegisterContentType(ext, contentType string) {
+	ext = strings.ToLower(ext)
+	if ext != "" && ext[0] != '.' {
+		ext = "." + ext
+	}
+	contentTypesMutex.Lock()
+	contentTypes[ext] = contentType
+	contentTypesMutex.Unlock()
+}
+
+// Return content type of file extension, such as ".wasm", from registered types and system mime types.
+// Return empty if it is unknown.
+func ContentTypeByExtension(ext string) string {
+	contentTypesMutex.RLock()
+	s, ok := contentTypes[strings.ToLower(ext)]
+	contentTypesMutex.RUnlock()
+	if ok {
+		return s
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// Return content type of file name by extension, or by sniffing data if extension is unknown.
+func detectContentType(name string, data []byte) string {
+	if s := ContentTypeByExtension(path.Ext(name)); s != "" {
+		return s
+	}
+	return http.DetectContentType(data)
+}
+
+// Set Content-Type header of file name if its extension is registered,
+// so http.ServeContent does not use system mime types or sniff.
+func setRegisteredContentType(c *Context, name string) {
+	contentTypesMutex.RLock()
+	s, ok := contentTypes[strings.ToLower(path.Ext(name))]
+	contentTypesMutex.RUnlock()
+	if ok {
+		c.Res.Header().Set("Content-Type", s)
+	}
+}
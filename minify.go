@@ -0,0 +1,43 @@
+package router
+
+import (
+	"mime"
+)
+
+// Minify content, such as an adapter of github.com/tdewolff/minify.
+type Minifier interface {
+	// Return minified data of media type, such as "text/html", "text/css" and "text/javascript".
+	// Return data as it is if media type is not supported.
+	Minify(mediaType string, data []byte) ([]byte, error)
+}
+
+// Adapter of function as Minifier.
+type MinifierFunc func(mediaType string, data []byte) ([]byte, error)
+
+func (f MinifierFunc) Minify(mediaType string, data []byte) ([]byte, error) {
+	return f(mediaType, data)
+}
+
+// Set minifier of Renderer output and cached static files, nil to disable.
+// Static files are minified once when they are added, so call it before AddStatic, AddFile, AddStaticFS and AddVariants.
+func (r *Router) SetMinifier(m Minifier) {
+	r.minifier = m
+}
+
+// Minify data of h, it is compressed again lazily.
+func (r *Router) minifyCache(h *CacheHandler) error {
+	if r.minifier == nil {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(h.ContentType)
+	if err != nil {
+		return nil
+	}
+	data, err := r.minifier.Minify(mediaType, h.Data)
+	if err != nil {
+		return err
+	}
+	h.Data = data
+	h.discardCompressed()
+	return nil
+}
@@ -0,0 +1,41 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Content-Type of NDJSON stream.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Write JSON documents one per line, each is flushed to client immediately.
+type NDJSONWriter struct {
+	c   *Context
+	enc *json.Encoder
+}
+
+// Return a NDJSONWriter, response header is written at the first Send.
+func (c *Context) NDJSON() *NDJSONWriter {
+	return &NDJSONWriter{c: c}
+}
+
+// Encode v as one line and flush.
+// It returns error of the context if client is gone.
+func (w *NDJSONWriter) Send(v interface{}) error {
+	err := w.c.Req.Context().Err()
+	if err != nil {
+		return err
+	}
+	if w.enc == nil {
+		w.c.Res.Header().Set("Content-Type", ContentTypeNDJSON)
+		w.c.Res.Header().Set("X-Content-Type-Options", "nosniff")
+		w.c.Res.WriteHeader(http.StatusOK)
+		w.enc = json.NewEncoder(w.c.Res)
+	}
+	err = w.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	w.c.res.Flush()
+	return nil
+}
@@ -0,0 +1,91 @@
+package router
+
+import "context"
+
+// Call funcs in order, then tail if it is not nil, until one returns false or Abort is called.
+// Return false if the chain is stopped.
+// State of the outer chain is kept, so chains can be nested, such as Route.Handle in a handler.
+func (c *Context) run(funcs []HandlerFunc, tail HandlerFunc) bool {
+	chain, next, index, stopped := c.chain, c.tail, c.index, c.stopped
+	c.chain, c.tail, c.index, c.stopped = funcs, tail, 0, false
+	ok := c.Next()
+	c.chain, c.tail, c.index, c.stopped = chain, next, index, stopped
+	return ok
+}
+
+// Call the rest handlers of the current chain, and return false if one of them returns false or calls Abort.
+// It is used by wrap-around middleware, such as timing, code after Next runs after downstream handlers.
+// The rest handlers are not called again after the middleware returns.
+// In before chain, the rest are the before chain, match and handlers of the route.
+// In handlers of a route, the rest are handlers of the route, nested chains such as Chain are not included.
+// Example:
+//
+//	func Timing(c *Context) bool {
+//		start := time.Now()
+//		ok := c.Next()
+//		c.Logger().Info("timing", "latency", time.Since(start))
+//		return ok
+//	}
+func (c *Context) Next() bool {
+	for c.index < len(c.chain) {
+		if c.disconnectAbort() {
+			return false
+		}
+		h := c.chain[c.index]
+		c.index++
+		if !h(c) {
+			c.Abort()
+		}
+	}
+	if c.tail != nil && !c.stopped && !c.disconnectAbort() {
+		tail := c.tail
+		c.tail = nil
+		if !tail(c) {
+			c.stopped = true
+		}
+	}
+	return !c.stopped
+}
+
+// Stop the rest handlers of the current chain, same as a handler returns false.
+func (c *Context) Abort() {
+	c.index = len(c.chain)
+	c.tail = nil
+	c.stopped = true
+}
+
+// Return true if Abort is called or a handler returned false in the current chain.
+func (c *Context) Aborted() bool {
+	return c.stopped
+}
+
+// Return a channel closed when client is gone or request context is canceled,
+// such as deadline of SetTimeout, used to stop expensive work early.
+// Example:
+//
+//	select {
+//	case v := <-result:
+//		c.WriteJSON(http.StatusOK, v)
+//	case <-c.Disconnected():
+//		return false
+//	}
+func (c *Context) Disconnected() <-chan struct{} {
+	return c.Req.Context().Done()
+}
+
+// Enable or disable abort the rest handlers when client is gone, default enabled.
+// Request context canceled by the server, such as Server.Shutdown closing connections, counts too.
+// It is checked before each handler of before chain, phases and route handlers, after and recovery chains always run.
+func (r *Router) SetAbortOnDisconnect(enable bool) {
+	r.keepOnDisconnect = !enable
+}
+
+// Abort the chain and return true if client is gone.
+// Deadline of SetTimeout is not a disconnect, handlers can still response, such as 504.
+func (c *Context) disconnectAbort() bool {
+	if c.finishing || c.router == nil || c.router.keepOnDisconnect || c.Req.Context().Err() != context.Canceled {
+		return false
+	}
+	c.Abort()
+	return true
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"strings"
+)
+
+// Why request is not handled by a route, see Context.NotFoundReason.
+type NotFoundReason int
+
+const (
+	// Request is not in notfound chain.
+	NotFoundNone NotFoundReason = iota
+	// No route matches path.
+	NotFoundNoRoute
+	// Path is a parent of routes, such as "/files" of "/files/list".
+	NotFoundNoHandler
+	// Path matches routes of other methods.
+	NotFoundMethodMismatch
+	// Routes matched but all of them fell through by Context.Fallthrough,
+	// such as handlers check params and call Fallthrough.
+	NotFoundConstraint
+)
+
+func (r NotFoundReason) String() string {
+	switch r {
+	case NotFoundNoRoute:
+		return "no_route"
+	case NotFoundNoHandler:
+		return "no_handler"
+	case NotFoundMethodMismatch:
+		return "method_mismatch"
+	case NotFoundConstraint:
+		return "constraint"
+	}
+	return ""
+}
+
+// Return why request is not handled by a route, used in notfound chain to choose response such as 404, 405 or 400.
+// Return NotFoundNone if request is not in notfound chain.
+func (c *Context) NotFoundReason() NotFoundReason {
+	if !c.notFound || c.router == nil {
+		return NotFoundNone
+	}
+	if c.notFoundReason == NotFoundNone {
+		c.notFoundReason = c.router.notFoundReason(c)
+	}
+	return c.notFoundReason
+}
+
+// Classify the miss of request.
+func (r *Router) notFoundReason(c *Context) NotFoundReason {
+	if len(c.tried) > 0 {
+		return NotFoundConstraint
+	}
+	if r.allowMethods(c) != "" {
+		return NotFoundMethodMismatch
+	}
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	path := strings.TrimSuffix(c.Req.URL.Path, "/")
+	if trees := routeTrees(r.table(c.Req.Method), path); trees != nil {
+		for _, root := range trees {
+			if root.route.matchParent(path + "/") {
+				return NotFoundNoHandler
+			}
+		}
+		return NotFoundNoRoute
+	}
+	n := len(c.Param)
+	route := r.matchTable(c, c.Req.Method, path, false, matchAny)
+	c.Param = c.Param[:n]
+	if route != nil {
+		return NotFoundNoHandler
+	}
+	return NotFoundNoRoute
+}
+
+// Return true if path is consumed by r or its sub routes, and there are sub routes after it.
+// path ends with '/', r.name is not matched yet.
+func (r *Route) matchParent(path string) bool {
+	if r.isParam() {
+		if r.name != ":" {
+			return false
+		}
+		i := strings.IndexByte(path, '/')
+		if i < 1 {
+			return false
+		}
+		return r.matchSubParent(path[i+1:])
+	}
+	if len(path) <= len(r.name) {
+		return r.name[:len(path)] == path
+	}
+	if path[:len(r.name)] != r.name {
+		return false
+	}
+	return r.matchSubParent(path[len(r.name):])
+}
+
+func (r *Route) matchSubParent(path string) bool {
+	if path == "" {
+		return r.param != nil || r.hasStatic()
+	}
+	if sub := r.static[path[0]]; sub != nil && sub.matchParent(path) {
+		return true
+	}
+	return r.param != nil && r.param.matchParent(path)
+}
+
+// Return true if r has static sub routes.
+func (r *Route) hasStatic() bool {
+	for i := 0; i < len(r.static); i++ {
+		if r.static[i] != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,381 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errOIDCState     = errors.New("oidc: invalid state")
+	errOIDCNoKey     = errors.New("oidc: signing key not found")
+	errOIDCIDToken   = errors.New("oidc: invalid id token")
+	errOIDCNoIDToken = errors.New("oidc: token response has no id_token")
+)
+
+// OIDC login of a provider: redirect to provider, handle callback, validate ID token against JWKS,
+// and save claims in session. Only RS256 ID tokens are supported.
+type OIDCProvider struct {
+	// Name of provider, used in session keys.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Absolute URL of callback route.
+	RedirectURL string
+	// Default is "openid", "profile", "email".
+	Scopes []string
+	// Endpoints, discovered from Issuer + "/.well-known/openid-configuration" if empty.
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+	// JWKS cache duration.
+	JWKSCacheTTL time.Duration
+	// Sessions used to keep login state and claims, required.
+	Sessions *SessionManager
+	// If it is nil, use http.DefaultClient.
+	Client    *http.Client
+	mutex     sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	keysFetch time.Time
+}
+
+// Session keys.
+func (p *OIDCProvider) sessionKey(name string) string {
+	return "oidc." + p.Name + "." + name
+}
+
+func (p *OIDCProvider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
+
+// Get url and decode JSON response to v.
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: get %s status %d", u, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Fetch endpoints from discovery document if not set.
+func (p *OIDCProvider) discoverEndpoints(ctx context.Context) error {
+	p.mutex.Lock()
+	ok := p.AuthURL != "" && p.TokenURL != "" && p.JWKSURL != ""
+	p.mutex.Unlock()
+	if ok {
+		return nil
+	}
+	var doc struct {
+		AuthURL  string `json:"authorization_endpoint"`
+		TokenURL string `json:"token_endpoint"`
+		JWKSURL  string `json:"jwks_uri"`
+	}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &doc)
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.AuthURL == "" {
+		p.AuthURL = doc.AuthURL
+	}
+	if p.TokenURL == "" {
+		p.TokenURL = doc.TokenURL
+	}
+	if p.JWKSURL == "" {
+		p.JWKSURL = doc.JWKSURL
+	}
+	return nil
+}
+
+// Return endpoints, they may be set by discoverEndpoints concurrently.
+func (p *OIDCProvider) endpoints() (authURL, tokenURL, jwksURL string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.AuthURL, p.TokenURL, p.JWKSURL
+}
+
+// Return public key of kid, JWKS is cached and fetched again if kid is unknown.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mutex.Lock()
+	key := p.keys[kid]
+	ttl := p.JWKSCacheTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	expired := time.Since(p.keysFetch) > ttl
+	// Unknown kid, fetch again but at most once per minute.
+	refetch := key == nil && time.Since(p.keysFetch) > time.Minute
+	p.mutex.Unlock()
+	if key != nil && !expired {
+		return key, nil
+	}
+	if !expired && !refetch {
+		return nil, errOIDCNoKey
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	_, _, jwksURL := p.endpoints()
+	err := p.getJSON(ctx, jwksURL, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.mutex.Lock()
+	p.keys = keys
+	p.keysFetch = time.Now()
+	p.mutex.Unlock()
+	if key = keys[kid]; key == nil {
+		return nil, errOIDCNoKey
+	}
+	return key, nil
+}
+
+// Verify ID token signature, issuer, audience, expiry and nonce.
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, token, nonce string) (Claims, error) {
+	part := strings.Split(token, ".")
+	if len(part) != 3 {
+		return nil, errOIDCIDToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	data, err := base64.RawURLEncoding.DecodeString(part[0])
+	if err != nil || json.Unmarshal(data, &header) != nil || header.Alg != "RS256" {
+		return nil, errOIDCIDToken
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(part[2])
+	if err != nil {
+		return nil, errOIDCIDToken
+	}
+	sum := sha256.Sum256([]byte(part[0] + "." + part[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) != nil {
+		return nil, errOIDCIDToken
+	}
+	data, err = base64.RawURLEncoding.DecodeString(part[1])
+	if err != nil {
+		return nil, errOIDCIDToken
+	}
+	var claims Claims
+	if json.Unmarshal(data, &claims) != nil {
+		return nil, errOIDCIDToken
+	}
+	if claims.String("iss") != p.Issuer || !claims.hasAudience(p.ClientID) || claims.String("nonce") != nonce {
+		return nil, errOIDCIDToken
+	}
+	if exp := claims.Time("exp"); exp.IsZero() || !time.Now().Before(exp) {
+		return nil, errTokenExpired
+	}
+	return claims, nil
+}
+
+// Return whether "aud" claim contains aud.
+func (c Claims) hasAudience(aud string) bool {
+	switch v := c["aud"].(type) {
+	case string:
+		return v == aud
+	case []interface{}:
+		for _, a := range v {
+			if a == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Redirect to provider. Query "return" is the URL redirected to after login.
+// Can be use as HandlerFunc, must run after Sessions.Handle.
+func (p *OIDCProvider) Login(c *Context) bool {
+	err := p.discoverEndpoints(c.Req.Context())
+	if err != nil {
+		c.Logger().Error("oidc discover", "provider", p.Name, "error", err)
+		c.Res.WriteHeader(http.StatusBadGateway)
+		return false
+	}
+	session := c.Session()
+	state, nonce, verifier := newSessionID(), newSessionID(), newSessionID()
+	session.Set(p.sessionKey("state"), state)
+	session.Set(p.sessionKey("nonce"), nonce)
+	session.Set(p.sessionKey("verifier"), verifier)
+	session.Set(p.sessionKey("return"), safeReturnURL(c.Req.FormValue("return")))
+	scopes := p.Scopes
+	if len(scopes) < 1 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	authURL, _, _ := p.endpoints()
+	sep := "?"
+	if strings.Contains(authURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(c.Res, c.Req, authURL+sep+q.Encode(), http.StatusFound)
+	return true
+}
+
+// Only allow local path, prevent open redirect.
+func safeReturnURL(s string) string {
+	if s == "" || s[0] != '/' || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/\\") {
+		return "/"
+	}
+	return s
+}
+
+// Handle the callback of provider, exchange code for ID token, verify it and save claims in session.
+// Can be use as HandlerFunc, must run after Sessions.Handle.
+func (p *OIDCProvider) Callback(c *Context) bool {
+	session := c.Session()
+	state, _ := session.Get(p.sessionKey("state")).(string)
+	nonce, _ := session.Get(p.sessionKey("nonce")).(string)
+	verifier, _ := session.Get(p.sessionKey("verifier")).(string)
+	returnURL, _ := session.Get(p.sessionKey("return")).(string)
+	session.Delete(p.sessionKey("state"))
+	session.Delete(p.sessionKey("nonce"))
+	session.Delete(p.sessionKey("verifier"))
+	session.Delete(p.sessionKey("return"))
+	if state == "" || c.Req.FormValue("state") != state {
+		c.Logger().Warn("oidc callback", "provider", p.Name, "error", errOIDCState)
+		c.Res.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	err := p.discoverEndpoints(c.Req.Context())
+	if err != nil {
+		c.Logger().Error("oidc discover", "provider", p.Name, "error", err)
+		c.Res.WriteHeader(http.StatusBadGateway)
+		return false
+	}
+	claims, err := p.exchange(c.Req.Context(), c.Req.FormValue("code"), verifier, nonce)
+	if err != nil {
+		c.Logger().Warn("oidc callback", "provider", p.Name, "error", err)
+		c.Res.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	// Prevent session fixation.
+	err = p.Sessions.Regenerate(c)
+	if err != nil {
+		c.Logger().Error("oidc callback", "provider", p.Name, "error", err)
+		c.Res.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	c.Session().Set(p.sessionKey("claims"), map[string]interface{}(claims))
+	c.claims = claims
+	http.Redirect(c.Res, c.Req, safeReturnURL(returnURL), http.StatusFound)
+	return true
+}
+
+// Exchange code at token endpoint and verify ID token.
+func (p *OIDCProvider) exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+	_, tokenURL, _ := p.endpoints()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	res, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint status %d", res.StatusCode)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errOIDCNoIDToken
+	}
+	return p.verifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// Load claims from session into context, response 401 if not logged in.
+// Can be use as HandlerFunc, must run after Sessions.Handle.
+func (p *OIDCProvider) Handle(c *Context) bool {
+	session := c.Session()
+	if session != nil {
+		switch v := session.Get(p.sessionKey("claims")).(type) {
+		case map[string]interface{}:
+			c.claims = v
+			return true
+		case Claims:
+			c.claims = v
+			return true
+		}
+	}
+	c.Res.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+// Add login and callback routes of provider.
+func (r *Router) AddOIDC(p *OIDCProvider, loginPath, callbackPath string) error {
+	_, err := r.AddGet(loginPath, p.Sessions.Handle, p.Login, p.Sessions.Commit)
+	if err != nil {
+		return err
+	}
+	_, err = r.AddGet(callbackPath, p.Sessions.Handle, p.Callback, p.Sessions.Commit)
+	return err
+}
@@ -0,0 +1,203 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI 3 document, only the parts generated from routes.
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// Info object of OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation object of OpenAPI document.
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// Parameter object of OpenAPI document.
+type OpenAPIParameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema,omitempty"`
+}
+
+// Response object of OpenAPI document.
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// Convert route pattern to OpenAPI path template and parameter names, named params keep their names.
+// Example: "/users/:/files/*" -> "/users/{param0}/files/{path}", ["param0", "path"].
+func openAPIPath(route *Route) (string, []string) {
+	params := route.Params()
+	part := strings.Split(route.path, "/")
+	var names []string
+	n := 0
+	for i, s := range part {
+		switch s {
+		case ":":
+			part[i] = "param" + strconv.Itoa(n)
+		case "*":
+			part[i] = "path"
+		default:
+			continue
+		}
+		if params[n] != "" {
+			part[i] = params[n]
+		}
+		n++
+		names = append(names, part[i])
+		part[i] = "{" + part[i] + "}"
+	}
+	return strings.Join(part, "/"), names
+}
+
+// Set info of generated OpenAPI document.
+func (r *Router) SetOpenAPIInfo(info OpenAPIInfo) {
+	r.openAPIInfo = info
+}
+
+// Return OpenAPI document generated from routes which have handlers.
+func (r *Router) OpenAPI() *OpenAPIDocument {
+	doc := new(OpenAPIDocument)
+	doc.OpenAPI = "3.0.3"
+	doc.Info = r.openAPIInfo
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	doc.Paths = make(map[string]map[string]*OpenAPIOperation)
+	r.walk(func(method string, route *Route) {
+		path, names := openAPIPath(route)
+		op := new(OpenAPIOperation)
+		for _, name := range names {
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]string{"type": "string"},
+			})
+		}
+		op.Responses = map[string]*OpenAPIResponse{"default": {Description: "response"}}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	})
+	return doc
+}
+
+// Response OpenAPI() in JSON. Can be use as HandlerFunc.
+func (r *Router) OpenAPIHandler(c *Context) bool {
+	c.Res.Header().Set("Content-Type", ContentTypeJSON)
+	c.Res.WriteHeader(http.StatusOK)
+	return json.NewEncoder(c.Res).Encode(r.OpenAPI()) == nil
+}
+
+// Handlers registry, key is operationId of OpenAPI document.
+type HandlerRegistry map[string][]HandlerFunc
+
+// Convert OpenAPI path template to route path.
+// "{name}" segment becomes ":", and "{name*}" or "{+name}" as the last segment becomes "*".
+// Example: "/users/{id}/files/{path*}" -> "/users/:/files/*".
+func openAPIToRoute(template string) (string, error) {
+	part := strings.Split(template, "/")
+	for i, s := range part {
+		begin := strings.IndexByte(s, '{')
+		end := strings.IndexByte(s, '}')
+		if begin < 0 && end < 0 {
+			if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+				return "", fmt.Errorf("openapi: path %s segment %s conflicts with route syntax", template, s)
+			}
+			continue
+		}
+		// Only whole segment template is supported.
+		if begin != 0 || end != len(s)-1 || len(s) < 3 {
+			return "", fmt.Errorf("openapi: path %s segment %s is not supported", template, s)
+		}
+		name := s[1 : len(s)-1]
+		if strings.HasPrefix(name, "+") || strings.HasSuffix(name, "*") {
+			if i != len(part)-1 {
+				return "", fmt.Errorf("openapi: path %s wildcard %s must be the last segment", template, s)
+			}
+			part[i] = "*"
+			continue
+		}
+		part[i] = ":"
+	}
+	return strings.Join(part, "/"), nil
+}
+
+// Parse OpenAPI document in JSON, add a route for each operation with handlers from registry by operationId.
+// All operations are validated and added by AddRoutes, either all routes are added or none.
+func (r *Router) AddOpenAPI(data []byte, registry HandlerRegistry) error {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	var operations []RouteSpec
+	for template, item := range doc.Paths {
+		routePath, err := openAPIToRoute(template)
+		if err != nil {
+			return err
+		}
+		for key, raw := range item {
+			method := strings.ToUpper(key)
+			if !isMethod(method) {
+				// Not an operation, such as "parameters" and "summary".
+				continue
+			}
+			var op OpenAPIOperation
+			err = json.Unmarshal(raw, &op)
+			if err != nil {
+				return fmt.Errorf("openapi: %s %s: %w", method, template, err)
+			}
+			handler, ok := registry[op.OperationID]
+			if !ok {
+				return fmt.Errorf("openapi: %s %s: no handler of operationId '%s'", method, template, op.OperationID)
+			}
+			operations = append(operations, RouteSpec{Method: method, Path: routePath, Handler: handler})
+		}
+	}
+	// Sort for deterministic error.
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Path != operations[j].Path {
+			return operations[i].Path < operations[j].Path
+		}
+		return operations[i].Method < operations[j].Method
+	})
+	return r.AddRoutes(operations)
+}
+
+// Return whether s is one of methods.
+func isMethod(s string) bool {
+	for _, m := range methods {
+		if m == s {
+			return true
+		}
+	}
+	return false
+}
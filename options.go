@@ -0,0 +1,136 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Policy of trailing slash when request path does not match any route.
+// Routes are added without trailing slash, so it applies to request such as "/a/".
+type SlashPolicy int
+
+const (
+	// Request "/a/" does not match route "/a".
+	SlashStrict SlashPolicy = iota
+	// Redirect to the path with or without trailing slash if it matches a route,
+	// 301 for GET and HEAD, 308 for other methods.
+	SlashRedirect
+	// Handle by the route of the path with or without trailing slash.
+	SlashIgnore
+)
+
+// Option of New.
+type Option func(*Router)
+
+// Return a Router configured by opts.
+// A zero value Router is still ready to use, and setters can be called after New.
+func New(opts ...Option) *Router {
+	r := new(Router)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Option of SetSlashPolicy.
+func WithSlashPolicy(policy SlashPolicy) Option {
+	return func(r *Router) {
+		r.SetSlashPolicy(policy)
+	}
+}
+
+// Option of SetCaseFolding.
+func WithCaseFolding(enable bool) Option {
+	return func(r *Router) {
+		r.SetCaseFolding(enable)
+	}
+}
+
+// Option of SetMaxBodySize.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Router) {
+		r.SetMaxBodySize(n)
+	}
+}
+
+// Option of SetDevMode.
+func WithDevMode(devMode bool) Option {
+	return func(r *Router) {
+		r.SetDevMode(devMode)
+	}
+}
+
+// Option of SetLogger.
+func WithLogger(logger Logger) Option {
+	return func(r *Router) {
+		r.SetLogger(logger)
+	}
+}
+
+// Option of SetMetrics.
+func WithMetrics(sink MetricsSink) Option {
+	return func(r *Router) {
+		r.SetMetrics(sink)
+	}
+}
+
+// Set policy of trailing slash, default is SlashStrict.
+func (r *Router) SetSlashPolicy(policy SlashPolicy) {
+	r.slashPolicy = policy
+}
+
+// If enable, path which does not match is matched again ignoring ASCII case of static names.
+// Param values keep the case of request path.
+func (r *Router) SetCaseFolding(enable bool) {
+	r.caseFolding = enable
+}
+
+// Limit request body to n bytes by http.MaxBytesReader, 0 is no limit.
+func (r *Router) SetMaxBodySize(n int64) {
+	r.maxBodySize = n
+}
+
+// Try to match request by case folding and slash policy.
+// Return the route to handle, or the location to redirect.
+func (r *Router) matchAlternative(c *Context) (*Route, string) {
+	path := c.Req.URL.Path
+	if r.caseFolding {
+		route := r.matchPath(c, path, true)
+		if route != nil {
+			return route, ""
+		}
+		c.Param = c.Param[:0]
+	}
+	if r.slashPolicy == SlashStrict || path == "/" {
+		return nil, ""
+	}
+	if strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	} else {
+		path = c.AllocString(path, "/")
+	}
+	route := r.matchPath(c, path, r.caseFolding)
+	if route == nil {
+		return nil, ""
+	}
+	if r.slashPolicy == SlashRedirect {
+		// Location is kept in response header.
+		return nil, strings.Clone(path)
+	}
+	return route, ""
+}
+
+// Redirect to location with query of request.
+// Leading '/' and '\' are collapsed, so "//evil.com" is not a network-path reference of another host.
+func redirectSlash(c *Context, location string) {
+	location = "/" + strings.TrimLeft(location, "/\\")
+	if c.Req.URL.RawQuery != "" {
+		location += "?" + c.Req.URL.RawQuery
+	}
+	c.Res.Header().Set("Location", location)
+	if c.Req.Method == http.MethodGet || c.Req.Method == http.MethodHead {
+		c.Res.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+	c.Res.WriteHeader(http.StatusPermanentRedirect)
+}
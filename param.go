@@ -0,0 +1,219 @@
+package router
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var errParamIndex = errors.New("index out of range")
+
+// Error of param conversion, response 400 by c.BadRequest.
+type ParamError struct {
+	// Index of c.Param.
+	Index int
+	Value string
+	// Target type, such as "int".
+	Type string
+	Err  error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("param %d %q is not a valid %s: %v", e.Index, e.Value, e.Type, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// Problem details of RFC 7807.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Invalid fields of ValidationError.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// Response problem as application/problem+json.
+func (c *Context) WriteProblem(p *Problem) error {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	c.Res.Header().Set("Content-Type", "application/problem+json")
+	c.Res.WriteHeader(p.Status)
+	return json.NewEncoder(c.Res).Encode(p)
+}
+
+// Response 400 problem with detail of err, and return false, so handler can return it.
+// Example: if err != nil { return c.BadRequest(err) }
+// If err is a *ValidationError, invalid fields are listed in "errors".
+func (c *Context) BadRequest(err error) bool {
+	p := &Problem{
+		Status:   http.StatusBadRequest,
+		Detail:   err.Error(),
+		Instance: c.Req.URL.Path,
+	}
+	var v *ValidationError
+	if errors.As(err, &v) {
+		p.Errors = v.Fields
+	}
+	c.WriteProblem(p)
+	return false
+}
+
+// Return the remaining path matched by all match route such as "/files/*", in escaped form of request URL.
+// Return "" if matched route is not a all match route.
+// Example: "/files/a%2Fb/c" -> "a%2Fb/c".
+func (c *Context) WildcardPath() string {
+	decoded := c.DecodedWildcardPath()
+	if decoded == "" {
+		return ""
+	}
+	raw := c.Req.URL.EscapedPath()
+	if raw == c.Req.URL.Path {
+		return decoded
+	}
+	// All match route always follows a '/'.
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '/' {
+			continue
+		}
+		if s, err := url.PathUnescape(raw[i+1:]); err == nil && s == decoded {
+			return raw[i+1:]
+		}
+	}
+	return decoded
+}
+
+// Return the remaining path matched by all match route, unescaped, same as the last value of c.Param.
+// Return "" if matched route is not a all match route.
+func (c *Context) DecodedWildcardPath() string {
+	if c.route == nil || !(c.route.isParam() && c.route.name == "*") || len(c.Param) < 1 {
+		return ""
+	}
+	return c.Param[len(c.Param)-1]
+}
+
+// Return c.Param[i] or error.
+func (c *Context) param(i int, typ string) (string, error) {
+	if i < 0 || i >= len(c.Param) {
+		return "", &ParamError{Index: i, Type: typ, Err: errParamIndex}
+	}
+	return c.Param[i], nil
+}
+
+// Return value of param route by name, such as "id" of "/users/:id/posts/:postID", "" if not found.
+// Names are stored on route by Add, positional c.Param is kept.
+func (c *Context) ParamByName(name string) string {
+	i := c.ParamIndex(name)
+	if i < 0 {
+		return ""
+	}
+	return c.Param[i]
+}
+
+// Return index of c.Param by name of param route, -1 if not found, used by ParamInt and others.
+func (c *Context) ParamIndex(name string) int {
+	if c.route == nil || name == "" {
+		return -1
+	}
+	for i, s := range c.route.meta.params {
+		if s == name && i < len(c.Param) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Return c.Param[i] as int.
+func (c *Context) ParamInt(i int) (int, error) {
+	s, err := c.param(i, "int")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &ParamError{Index: i, Value: s, Type: "int", Err: err}
+	}
+	return n, nil
+}
+
+// Return c.Param[i] as int64.
+func (c *Context) ParamInt64(i int) (int64, error) {
+	s, err := c.param(i, "int64")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Index: i, Value: s, Type: "int64", Err: err}
+	}
+	return n, nil
+}
+
+// Return c.Param[i] parsed by time.Parse with layout.
+func (c *Context) ParamTime(i int, layout string) (time.Time, error) {
+	s, err := c.param(i, "time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, &ParamError{Index: i, Value: s, Type: "time", Err: err}
+	}
+	return t, nil
+}
+
+// A RFC 4122 UUID.
+type UUID [16]byte
+
+var errUUIDFormat = errors.New("invalid format")
+
+// Parse UUID in form "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errUUIDFormat
+	}
+	b := []byte(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	_, err := hex.Decode(u[:], b)
+	if err != nil {
+		return u, errUUIDFormat
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[:8], u[:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// Return c.Param[i] as UUID.
+func (c *Context) ParamUUID(i int) (UUID, error) {
+	s, err := c.param(i, "uuid")
+	if err != nil {
+		return UUID{}, err
+	}
+	u, err := ParseUUID(s)
+	if err != nil {
+		return u, &ParamError{Index: i, Value: s, Type: "uuid", Err: err}
+	}
+	return u, nil
+}
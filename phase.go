@@ -0,0 +1,246 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Phases of ServeHTTP after the before chain.
+// They run in order set by SetPhaseOrder, the first phase which handles the request stops the rest.
+// Default order: PhasePreflight, PhaseMatch, PhaseAutoOptions, PhaseMethodNotAllowed, PhaseNotfound.
+type Phase int
+
+const (
+	// Handle CORS preflight request by CORS set by SetCORS.
+	// For other requests, CORS headers are set and the next phase runs.
+	PhasePreflight Phase = iota
+	// Redirect by Router.Redirects, or match route and call its handlers.
+	PhaseMatch
+	// Response OPTIONS request of a path which has routes with Allow header, enabled by SetAutoOptions.
+	PhaseAutoOptions
+	// Response 405 with Allow header if path has routes of other methods, enabled by SetMethodNotAllowed.
+	PhaseMethodNotAllowed
+	// Call notfound chain, it always handles the request.
+	PhaseNotfound
+	phaseCount
+)
+
+var errPhaseOrder = errors.New("phase order must contain PhaseMatch and PhaseNotfound once, PhaseNotfound is the last")
+
+var defaultPhaseOrder = []Phase{PhasePreflight, PhaseMatch, PhaseAutoOptions, PhaseMethodNotAllowed, PhaseNotfound}
+
+// Set the precedence of phases.
+// It must contain PhaseMatch and PhaseNotfound, and PhaseNotfound must be the last.
+// Phases not in order are skipped.
+func (r *Router) SetPhaseOrder(order ...Phase) error {
+	var count [phaseCount]int
+	for _, p := range order {
+		if p < 0 || p >= phaseCount {
+			return errPhaseOrder
+		}
+		count[p]++
+		if count[p] > 1 {
+			return errPhaseOrder
+		}
+	}
+	if count[PhaseMatch] != 1 || len(order) < 1 || order[len(order)-1] != PhaseNotfound {
+		return errPhaseOrder
+	}
+	r.phases = append([]Phase(nil), order...)
+	return nil
+}
+
+// Override the default response of phase by funcs.
+// PhasePreflight: called instead of CORS.Handle for preflight requests.
+// PhaseAutoOptions and PhaseMethodNotAllowed: called after Allow header is set, instead of writing 204 or 405.
+// PhaseNotfound: same as SetNotfound.
+// PhaseMatch can not be overridden.
+func (r *Router) SetPhaseHandler(phase Phase, funcs ...HandlerFunc) {
+	if phase == PhaseNotfound {
+		r.notfound = funcs
+		return
+	}
+	if phase >= 0 && phase < phaseCount && phase != PhaseMatch {
+		r.phaseHandlers[phase] = funcs
+	}
+}
+
+// Set CORS handled in PhasePreflight, nil to disable.
+func (r *Router) SetCORS(cors *CORS) {
+	r.cors = cors
+}
+
+// Enable PhaseAutoOptions.
+func (r *Router) SetAutoOptions(enable bool) {
+	r.autoOptions = enable
+}
+
+// Enable PhaseMethodNotAllowed.
+func (r *Router) SetMethodNotAllowed(enable bool) {
+	r.methodNotAllowed = enable
+}
+
+// Run phases after before chain.
+func (r *Router) servePhases(c *Context) {
+	order := r.phases
+	if order == nil {
+		order = defaultPhaseOrder
+	}
+	for _, p := range order {
+		var handled bool
+		switch p {
+		case PhasePreflight:
+			handled = r.servePreflight(c)
+		case PhaseMatch:
+			handled = r.serveMatch(c)
+		case PhaseAutoOptions:
+			handled = r.serveAutoOptions(c)
+		case PhaseMethodNotAllowed:
+			handled = r.serveMethodNotAllowed(c)
+		case PhaseNotfound:
+			c.notFound = true
+			r.callChain(c, r.notfound)
+			handled = true
+		}
+		if handled {
+			return
+		}
+	}
+}
+
+// Call funcs until one returns false.
+func (r *Router) callChain(c *Context, funcs []HandlerFunc) {
+	c.run(funcs, nil)
+}
+
+func (r *Router) servePreflight(c *Context) bool {
+	if r.cors == nil {
+		return false
+	}
+	preflight := c.Req.Method == http.MethodOptions && c.Req.Header.Get("Origin") != "" &&
+		c.Req.Header.Get("Access-Control-Request-Method") != ""
+	if preflight && len(r.phaseHandlers[PhasePreflight]) > 0 {
+		r.callChain(c, r.phaseHandlers[PhasePreflight])
+		return true
+	}
+	return !r.cors.Handle(c)
+}
+
+func (r *Router) serveMatch(c *Context) bool {
+	if redirects := r.loadRedirects(); redirects != nil && redirects.serve(c) {
+		return true
+	}
+	for {
+		route, handlers, location := r.matchRoute(c)
+		if location != "" {
+			c.Param = c.Param[:0]
+			redirectSlash(c, location)
+			return true
+		}
+		if route == nil {
+			c.Param = c.Param[:0]
+			return false
+		}
+		c.route = route
+		c.logger = nil
+		if r.stats != nil {
+			r.stats.begin(c)
+		}
+		if r.fallThrough && len(c.tried) < 1 {
+			c.fallHeader = c.Res.Header().Clone()
+		}
+		route.setHeader(c.Res.Header())
+		route.setDeprecationHeader(c.Res.Header())
+		if !route.checkCountry(c) || !route.checkExpectContinue(c) {
+			return true
+		}
+		if r.compression != nil && !route.meta.noCompression && compressRequest(c.Req) {
+			c.compress.reset(c.res.ResponseWriter, r.compression)
+			c.res.ResponseWriter = &c.compress
+		}
+		c.res.limit = route.meta.maxResponseSize
+		c.res.limitPolicy = route.meta.responseSizePolicy
+		r.callPool(c, route, handlers)
+		c.logResponseSize()
+		// Try next matching route.
+		if !r.fallNext(c) {
+			return true
+		}
+	}
+}
+
+// Return the matched route and its handlers, or the location to redirect.
+func (r *Router) matchRoute(c *Context) (*Route, []HandlerFunc, string) {
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	route := r.match(c)
+	if route == nil {
+		c.Param = c.Param[:0]
+		var location string
+		route, location = r.matchAlternative(c)
+		if route == nil {
+			return nil, nil, location
+		}
+	}
+	route = route.resolveAlias()
+	return route, route.Handler, ""
+}
+
+// Return methods which have routes matched by request path, joined by ", ".
+func (r *Router) allowMethods(c *Context) string {
+	var allow []string
+	get := false
+	for _, m := range methods {
+		if r.lookup(c, m) == nil {
+			continue
+		}
+		allow = append(allow, m)
+		get = get || m == http.MethodGet
+	}
+	if len(allow) < 1 {
+		return ""
+	}
+	if get && r.headFallback && r.lookup(c, http.MethodHead) == nil {
+		allow = append(allow, http.MethodHead)
+	}
+	if r.autoOptions && r.lookup(c, http.MethodOptions) == nil {
+		allow = append(allow, http.MethodOptions)
+	}
+	return strings.Join(allow, ", ")
+}
+
+func (r *Router) serveAutoOptions(c *Context) bool {
+	if !r.autoOptions || c.Req.Method != http.MethodOptions {
+		return false
+	}
+	allow := r.allowMethods(c)
+	if allow == "" {
+		return false
+	}
+	c.Res.Header().Set("Allow", allow)
+	if len(r.phaseHandlers[PhaseAutoOptions]) > 0 {
+		r.callChain(c, r.phaseHandlers[PhaseAutoOptions])
+		return true
+	}
+	c.Res.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (r *Router) serveMethodNotAllowed(c *Context) bool {
+	if !r.methodNotAllowed {
+		return false
+	}
+	allow := r.allowMethods(c)
+	if allow == "" {
+		return false
+	}
+	c.Res.Header().Set("Allow", allow)
+	if len(r.phaseHandlers[PhaseMethodNotAllowed]) > 0 {
+		r.callChain(c, r.phaseHandlers[PhaseMethodNotAllowed])
+		return true
+	}
+	c.Res.WriteHeader(http.StatusMethodNotAllowed)
+	return true
+}
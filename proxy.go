@@ -0,0 +1,142 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+)
+
+var errNoUpstream = errors.New("proxy: no upstream")
+
+// Reverse proxy requests to upstreams, balanced in round robin.
+// Request id and trace headers of current request are injected into outbound requests.
+type ProxyHandler struct {
+	// Upstream base URLs.
+	Upstream []*url.URL
+	// If it is nil, use Transport of router's Client.
+	Transport http.RoundTripper
+	// Circuit breakers of upstreams, same index as Upstream.
+	breakers []*Breaker
+	next     uint32
+	// Hedged requests, nil if disabled.
+	hedge *proxyHedge
+}
+
+// Return a ProxyHandler with upstream URLs.
+func NewProxyHandler(upstream ...string) (*ProxyHandler, error) {
+	if len(upstream) < 1 {
+		return nil, errNoUpstream
+	}
+	h := new(ProxyHandler)
+	for _, s := range upstream {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		h.Upstream = append(h.Upstream, u)
+	}
+	return h, nil
+}
+
+// Create a circuit breaker for each upstream by newBreaker.
+// Upstreams whose breaker is open are skipped, if all are open, response 503.
+// Transport error and response status code >= 500 are counted as failure.
+func (h *ProxyHandler) SetBreaker(newBreaker func(upstream *url.URL) *Breaker) {
+	h.breakers = make([]*Breaker, len(h.Upstream))
+	for i, u := range h.Upstream {
+		h.breakers[i] = newBreaker(u)
+	}
+}
+
+// Return next upstream index allowed by breaker, -1 if none.
+func (h *ProxyHandler) upstream() int {
+	n := int(atomic.AddUint32(&h.next, 1) - 1)
+	if len(h.breakers) < 1 {
+		return n % len(h.Upstream)
+	}
+	for i := 0; i < len(h.Upstream); i++ {
+		j := (n + i) % len(h.Upstream)
+		if h.breakers[j].Allow() {
+			return j
+		}
+	}
+	return -1
+}
+
+// Return Transport, or transport of router's Client.
+func (h *ProxyHandler) transport(c *Context) http.RoundTripper {
+	if h.Transport != nil {
+		return h.Transport
+	}
+	return c.router.transport()
+}
+
+// Return another upstream index allowed by breaker for hedged request, -1 if none.
+func (h *ProxyHandler) hedgeUpstream(primary int) int {
+	if len(h.Upstream) < 2 {
+		return -1
+	}
+	n := int(atomic.AddUint32(&h.next, 1) - 1)
+	for i := 0; i < len(h.Upstream); i++ {
+		j := (n + i) % len(h.Upstream)
+		if j == primary {
+			continue
+		}
+		if len(h.breakers) < 1 || h.breakers[j].Allow() {
+			return j
+		}
+	}
+	return -1
+}
+
+// Can be use as HandlerFunc.
+func (h *ProxyHandler) Handle(c *Context) bool {
+	if len(h.Upstream) < 1 {
+		c.Logger().Error("proxy", "error", errNoUpstream)
+		c.Res.WriteHeader(http.StatusBadGateway)
+		return true
+	}
+	i := h.upstream()
+	if i < 0 {
+		c.Res.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	}
+	target := h.Upstream[i]
+	failed := false
+	var hedge *hedgeTransport
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			c.propagate(r.Out)
+		},
+		Transport: h.transport(c),
+		ModifyResponse: func(res *http.Response) error {
+			failed = res.StatusCode >= http.StatusInternalServerError
+			return nil
+		},
+		ErrorHandler: func(res http.ResponseWriter, req *http.Request, err error) {
+			failed = true
+			c.Logger().Error("proxy", "upstream", target.String(), "error", err)
+			res.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	if h.hedge != nil && canHedge(c.Req) {
+		hedge = &hedgeTransport{handler: h, c: c, in: c.Req, primary: i}
+		proxy.Transport = hedge
+	}
+	proxy.ServeHTTP(c.Res, c.Req)
+	if len(h.breakers) > 0 {
+		if hedge == nil {
+			h.breakers[i].Done(failed)
+			return true
+		}
+		h.breakers[i].Done(hedge.winner < 0 || (hedge.winner == i && failed))
+		if hedge.hedge >= 0 {
+			h.breakers[hedge.hedge].Done(hedge.winner < 0 || (hedge.winner == hedge.hedge && failed))
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errProxyHeader = errors.New("invalid proxy protocol header")
+	// Signature of PROXY protocol v2.
+	proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// Default timeout of reading PROXY protocol header.
+const proxyHeaderTimeout = 10 * time.Second
+
+// Listener parses PROXY protocol v1/v2 header of accepted connections,
+// RemoteAddr of connections is the client address in header.
+type proxyListener struct {
+	net.Listener
+	// Return true if header of connection from addr should be parsed, nil trusts all.
+	trusted func(addr net.Addr) bool
+	timeout time.Duration
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if l.trusted != nil && !l.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, timeout: l.timeout}, nil
+}
+
+// Connection with PROXY protocol header, header is read at the first Read or RemoteAddr.
+// net/http calls RemoteAddr first in the connection goroutine, so a slow client does not block Accept,
+// and the read deadline of header is cleared before net/http sets its own.
+type proxyConn struct {
+	net.Conn
+	timeout time.Duration
+	once    sync.Once
+	reader  *bufio.Reader
+	remote  net.Addr
+	err     error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReader(c.Conn)
+		c.remote = c.Conn.RemoteAddr()
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		addr, err := readProxyHeader(c.reader)
+		if err != nil {
+			c.err = err
+			c.Conn.Close()
+			return
+		}
+		if addr != nil {
+			c.remote = addr
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remote
+}
+
+// Read PROXY protocol header, return the source address, nil if it is LOCAL or UNKNOWN.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(proxySignature))
+	if err == nil && bytes.Equal(b, proxySignature) {
+		return readProxyHeaderV2(r)
+	}
+	b, err = r.Peek(5)
+	if err != nil || string(b) != "PROXY" {
+		return nil, errProxyHeader
+	}
+	// Max length of v1 header is 107.
+	var line []byte
+	for len(line) < 107 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errProxyHeader
+	}
+	// PROXY TCP4 src dst srcPort dstPort
+	field := strings.Fields(string(line[:len(line)-2]))
+	if len(field) >= 2 && field[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(field) != 6 || (field[1] != "TCP4" && field[1] != "TCP6") {
+		return nil, errProxyHeader
+	}
+	ip := net.ParseIP(field[2])
+	port, err := strconv.ParseUint(field[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, errProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	var header [16]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, errProxyHeader
+	}
+	data := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	// LOCAL command, such as health check of proxy.
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch header[13] {
+	case 0x11:
+		// TCP over IPv4, src(4) dst(4) srcPort(2) dstPort(2).
+		if len(data) < 12 {
+			return nil, errProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(data[:4]), Port: int(binary.BigEndian.Uint16(data[8:]))}, nil
+	case 0x21:
+		// TCP over IPv6, src(16) dst(16) srcPort(2) dstPort(2).
+		if len(data) < 36 {
+			return nil, errProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(data[:16]), Port: int(binary.BigEndian.Uint16(data[32:]))}, nil
+	}
+	// Unsupported family, use address of connection.
+	return nil, nil
+}
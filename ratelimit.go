@@ -0,0 +1,88 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limit requests per key in a time window, response 429 if exceeded.
+// It emits RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset (draft IETF) and Retry-After headers.
+type RateLimiter struct {
+	// Max requests in a window.
+	Limit int64
+	// Window duration.
+	Window time.Duration
+	// Use sliding window instead of fixed window.
+	Sliding bool
+	// Return the key of request, default is c.ClientIP(). Key is prefixed by c.Tenant() if it is set.
+	Key func(*Context) string
+	// Use legacy X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset header names.
+	LegacyHeaders bool
+	// Counter storage, use a MemoryStore if it is nil.
+	// Use a shared store such as RedisStore for multiple router instances.
+	Store LimiterStore
+	once  sync.Once
+}
+
+// Return a RateLimiter allows limit requests per window.
+func NewRateLimiter(limit int64, window time.Duration) *RateLimiter {
+	l := new(RateLimiter)
+	l.Limit = limit
+	l.Window = window
+	return l
+}
+
+// Increase the counter of key, return count and duration until reset.
+func (l *RateLimiter) incr(c *Context, key string) (int64, time.Duration, error) {
+	l.once.Do(func() {
+		if l.Store == nil {
+			l.Store = NewMemoryStore()
+		}
+	})
+	if l.Sliding {
+		n, err := l.Store.SlidingWindow(c.Req.Context(), key, time.Now(), l.Window, l.Limit)
+		return n, l.Window, err
+	}
+	return l.Store.Incr(c.Req.Context(), key, l.Window)
+}
+
+// Can be use as HandlerFunc.
+// If the store fails, the request is allowed.
+func (l *RateLimiter) Handle(c *Context) bool {
+	var key string
+	if l.Key != nil {
+		key = l.Key(c)
+	} else {
+		key = c.ClientIP()
+	}
+	key = c.tenantKey(key)
+	count, reset, err := l.incr(c, key)
+	if err != nil {
+		c.Logger().Error("rate limiter", "key", key, "error", err)
+		return true
+	}
+	remaining := l.Limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	// Seconds until reset, round up.
+	seconds := int64((reset + time.Second - 1) / time.Second)
+	header := c.Res.Header()
+	if l.LegacyHeaders {
+		header.Set("X-RateLimit-Limit", strconv.FormatInt(l.Limit, 10))
+		header.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+seconds, 10))
+	} else {
+		header.Set("RateLimit-Limit", strconv.FormatInt(l.Limit, 10))
+		header.Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		header.Set("RateLimit-Reset", strconv.FormatInt(seconds, 10))
+	}
+	if count > l.Limit {
+		header.Set("Retry-After", strconv.FormatInt(seconds, 10))
+		c.Res.WriteHeader(http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
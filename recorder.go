@@ -0,0 +1,207 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Value of redacted header.
+const redactedValue = "[REDACTED]"
+
+// A recorded request/response pair.
+type Record struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	// Request body, truncated to Recorder.MaxBodySize.
+	Body []byte `json:"body"`
+	// Response status code.
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader"`
+	// Response body, truncated to Recorder.MaxBodySize.
+	ResponseBody []byte        `json:"responseBody"`
+	Latency      time.Duration `json:"latency"`
+}
+
+// Return a new request built from record, used to replay.
+func (r *Record) Request() (*http.Request, error) {
+	req, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	return req, nil
+}
+
+// Response of Replay, implements http.ResponseWriter.
+type ReplayResponse struct {
+	// Status code, 200 if handler does not call WriteHeader.
+	Code   int
+	Body   bytes.Buffer
+	header http.Header
+}
+
+func (w *ReplayResponse) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *ReplayResponse) WriteHeader(statusCode int) {
+	if w.Code == 0 {
+		w.Code = statusCode
+	}
+}
+
+func (w *ReplayResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.Body.Write(b)
+}
+
+// Replay the recorded request against h, return the response.
+func Replay(h http.Handler, r *Record) (*ReplayResponse, error) {
+	req, err := r.Request()
+	if err != nil {
+		return nil, err
+	}
+	res := new(ReplayResponse)
+	h.ServeHTTP(res, req)
+	if res.Code == 0 {
+		res.Code = http.StatusOK
+	}
+	return res, nil
+}
+
+// Capture sanitized request/response pairs into a ring buffer and/or writer.
+type Recorder struct {
+	// Max bytes of request and response body to keep.
+	MaxBodySize int
+	// Header names whose values are replaced by "[REDACTED]".
+	RedactHeader []string
+	// If it is not nil, each record is written as a JSON line.
+	Writer io.Writer
+	mutex  sync.Mutex
+	ring   []Record
+	next   int
+	full   bool
+}
+
+// Return a Recorder keeps the latest size records in memory.
+// If size < 1, records are only written to Writer.
+func NewRecorder(size int) *Recorder {
+	r := new(Recorder)
+	r.MaxBodySize = 4096
+	r.RedactHeader = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+	if size > 0 {
+		r.ring = make([]Record, size)
+	}
+	return r
+}
+
+// Return records in the ring buffer, oldest first.
+func (r *Recorder) Records() []Record {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var records []Record
+	if r.full {
+		records = append(records, r.ring[r.next:]...)
+	}
+	return append(records, r.ring[:r.next]...)
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range r.RedactHeader {
+		if _, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			h.Set(k, redactedValue)
+		}
+	}
+	return h
+}
+
+// Read request body prefix, and keep the rest readable for handlers.
+func (r *Recorder) begin(c *Context) {
+	c.recordBody = c.recordBody[:0]
+	c.res.body = c.res.body[:0]
+	c.res.bodyMax = r.MaxBodySize
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return
+	}
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, c.Req.Body, int64(r.MaxBodySize))
+	c.recordBody = append(c.recordBody, buf.Bytes()...)
+	switch err {
+	case nil:
+		c.Req.Body = &recordBody{Reader: io.MultiReader(&buf, c.Req.Body), Closer: c.Req.Body}
+	case io.EOF:
+		c.Req.Body = &recordBody{Reader: &buf, Closer: c.Req.Body}
+	default:
+		// Handlers get the error after the read bytes, such as *http.MaxBytesError.
+		c.Logger().Warn("recorder", "error", err)
+		c.Req.Body = &recordBody{Reader: io.MultiReader(&buf, &errorReader{err: err}), Closer: c.Req.Body}
+	}
+}
+
+// Return err of Read.
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read(b []byte) (int, error) {
+	return 0, r.err
+}
+
+// Save the record.
+func (r *Recorder) end(c *Context) {
+	var rec Record
+	rec.Time = c.start
+	rec.Method = c.Req.Method
+	rec.URL = c.Req.URL.String()
+	rec.Header = r.redact(c.Req.Header)
+	rec.Body = append([]byte(nil), c.recordBody...)
+	rec.Status = c.Status()
+	rec.ResponseHeader = r.redact(c.res.Header())
+	body := c.res.body
+	if len(body) > r.MaxBodySize {
+		body = body[:r.MaxBodySize]
+	}
+	rec.ResponseBody = append([]byte(nil), body...)
+	if c.router.redactor != nil {
+		rec.Body = c.router.redactor.Redact(rec.Body)
+		rec.ResponseBody = c.router.redactor.Redact(rec.ResponseBody)
+	}
+	rec.Latency = time.Since(c.start)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.ring) > 0 {
+		r.ring[r.next] = rec
+		r.next++
+		if r.next == len(r.ring) {
+			r.next = 0
+			r.full = true
+		}
+	}
+	if r.Writer != nil {
+		err := json.NewEncoder(r.Writer).Encode(&rec)
+		if err != nil && c.router != nil {
+			c.router.Logger().Error("recorder", "error", err)
+		}
+	}
+}
+
+// Request body that has been partially read by Recorder.
+type recordBody struct {
+	io.Reader
+	io.Closer
+}
+
+// Set the recorder, nil to disable recording.
+func (r *Router) SetRecorder(recorder *Recorder) {
+	r.recorder = recorder
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+)
+
+// Debug page template, only used in dev mode.
+var debugPageTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>500 panic: {{.Value}}</title>
+<style>
+body{font-family:sans-serif;margin:2em;}
+pre{background:#f4f4f4;padding:1em;overflow:auto;}
+td{padding:0 1em 0 0;vertical-align:top;}
+</style>
+</head>
+<body>
+<h1>panic: {{.Value}}</h1>
+<h2>Request</h2>
+<table>
+<tr><td>Method</td><td>{{.Method}}</td></tr>
+<tr><td>URL</td><td>{{.URL}}</td></tr>
+<tr><td>Route</td><td>{{.Route}}</td></tr>
+<tr><td>Param</td><td>{{.Param}}</td></tr>
+<tr><td>RemoteAddr</td><td>{{.RemoteAddr}}</td></tr>
+</table>
+<h2>Header</h2>
+<table>
+{{range $k, $v := .Header}}<tr><td>{{$k}}</td><td>{{$v}}</td></tr>
+{{end}}</table>
+<h2>Stack</h2>
+<pre>{{.Stack}}</pre>
+</body>
+</html>
+`))
+
+// Data of debug page.
+type debugPage struct {
+	Value      string
+	Method     string
+	URL        string
+	Route      string
+	Param      []string
+	RemoteAddr string
+	Header     http.Header
+	Stack      string
+}
+
+// Return the value of recover() if handler chain panic, else return nil.
+func (c *Context) PanicValue() interface{} {
+	return c.panicValue
+}
+
+// Return the goroutine stack if handler chain panic, else return nil.
+func (c *Context) PanicStack() []byte {
+	return c.panicStack
+}
+
+// Recovery response status code 500.
+// If router is in dev mode, it renders a debug page with the panic value, stack, request details and matched route.
+func Recovery(c *Context) bool {
+	if c.router == nil || !c.router.devMode {
+		c.Res.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	var page debugPage
+	page.Value = fmt.Sprint(c.panicValue)
+	page.Method = c.Req.Method
+	page.URL = c.Req.URL.String()
+	if c.route != nil {
+		page.Route = c.route.path
+	}
+	page.Param = c.Param
+	page.RemoteAddr = c.Req.RemoteAddr
+	page.Header = c.Req.Header
+	page.Stack = string(c.panicStack)
+	c.Res.Header().Set("Content-Type", ContentTypeHTML)
+	c.Res.WriteHeader(http.StatusInternalServerError)
+	return debugPageTemplate.Execute(c.Res, &page) == nil
+}
+
+// Recover from handler chain panic, call recovery and after chain.
+// After chain is not called again if it panics, http.ErrAbortHandler is not recovered.
+func (r *Router) recover(c *Context) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		c.cancelTimeout()
+		panic(v)
+	}
+	c.panicValue = v
+	c.panicStack = debug.Stack()
+	c.endStream()
+	c.finishing = true
+	c.Logger().Error("panic", "value", v, "stack", string(c.panicStack))
+	// Recovery.
+	c.run(r.recovery, nil)
+	r.handleAfter(c)
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Mask JSON fields before body is logged, audited or recorded.
+type Redactor struct {
+	// JSON paths of masked fields.
+	// A name without "." such as "password" matches the field at any depth.
+	// A dotted path such as "user.card.number" matches from root,
+	// "*" matches any field name or array element.
+	Paths []string
+	// Mask strings which look like payment card numbers.
+	CardNumbers bool
+}
+
+// Return a Redactor masks paths and card numbers.
+func NewRedactor(paths ...string) *Redactor {
+	r := new(Redactor)
+	r.Paths = paths
+	r.CardNumbers = true
+	return r
+}
+
+// Set Redactor used by AccessLog, Auditor and Recorder, nil to disable.
+func (r *Router) SetRedactor(redactor *Redactor) {
+	r.redactor = redactor
+}
+
+// Return a copy of JSON data with fields masked.
+// If data is not JSON, it is returned unchanged.
+func (r *Redactor) Redact(data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil || dec.More() {
+		return data
+	}
+	v = r.RedactValue(v)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return b
+}
+
+// Return v with fields masked, maps and slices are modified in place.
+func (r *Redactor) RedactValue(v interface{}) interface{} {
+	paths := make([][]string, 0, len(r.Paths))
+	for _, p := range r.Paths {
+		paths = append(paths, strings.Split(p, "."))
+	}
+	return r.redact(v, nil, paths)
+}
+
+func (r *Redactor) redact(v interface{}, path []string, paths [][]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			p := append(path, k)
+			if matchRedactPath(paths, p) {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = r.redact(e, p, paths)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = r.redact(e, append(path, "*"), paths)
+		}
+	case string:
+		if r.CardNumbers && isCardNumber(v) {
+			return redactedValue
+		}
+	}
+	return v
+}
+
+// Return true if path matches any of paths.
+func matchRedactPath(paths [][]string, path []string) bool {
+	for _, p := range paths {
+		if len(p) == 1 {
+			if strings.EqualFold(p[0], path[len(path)-1]) {
+				return true
+			}
+			continue
+		}
+		if len(p) != len(path) {
+			continue
+		}
+		i := 0
+		for ; i < len(p); i++ {
+			if p[i] != "*" && path[i] != "*" && !strings.EqualFold(p[i], path[i]) {
+				break
+			}
+		}
+		if i == len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if s is 13-19 digits, spaces or dashes allowed, and passes Luhn check.
+func isCardNumber(s string) bool {
+	n, sum := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
@@ -0,0 +1,291 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A redirect rule.
+// Source is a route path such as "/old/:id/*rest".
+// Target can use params of Source by name or index, such as "/new/:id/*rest" or "/new/:0/*1".
+type Redirect struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Status code, 301, 302, 307 or 308.
+	Code int `json:"code"`
+}
+
+// Bulk redirects, rules can be updated at runtime.
+// Router checks it before matching routes, see Router.AddRedirects.
+type Redirects struct {
+	mutex sync.RWMutex
+	root  *rootRoute
+	rules map[string]*Redirect
+}
+
+// Return an empty Redirects.
+func NewRedirects() *Redirects {
+	r := new(Redirects)
+	r.root = new(rootRoute)
+	r.rules = make(map[string]*Redirect)
+	return r
+}
+
+// Add or update rules, source -> target, with status code.
+func (r *Redirects) Set(rules map[string]string, code int) error {
+	list := make([]*Redirect, 0, len(rules))
+	for source, target := range rules {
+		list = append(list, &Redirect{Source: source, Target: target, Code: code})
+	}
+	return r.add(list, false)
+}
+
+// Remove rules by source.
+func (r *Redirects) Remove(sources ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, s := range sources {
+		if _, ok := r.rules[s]; ok {
+			delete(r.rules, s)
+			// Keep sub routes of other rules.
+			if route := r.root.Find(s); route != nil {
+				route.Handler = nil
+			}
+		}
+	}
+}
+
+// Return all rules sorted by source.
+func (r *Redirects) Rules() []Redirect {
+	r.mutex.RLock()
+	list := make([]Redirect, 0, len(r.rules))
+	for _, v := range r.rules {
+		list = append(list, *v)
+	}
+	r.mutex.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Source < list[j].Source
+	})
+	return list
+}
+
+// Add or update rules from file, code is used if a line has no code.
+// Each line is "source target [code]", empty lines and lines start with '#' are ignored.
+func (r *Redirects) Load(file string, code int) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var list []*Redirect
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		field := strings.Fields(line)
+		if len(field) < 2 || len(field) > 3 {
+			return fmt.Errorf("%s:%d invalid redirect %q", file, n, line)
+		}
+		rule := &Redirect{Source: field[0], Target: field[1], Code: code}
+		if len(field) == 3 {
+			rule.Code, err = strconv.Atoi(field[2])
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid code %q", file, n, field[2])
+			}
+		}
+		list = append(list, rule)
+	}
+	return r.add(list, false)
+}
+
+// Validate and add rules, all rules are replaced if replace is true.
+// Rules are built into a new tree, which replaces the current one only if all rules are valid.
+func (r *Redirects) add(list []*Redirect, replace bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	rules := make(map[string]*Redirect)
+	if !replace {
+		for source, rule := range r.rules {
+			rules[source] = rule
+		}
+	}
+	for _, rule := range list {
+		rules[rule.Source] = rule
+	}
+	root := new(rootRoute)
+	for _, rule := range rules {
+		h, err := rule.handler()
+		if err != nil {
+			return err
+		}
+		route, err := root.Add(rule.Source)
+		if err != nil {
+			return err
+		}
+		route.Handler = []HandlerFunc{h}
+	}
+	r.root = root
+	r.rules = rules
+	return nil
+}
+
+// Return a HandlerFunc writes redirect response.
+func (rule *Redirect) handler() (HandlerFunc, error) {
+	switch rule.Code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, fmt.Errorf("redirect %s invalid code %d", rule.Source, rule.Code)
+	}
+	names := paramNames(rule.Source)
+	if names == nil {
+		// All unnamed, use index only.
+		for _, s := range strings.Split(rule.Source, "/") {
+			if s != "" && (s[0] == ':' || s[0] == '*') {
+				names = append(names, "")
+			}
+		}
+	}
+	// Index of param for each segment of target, -1 is literal.
+	part := strings.Split(rule.Target, "/")
+	index := make([]int, len(part))
+	wildcard := make([]bool, len(part))
+	for i, s := range part {
+		index[i] = -1
+		if s == "" || (s[0] != ':' && s[0] != '*') {
+			continue
+		}
+		wildcard[i] = s[0] == '*'
+		index[i] = redirectParam(names, s[1:])
+		if index[i] < 0 {
+			return nil, fmt.Errorf("redirect %s target %s has unknown param %s", rule.Source, rule.Target, s)
+		}
+	}
+	code := rule.Code
+	return func(c *Context) bool {
+		var str strings.Builder
+		for i, s := range part {
+			if i > 0 {
+				str.WriteByte('/')
+			}
+			if index[i] >= 0 && index[i] < len(c.Param) {
+				s = escapeRedirectParam(c.Param[index[i]], wildcard[i])
+			}
+			str.WriteString(s)
+		}
+		location := str.String()
+		if c.Req.URL.RawQuery != "" && !strings.Contains(location, "?") {
+			location += "?" + c.Req.URL.RawQuery
+		}
+		c.Res.Header().Set("Location", location)
+		c.Res.WriteHeader(code)
+		return false
+	}, nil
+}
+
+// Escape decoded param value for Location, '/' of wildcard value is kept.
+func escapeRedirectParam(s string, wildcard bool) string {
+	if !wildcard {
+		return url.PathEscape(s)
+	}
+	part := strings.Split(s, "/")
+	for i := range part {
+		part[i] = url.PathEscape(part[i])
+	}
+	return strings.Join(part, "/")
+}
+
+// Return index of param by name or index string, -1 if not found.
+func redirectParam(names []string, name string) int {
+	for i, s := range names {
+		if s != "" && s == name {
+			return i
+		}
+	}
+	i, err := strconv.Atoi(name)
+	if err != nil || i < 0 || i >= len(names) {
+		return -1
+	}
+	return i
+}
+
+// Try to redirect request, return true if a rule matched.
+func (r *Redirects) serve(c *Context) bool {
+	n := len(c.Param)
+	r.mutex.RLock()
+	route := r.root.match(c, c.Req.URL.Path, false, matchHandler)
+	var h HandlerFunc
+	if route != nil {
+		h = route.Handler[0]
+	}
+	r.mutex.RUnlock()
+	if h == nil {
+		return false
+	}
+	h(c)
+	c.Param = c.Param[:n]
+	return true
+}
+
+// Can be use as HandlerFunc, admin API of rules.
+// GET: response rules in JSON.
+// POST: add or update rules, body is JSON array of Redirect.
+// PUT: replace all rules, body is JSON array of Redirect.
+// DELETE: remove rules by query "source".
+func (r *Redirects) AdminHandler(c *Context) bool {
+	switch c.Req.Method {
+	case http.MethodGet:
+		c.WriteJSON(http.StatusOK, r.Rules())
+	case http.MethodPost, http.MethodPut:
+		var list []*Redirect
+		err := json.NewDecoder(c.Req.Body).Decode(&list)
+		if err == nil {
+			err = r.add(list, c.Req.Method == http.MethodPut)
+		}
+		if err != nil {
+			return c.BadRequest(err)
+		}
+		c.Res.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		r.Remove(c.Req.URL.Query()["source"]...)
+		c.Res.WriteHeader(http.StatusNoContent)
+	default:
+		c.Res.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		c.Res.WriteHeader(http.StatusMethodNotAllowed)
+	}
+	return true
+}
+
+// Add or update redirect rules, source -> target, with status code.
+// Rules are checked before matching routes, and can be updated by Router.Redirects() at runtime.
+// Example: router.AddRedirects(map[string]string{"/blog/:id": "/posts/:id"}, http.StatusMovedPermanently)
+func (r *Router) AddRedirects(rules map[string]string, code int) error {
+	return r.Redirects().Set(rules, code)
+}
+
+// Return Redirects of router, it is created if not exists, call it before Freeze.
+func (r *Router) Redirects() *Redirects {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.redirects == nil {
+		r.redirects = NewRedirects()
+	}
+	return r.redirects
+}
+
+// Return Redirects of router, nil if not created.
+func (r *Router) loadRedirects() *Redirects {
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	return r.redirects
+}
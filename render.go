@@ -0,0 +1,153 @@
+package router
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+var errNoRenderer = errors.New("router: renderer is not set")
+
+// Render html templates in fs.FS with layout.
+// The layout is parsed with each page, page overrides blocks of layout,
+// such as {{define "content"}}...{{end}} in page and {{block "content" .}}{{end}} in layout.
+// Functions below are injected for each render:
+// csrfToken returns CSRFToken(c), flashes returns c.Flashes(),
+// asset returns Manifest[name] or name if not found,
+// integrity returns Integrity[name] or "" if not found, cspNonce returns c.CSPNonce().
+type Renderer struct {
+	FS fs.FS
+	// Layout template name in FS, empty means no layout.
+	Layout string
+	// Asset manifest, such as {"app.js": "app.3f2a1c.js"}.
+	Manifest map[string]string
+	// Subresource integrity of assets, such as the result of Router.WarmStatic.
+	Integrity map[string]string
+	// Return CSRF token of request.
+	CSRFToken func(*Context) string
+	// Parse templates on every render, for development.
+	Reload bool
+	mutex  sync.RWMutex
+	cache  map[string]*template.Template
+}
+
+// Return a Renderer of fsys with layout.
+func NewRenderer(fsys fs.FS, layout string) *Renderer {
+	r := new(Renderer)
+	r.FS = fsys
+	r.Layout = layout
+	r.cache = make(map[string]*template.Template)
+	return r
+}
+
+// Set Renderer used by c.Render.
+func (r *Router) SetRenderer(renderer *Renderer) {
+	r.renderer = renderer
+}
+
+// Add custom template functions, call it before first render.
+func (r *Router) AddTemplateFuncs(funcs template.FuncMap) {
+	if r.templateFuncs == nil {
+		r.templateFuncs = make(template.FuncMap)
+	}
+	for k, v := range funcs {
+		r.templateFuncs[k] = v
+	}
+}
+
+// Placeholders of per-render functions, replaced in execute.
+var renderFuncs = template.FuncMap{
+	"csrfToken": func() string { return "" },
+	"flashes":   func() []FlashMessage { return nil },
+	"asset":     func(name string) string { return name },
+	"integrity": func(name string) string { return "" },
+	"cspNonce":  func() string { return "" },
+}
+
+// Return parsed template of name, it is never executed and can be cloned.
+func (r *Renderer) template(name string, funcs template.FuncMap) (*template.Template, error) {
+	if !r.Reload {
+		r.mutex.RLock()
+		t := r.cache[name]
+		r.mutex.RUnlock()
+		if t != nil {
+			return t, nil
+		}
+	}
+	files := []string{name}
+	if r.Layout != "" {
+		files = []string{r.Layout, name}
+	}
+	t := template.New(path.Base(files[0])).Funcs(renderFuncs).Funcs(funcs)
+	t, err := t.ParseFS(r.FS, files...)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]*template.Template)
+	}
+	r.cache[name] = t
+	r.mutex.Unlock()
+	return t, nil
+}
+
+// Execute template name with data into c.Buff.
+func (r *Renderer) execute(c *Context, name string, data interface{}) error {
+	t, err := r.template(name, c.router.templateFuncs)
+	if err != nil {
+		return err
+	}
+	t, err = t.Clone()
+	if err != nil {
+		return err
+	}
+	t.Funcs(template.FuncMap{
+		"csrfToken": func() string {
+			if r.CSRFToken == nil {
+				return ""
+			}
+			return r.CSRFToken(c)
+		},
+		"flashes": c.Flashes,
+		"asset": func(name string) string {
+			if s, ok := r.Manifest[name]; ok {
+				return s
+			}
+			return name
+		},
+		"integrity": func(name string) string {
+			return r.Integrity[name]
+		},
+		"cspNonce": c.CSPNonce,
+	})
+	c.Buff.Reset()
+	return t.Execute(&c.Buff, data)
+}
+
+// Render template name with data by Renderer of router, and response with statusCode.
+// Output is minified by Minifier of router if it is set.
+// Nothing is written if it returns error.
+func (c *Context) Render(statusCode int, name string, data interface{}) error {
+	if c.router.renderer == nil {
+		return errNoRenderer
+	}
+	err := c.router.renderer.execute(c, name, data)
+	if err != nil {
+		return err
+	}
+	if m := c.router.minifier; m != nil {
+		b, err := m.Minify("text/html", c.Buff.Bytes())
+		if err != nil {
+			return err
+		}
+		c.Buff.Reset()
+		c.Buff.Write(b)
+	}
+	c.Res.Header().Set("Content-Type", ContentTypeHTML)
+	c.Res.WriteHeader(statusCode)
+	_, err = c.Res.Write(c.Buff.Bytes())
+	return err
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+var errHijack = errors.New("response writer does not implement http.Hijacker")
+
+// Wrap http.ResponseWriter, record status code and body size.
+type responseWriter struct {
+	http.ResponseWriter
+	// Status code, 0 means not written.
+	status int
+	// Body size.
+	size int64
+	// Keep the first bodyMax bytes of body if bodyMax > 0, used by Recorder.
+	body    []byte
+	bodyMax int
+	// Names of trailers declared in "Trailer" header before the first write.
+	trailers []string
+	// Max body size of route, and whether body exceeded it.
+	limit       int64
+	limitPolicy ResponseSizePolicy
+	exceeded    bool
+	// Time of the first flush, response is streaming if it is not zero.
+	streamStart time.Time
+}
+
+func (w *responseWriter) reset(res http.ResponseWriter) {
+	w.ResponseWriter = res
+	w.status = 0
+	w.size = 0
+	w.body = w.body[:0]
+	w.bodyMax = 0
+	w.trailers = w.trailers[:0]
+	w.limit = 0
+	w.exceeded = false
+	w.streamStart = time.Time{}
+}
+
+// Declare trailers, called before the first write.
+func (w *responseWriter) declareTrailers() {
+	for _, name := range w.trailers {
+		w.ResponseWriter.Header().Add("Trailer", name)
+	}
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.declareTrailers()
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	allowed, limitErr := w.checkLimit(b)
+	if allowed < 1 && limitErr != nil {
+		return 0, limitErr
+	}
+	b = b[:allowed]
+	if w.status == 0 {
+		w.declareTrailers()
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	if m := w.bodyMax - len(w.body); m > 0 {
+		if m > n {
+			m = n
+		}
+		w.body = append(w.body, b[:m]...)
+	}
+	if err == nil {
+		err = limitErr
+	}
+	return n, err
+}
+
+// Implements http.Flusher.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.declareTrailers()
+			w.status = http.StatusOK
+		}
+		if w.streamStart.IsZero() {
+			w.streamStart = time.Now()
+		}
+		f.Flush()
+	}
+}
+
+// Implements http.Hijacker.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errHijack
+}
+
+// Implements http.Pusher.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Use by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Return response status code, http.StatusOK if not written.
+func (c *Context) Status() int {
+	if c.res.status == 0 {
+		return http.StatusOK
+	}
+	return c.res.status
+}
+
+// Return whether response status code has been written.
+func (c *Context) Written() bool {
+	return c.res.status != 0
+}
+
+// Return size of response body has been written.
+func (c *Context) Size() int64 {
+	return c.res.size
+}
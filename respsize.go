@@ -0,0 +1,54 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Returned by Write of response if response body exceeds the limit of route, see Route.SetMaxResponseSize.
+var ErrResponseTooLarge = errors.New("response body exceeds the limit of route")
+
+// What to do if response body exceeds the limit of route.
+type ResponseSizePolicy int
+
+const (
+	// Write bytes up to the limit, then Write returns ErrResponseTooLarge.
+	ResponseTruncate ResponseSizePolicy = iota
+	// Write nothing of the exceeding Write and return ErrResponseTooLarge,
+	// response 500 if status code is not written yet.
+	ResponseError
+	// Write anyway, only log.
+	ResponseLog
+)
+
+// Limit bytes of response body of route, 0 is no limit. A warning is logged once a response exceeds it.
+// It protects clients from handlers that accidentally stream unbounded data.
+func (r *Route) SetMaxResponseSize(n int64, policy ResponseSizePolicy) {
+	r.meta.maxResponseSize = n
+	r.meta.responseSizePolicy = policy
+}
+
+// Check limit before writing b, return bytes of b can be written and error.
+func (w *responseWriter) checkLimit(b []byte) (int, error) {
+	if w.limit <= 0 || w.size+int64(len(b)) <= w.limit {
+		return len(b), nil
+	}
+	w.exceeded = true
+	switch w.limitPolicy {
+	case ResponseTruncate:
+		return int(w.limit - w.size), ErrResponseTooLarge
+	case ResponseError:
+		if w.status == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return 0, ErrResponseTooLarge
+	}
+	return len(b), nil
+}
+
+// Log response exceeded the limit of route.
+func (c *Context) logResponseSize() {
+	if c.res.exceeded {
+		c.Logger().Warn("response too large", "limit", c.res.limit, "size", c.res.size)
+	}
+}
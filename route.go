@@ -2,8 +2,12 @@ package router
 
 import (
 	"fmt"
+	"math/rand"
+	"net/http"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Return different sub string of s1 and s2.
@@ -28,7 +32,8 @@ func diffString(s1, s2 string) (string, string) {
 // Split static route and param route.
 // Example: "/users/:/status" -> ["/users/",":","/status"]
 func splitRoute(_path string) ([]string, error) {
-	_path = path.Clean(_path)
+	// Relative path is from root, "" and "." are "/".
+	_path = path.Clean("/" + _path)
 	// Empty path
 	if _path == "" || _path == "/" {
 		return []string{"/"}, nil
@@ -87,23 +92,177 @@ type Route struct {
 	static [256]*Route
 	// Param sub route. A route can only has one param sub route.
 	param *Route
+	// Data of route besides handlers, moved along with Handler.
+	meta routeMeta
+	// Route or one of its sub routes has priority, used to skip priority check.
+	prioritized bool
+}
+
+// Data of route besides handlers.
+type routeMeta struct {
+	// Deprecation information.
+	deprecated      bool
+	deprecatedAt    time.Time
+	sunset          time.Time
+	deprecationLink string
+	// Override CORS of router.
+	cors *CORS
+	// Allowed countries of client, empty means all.
+	countries []string
+	// Audit action, empty if not audited.
+	audit string
+	// Names of param routes in order, such as "id" of ":id".
+	params []string
+	// Response headers set before handlers.
+	header http.Header
+	// Access log sampling rate of route, used if logSampling is true.
+	logSampling bool
+	logRate     float64
+	// Higher priority wins on ambiguous matches.
+	priority int
+	// Hook of "Expect: 100-continue".
+	expectContinue HandlerFunc
+	// Path of localized aliases, locale -> path.
+	aliases map[string]string
+	// Name of concurrency pool, empty means no pool.
+	pool string
+	// Route of alias, nil if it is not an alias.
+	alias *Route
+	// Max bytes of response body, 0 is no limit.
+	maxResponseSize    int64
+	responseSizePolicy ResponseSizePolicy
+	// Response is not compressed by Compression of router.
+	noCompression bool
+}
+
+// Set priority of route, default is 0.
+// If a path matches more than one route, such as "/users/new" and "/users/:",
+// the route has higher priority wins, static route wins if priorities are equal.
+func (r *Route) SetPriority(n int) {
+	r.meta.priority = n
+	for p := r; p != nil; p = p.parent {
+		p.prioritized = true
+	}
+}
+
+// Return priority of route.
+func (r *Route) Priority() int {
+	return r.meta.priority
+}
+
+// Set a fixed response header, it is set before handlers run, handlers can override it.
+// Empty value removes the header.
+func (r *Route) SetHeader(key, value string) {
+	if value == "" {
+		r.meta.header.Del(key)
+		return
+	}
+	if r.meta.header == nil {
+		r.meta.header = make(http.Header)
+	}
+	r.meta.header.Set(key, value)
+}
+
+// Copy fixed headers to h.
+func (r *Route) setHeader(h http.Header) {
+	for k, v := range r.meta.header {
+		h[k] = append(h[k][:0:0], v...)
+	}
+}
+
+// Set the rate of requests logged by AccessLog, in range [0,1], 0 disables logging of the route.
+// It is used for noisy routes such as "/healthz".
+func (r *Route) SetLogSampling(rate float64) {
+	r.meta.logSampling = rate < 1
+	r.meta.logRate = rate
+}
+
+// Return true if current request should be logged by AccessLog.
+func (r *Route) sampleLog() bool {
+	if !r.meta.logSampling {
+		return true
+	}
+	return r.meta.logRate > 0 && rand.Float64() < r.meta.logRate
+}
+
+// Mark route deprecated as of now, router emits Deprecation, Sunset and Link headers.
+// sunset is the time route will be removed, link is the URL of deprecation document, they are optional.
+func (r *Route) Deprecate(sunset time.Time, link string) {
+	r.meta.deprecated = true
+	r.meta.deprecatedAt = time.Now()
+	r.meta.sunset = sunset
+	r.meta.deprecationLink = link
+}
+
+// Return whether route is deprecated.
+func (r *Route) Deprecated() bool {
+	return r.meta.deprecated
+}
+
+// Set Deprecation, Sunset and Link headers if route is deprecated.
+func (r *Route) setDeprecationHeader(h http.Header) {
+	if !r.meta.deprecated {
+		return
+	}
+	h.Set("Deprecation", "@"+strconv.FormatInt(r.meta.deprecatedAt.Unix(), 10))
+	if !r.meta.sunset.IsZero() {
+		h.Set("Sunset", r.meta.sunset.UTC().Format(http.TimeFormat))
+	}
+	if r.meta.deprecationLink != "" {
+		h.Add("Link", "<"+r.meta.deprecationLink+">; rel=\"deprecation\"")
+	}
 }
 
 // Exec all handlers.
 func (r *Route) Handle(c *Context) bool {
-	for _, h := range r.Handler {
-		if !h(c) {
-			return false
+	return c.run(r.Handler, nil)
+}
+
+// Return path pattern of route, param routes have their names if added with names.
+// Example: "/users/:id/*file", or "/users/:/*" if unnamed.
+func (r *Route) Pattern() string {
+	names := r.meta.params
+	part := strings.Split(r.path, "/")
+	n := 0
+	for i, s := range part {
+		if s != ":" && s != "*" {
+			continue
+		}
+		if n < len(names) {
+			part[i] = s + names[n]
 		}
+		n++
 	}
-	return true
+	return strings.Join(part, "/")
+}
+
+// Return names of param routes in order, the length is the count of params.
+// Name is empty if the param route is unnamed.
+func (r *Route) Params() []string {
+	var params []string
+	for _, s := range strings.Split(r.path, "/") {
+		if s != ":" && s != "*" {
+			continue
+		}
+		name := ""
+		if len(params) < len(r.meta.params) {
+			name = r.meta.params[len(params)]
+		}
+		params = append(params, name)
+	}
+	return params
+}
+
+// Return true if r is a param route, static route name can be ":" or "*" too, such as "*" of "/a*".
+func (r *Route) isParam() bool {
+	return r.parent != nil && r.parent.param == r
 }
 
 func (r *Route) add(name string) *Route {
 	sub := new(Route)
 	sub.name = name
 	sub.parent = r
-	if r.name == "*" || r.name == ":" {
+	if r.isParam() {
 		sub.path = r.path + "/" + name
 	} else {
 		sub.path = r.path + name
@@ -111,33 +270,23 @@ func (r *Route) add(name string) *Route {
 	return sub
 }
 
-// Try to add a param sub route to r, it returns error in these cases:
-// name is no equal to r's name and r has static sub route.
+// Try to add a param sub route to r, it returns error if r has a different param sub route.
+// Static sub routes are allowed, they are matched before the param sub route.
 func (r *Route) addSubParam(name string) (*Route, error) {
-	// Case 1, r has a param sub route, name must equal to this sub route's name.
+	// r has a param sub route, name must equal to this sub route's name.
 	if r.param != nil {
 		if r.param.name != name {
 			return nil, fmt.Errorf("%s has a param sub route %s, add sub route %s failed", r.path, r.param.name, name)
 		}
 		return r.param, nil
 	}
-	// Case 2, r has static sub route.
-	for i := 0; i < len(r.static); i++ {
-		if r.static[i] != nil {
-			return nil, fmt.Errorf("%s has a static sub route %s, add sub route %s failed", r.path, r.static[i].name, name)
-		}
-	}
 	// Add param sub route.
 	r.param = r.add(name)
 	return r.param, nil
 }
 
-// Try to add a static sub route to r, return error if r is a all match route or r has a param route.
+// Try to add a static sub route to r.
 func (r *Route) addSubStatic(name string) (*Route, error) {
-	// r has a param route.
-	if r.param != nil {
-		return nil, fmt.Errorf("%s has a param sub route %s, add sub route %s failed", r.path, r.param.name, name)
-	}
 	// Let sub route to handle.
 	if r.static[name[0]] != nil {
 		return r.static[name[0]].addStatic(name)
@@ -146,10 +295,10 @@ func (r *Route) addSubStatic(name string) (*Route, error) {
 	return r.static[name[0]], nil
 }
 
-// Try to add a static path to r, it returns error if r has a param route.
+// Try to add a static path to r.
 func (r *Route) addStatic(name string) (*Route, error) {
 	// r is a param route.
-	if r.name == ":" {
+	if r.isParam() {
 		return r.addSubStatic(name)
 	}
 	// Add case 1, r.name="/abc", name="/abc".
@@ -160,12 +309,8 @@ func (r *Route) addStatic(name string) (*Route, error) {
 	// Add case 2, r.name="/abc", name="/ab", diff1="c", diff2="".
 	// New: /ab(name) -> c(r).
 	if diff2 == "" {
-		err := r.moveToNewSub(diff1)
-		if err != nil {
-			return nil, err
-		}
 		// Return "/ab".
-		return r, nil
+		return r.split(len(name)), nil
 	}
 	// Add case 3, r.name="/ab", name="/abc", diff1="", diff2="c".
 	// New: /ab(r) -> c(name).
@@ -180,35 +325,36 @@ func (r *Route) addStatic(name string) (*Route, error) {
 	//  		-> c(r).
 	// New: /ab
 	// 			-> d(name).
-	err := r.moveToNewSub(diff1)
-	if err != nil {
-		return nil, err
-	}
 	// Return "d".
-	return r.addSubStatic(diff2)
-}
-
-// Add a new sub route, copy r's data to new route.
-func (r *Route) moveToNewSub(name string) error {
-	// Save r's data.
-	handler := r.Handler
-	staic := r.static
-	param := r.param
-	// Modify r's data.
-	r.path = r.path[:len(r.path)-len(name)]
-	r.name = r.name[:len(r.name)-len(name)]
-	r.Handler = nil
-	r.removeAllStatic()
-	r.param = nil
-	// Add a new static route.
-	sub, err := r.addSubStatic(name)
-	if err != nil {
-		return err
+	return r.split(len(r.name) - len(diff1)).addSubStatic(diff2)
+}
+
+// Split static route r into a new parent route of the first n bytes of name, and r of the rest.
+// r keeps its data, so Route returned before is still valid.
+func (r *Route) split(n int) *Route {
+	p := new(Route)
+	p.name = r.name[:n]
+	p.path = r.path[:len(r.path)-len(r.name)+n]
+	p.parent = r.parent
+	p.prioritized = r.prioritized
+	p.parent.static[p.name[0]] = p
+	r.name = r.name[n:]
+	r.parent = p
+	p.static[r.name[0]] = r
+	return p
+}
+
+// Call fn with r and all sub routes.
+func (r *Route) walk(fn func(*Route)) {
+	fn(r)
+	if r.param != nil {
+		r.param.walk(fn)
+	}
+	for i := 0; i < len(r.static); i++ {
+		if r.static[i] != nil {
+			r.static[i].walk(fn)
+		}
 	}
-	sub.Handler = handler
-	sub.static = staic
-	sub.param = param
-	return nil
 }
 
 func (r *Route) removeAllStatic() {
@@ -217,9 +363,13 @@ func (r *Route) removeAllStatic() {
 	}
 }
 
-// Root route of a route tree.
+// Root route of a route tree, the default Matcher.
 type rootRoute struct {
 	route Route
+	// Routes of exact paths if tree has no param routes, used for O(1) matching.
+	exact map[string]*Route
+	// Tree has param routes.
+	dynamic bool
 }
 
 // Try to add a route by path.
@@ -229,17 +379,16 @@ func (r *rootRoute) Add(path string) (*Route, error) {
 	if err != nil {
 		return nil, err
 	}
-	route := &r.route
-	// Initialize root route.
-	if route.name == "" {
-		route.name = routePath[0]
-		route.path = route.name
-		routePath = routePath[1:]
+	// r.route is an empty root of static routes, so Route of path "/" can be split too.
+	route, err := r.route.addSubStatic(routePath[0])
+	if err != nil {
+		return nil, err
 	}
+	routePath = routePath[1:]
 	// Add sub route loop.
 	for _, name := range routePath {
 		// Route is a all match route, can not add sub route.
-		if route.name == "*" {
+		if route.isParam() && route.name == "*" {
 			return nil, fmt.Errorf("%s is a all match route, add sub route %s failed", route.path, name)
 		}
 		if name == ":" || name == "*" {
@@ -251,9 +400,66 @@ func (r *rootRoute) Add(path string) (*Route, error) {
 			return nil, err
 		}
 	}
+	route.meta.params = paramNames(path)
+	for p := route; p != nil; p = p.parent {
+		if p.isParam() {
+			r.dynamic = true
+			r.exact = nil
+			break
+		}
+	}
+	if !r.dynamic {
+		if r.exact == nil {
+			r.exact = make(map[string]*Route)
+		}
+		r.exact[route.path] = route
+	}
 	return route, nil
 }
 
+// Rebuild exact paths map after routes are removed.
+func (r *rootRoute) buildExact() {
+	r.dynamic = false
+	r.exact = nil
+	r.route.walk(func(route *Route) {
+		if route.isParam() {
+			r.dynamic = true
+		}
+	})
+	if r.dynamic {
+		return
+	}
+	r.exact = make(map[string]*Route)
+	r.route.walk(func(route *Route) {
+		r.exact[route.path] = route
+	})
+}
+
+// Return names of param routes in path, nil if all are unnamed.
+// Example: "/users/:id/*file" -> ["id","file"]
+func paramNames(_path string) []string {
+	var names []string
+	named := false
+	for _, s := range strings.Split(path.Clean(_path), "/") {
+		if s == "" || (s[0] != ':' && s[0] != '*') {
+			continue
+		}
+		names = append(names, s[1:])
+		if len(s) > 1 {
+			named = true
+		}
+	}
+	if !named {
+		return nil
+	}
+	return names
+}
+
+// Call fn with all routes.
+func (r *rootRoute) Walk(fn func(*Route)) {
+	r.route.walk(fn)
+}
+
 // Try to find route by path.
 func (r *rootRoute) Find(path string) *Route {
 	// Split path into static and param routes.
@@ -266,6 +472,7 @@ func (r *rootRoute) Find(path string) *Route {
 	name := routePath[0]
 	// r must be static route.
 	for {
+		route = route.static[name[0]]
 		if route == nil || len(route.name) > len(name) || route.name != name[:len(route.name)] {
 			return nil
 		}
@@ -273,7 +480,6 @@ func (r *rootRoute) Find(path string) *Route {
 		if name == "" {
 			break
 		}
-		route = route.static[name[0]]
 	}
 	routePath = routePath[1:]
 	// Check sub.
@@ -299,27 +505,59 @@ func (r *rootRoute) Find(path string) *Route {
 	return route
 }
 
-// Try to remove route by path.
+// Try to remove route by path, sub routes of the route are removed too.
 // If success, it will go on remove the route's parent if its parent has no sub route and no handlers.
 func (r *rootRoute) Remove(path string) bool {
+	if !r.remove(path) {
+		return false
+	}
+	r.buildExact()
+	return true
+}
+
+func (r *rootRoute) remove(path string) bool {
 	// Find the route.
 	route := r.Find(path)
 	if route == nil {
 		return false
 	}
+	// Static sub routes such as "/users" of "/user" are not sub paths, keep them.
+	if !route.isParam() && !strings.HasSuffix(route.path, "/") {
+		static := -1
+		for i := 0; i < len(route.static); i++ {
+			if route.static[i] != nil && i != '/' {
+				if static >= 0 {
+					static = len(route.static)
+					break
+				}
+				static = i
+			}
+		}
+		if static >= 0 {
+			route.static['/'] = nil
+			route.Handler = nil
+			route.meta = routeMeta{}
+			if static < len(route.static) {
+				route.static[static].join()
+			}
+			return true
+		}
+	}
 	for {
 		// Reset root route.
 		if route == &r.route {
 			route.path = ""
 			route.Handler = nil
+			route.meta = routeMeta{}
 			route.name = ""
 			route.removeAllStatic()
 			route.param = nil
+			route.prioritized = false
 			return true
 		}
 		// Remove from it's parent route.
 		parent := route.parent
-		if route.name[0] == ':' || route.name[0] == '*' {
+		if route.isParam() {
 			parent.param = nil
 		} else {
 			parent.static[route.name[0]] = nil
@@ -327,96 +565,232 @@ func (r *rootRoute) Remove(path string) bool {
 		if len(parent.Handler) > 0 {
 			return true
 		}
-		// Parent has no handlers, if it has only one static sub, join them.
-		if parent.name != ":" && parent.name != "*" {
-			var static []int
-			for i := 0; i < len(parent.static); i++ {
-				if parent.static[i] != nil {
-					static = append(static, i)
-					if len(static) > 1 {
-						break
-					}
+		static := -1
+		for i := 0; i < len(parent.static); i++ {
+			if parent.static[i] != nil {
+				if static >= 0 {
+					return true
 				}
+				static = i
 			}
-			if len(static) > 1 {
-				return true
-			}
-			if len(static) == 1 {
-				sub := route.static[static[0]]
-				route.path = sub.path
-				route.Handler = sub.Handler
-				route.name += sub.name
-				if sub.param != nil {
-					sub.param.parent = route
-				} else {
-					for i := 0; i < len(sub.static); i++ {
-						route.static[i] = sub.static[i]
-						if route.static[i] != nil {
-							route.static[i].parent = route
-						}
-					}
-				}
+		}
+		if parent.param != nil {
+			return true
+		}
+		// Parent has no handlers and only one static sub, join them.
+		if static >= 0 {
+			if parent != &r.route && !parent.isParam() {
+				parent.static[static].join()
 			}
+			return true
 		}
-		// If parent has no sub route and no handlers, go on remove parent.
+		// Parent has no sub route and no handlers, go on remove parent.
 		route = parent
 	}
 }
 
+// Join static route r into its parent, which has no handlers and no other sub routes.
+// r keeps its data, so Route returned before is still valid.
+func (r *Route) join() {
+	p := r.parent
+	r.name = p.name + r.name
+	r.parent = p.parent
+	r.prioritized = r.prioritized || p.prioritized
+	r.parent.static[r.name[0]] = r
+}
+
+// What route can be matched.
+const (
+	// Any route in tree.
+	matchAny = iota
+	// Route has handlers.
+	matchHandler
+	// Route has handlers and is not tried by Context.Fallthrough.
+	matchNext
+)
+
 // Try to match path, return the final route and value of param route.
 // Value of param route will append to param and return.
 func (r *rootRoute) Match(c *Context) *Route {
-	path := c.Req.URL.Path
-	route := &r.route
-	i := 0
-Loop:
-	for {
-		// Whether current route match the prefix of path.
-		if len(route.name) < len(path) {
-			// Current route match the prefix of path.
-			if path[:len(route.name)] == route.name {
-				// Path removes prefix.
-				path = path[len(route.name):]
-				// Try to match sub routes.
-			ParamLoop:
-				// If sub route is a param route.
-				for route.param != nil {
-					// Is a param route.
-					if route.param.name == ":" {
-						i = 1
-						// Find next '/'
-						for ; i < len(path); i++ {
-							if path[i] == '/' {
-								c.Param = append(c.Param, path[:i])
-								// Ignore '/'
-								path = path[i+1:]
-								route = route.param
-								continue ParamLoop
-							}
-						}
-						// Can not find '/', it's the end.
-						c.Param = append(c.Param, path)
-						route = route.param
-						return route
-					}
-					// Is a all match route.
-					c.Param = append(c.Param, path)
-					return route.param
-				}
-				// If sub route is a static route.
-				if route.static[path[0]] != nil {
-					route = route.static[path[0]]
-					continue Loop
-				}
+	return r.match(c, c.Req.URL.Path, false, matchAny)
+}
+
+// Try to match path, static names are compared ignoring ASCII case if fold is true.
+// Static sub routes are tried before the param sub route, it backtracks if the static one can not match.
+// If tree has no param routes, exact path is matched by map.
+func (r *rootRoute) match(c *Context, path string, fold bool, mode int) *Route {
+	if r.exact != nil && !fold && mode != matchAny {
+		route := r.exact[path]
+		if route == nil || !route.accept(c, mode) {
+			return nil
+		}
+		return route
+	}
+	return r.route.match(c, path, fold, mode)
+}
+
+// Return true if route can be the result of match.
+func (r *Route) accept(c *Context, mode int) bool {
+	switch mode {
+	case matchHandler:
+		return len(r.Handler) > 0
+	case matchNext:
+		return len(r.Handler) > 0 && !c.triedRoute(r) && (r.meta.alias == nil || !c.triedRoute(r.meta.alias))
+	}
+	return true
+}
+
+// Match path from param route r.
+func (r *Route) matchParam(c *Context, path string, fold bool, mode int) *Route {
+	switch r.name {
+	case ":":
+		if path == "" {
+			return nil
+		}
+		// Find next '/'
+		i := 1
+		for ; i < len(path); i++ {
+			if path[i] == '/' {
+				break
+			}
+		}
+		c.Param = append(c.Param, path[:i])
+		if i == len(path) {
+			// Can not find '/', it's the end.
+			if r.accept(c, mode) {
+				return r
 			}
-			// Current route dose not match the prefix of path.
+		} else if i+1 < len(path) {
+			// Ignore '/', path ends with '/' can not match.
+			if route := r.matchSub(c, path[i+1:], fold, mode); route != nil {
+				return route
+			}
+		}
+		c.Param = c.Param[:len(c.Param)-1]
+		return nil
+	case "*":
+		if path == "" || !r.accept(c, mode) {
 			return nil
 		}
-		// Current route match the rest of path.
-		if path == route.name {
-			return route
+		c.Param = append(c.Param, path)
+		return r
+	}
+	return nil
+}
+
+// Match path from static route r, r.name is not matched yet.
+func (r *Route) match(c *Context, path string, fold bool, mode int) *Route {
+	// Whether current route match the prefix of path.
+	if len(r.name) > len(path) {
+		return nil
+	}
+	if path[:len(r.name)] != r.name && !(fold && strings.EqualFold(path[:len(r.name)], r.name)) {
+		return nil
+	}
+	return r.matchSub(c, path[len(r.name):], fold, mode)
+}
+
+// Match the rest of path by sub routes of r.
+func (r *Route) matchSub(c *Context, path string, fold bool, mode int) *Route {
+	// Current route match the rest of path.
+	if path == "" {
+		if r.accept(c, mode) {
+			return r
 		}
-		// Current route dose not match the rest of path.
 		return nil
 	}
+	// Static sub route first.
+	if sub := r.static[path[0]]; sub != nil {
+		n := len(c.Param)
+		if route := sub.match(c, path, fold, mode); route != nil {
+			if r.param == nil || (!r.param.prioritized && route.meta.priority >= 0) {
+				return route
+			}
+			return r.matchPriority(c, path, fold, mode, route, n)
+		}
+	}
+	if fold && swapCase(path[0]) != path[0] {
+		if sub := r.static[swapCase(path[0])]; sub != nil {
+			if route := sub.match(c, path, fold, mode); route != nil {
+				return route
+			}
+		}
+	}
+	// Backtrack to param sub route.
+	if r.param != nil {
+		return r.param.matchParam(c, path, fold, mode)
+	}
+	return nil
+}
+
+// Match path by param sub route of r, return it if it has higher priority than route,
+// otherwise return route, and params of route are kept from n.
+func (r *Route) matchPriority(c *Context, path string, fold bool, mode int, route *Route, n int) *Route {
+	param := append([]string(nil), c.Param[n:]...)
+	c.Param = c.Param[:n]
+	if sub := r.param.matchParam(c, path, fold, mode); sub != nil && sub.meta.priority > route.meta.priority {
+		return sub
+	}
+	c.Param = append(c.Param[:n], param...)
+	return route
+}
+
+// Call fn with every route matches path in default order, static sub routes are before the param one.
+func (r *Route) matchAll(path string, fn func(*Route)) {
+	if !r.isParam() {
+		if strings.HasPrefix(path, r.name) {
+			r.matchSubAll(path[len(r.name):], fn)
+		}
+		return
+	}
+	switch r.name {
+	case ":":
+		if path == "" {
+			return
+		}
+		i := strings.IndexByte(path[1:], '/')
+		if i >= 0 {
+			i++
+		}
+		if i < 0 {
+			if len(r.Handler) > 0 {
+				fn(r)
+			}
+			return
+		}
+		if i+1 < len(path) {
+			r.matchSubAll(path[i+1:], fn)
+		}
+		return
+	case "*":
+		if path != "" && len(r.Handler) > 0 {
+			fn(r)
+		}
+	}
+}
+
+func (r *Route) matchSubAll(path string, fn func(*Route)) {
+	if path == "" {
+		if len(r.Handler) > 0 {
+			fn(r)
+		}
+		return
+	}
+	if sub := r.static[path[0]]; sub != nil {
+		sub.matchAll(path, fn)
+	}
+	if r.param != nil {
+		r.param.matchAll(path, fn)
+	}
+}
+
+// Return the other case of ASCII letter b.
+func swapCase(b byte) byte {
+	switch {
+	case 'a' <= b && b <= 'z':
+		return b - 'a' + 'A'
+	case 'A' <= b && b <= 'Z':
+		return b - 'A' + 'a'
+	}
+	return b
 }
@@ -1,14 +1,22 @@
 package router
 
 import (
+	"context"
+	"crypto/cipher"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"io/ioutil"
-	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type HandlerFunc func(*Context) bool
@@ -19,15 +27,23 @@ func Notfound(c *Context) bool {
 	return true
 }
 
+// BadRequest response status code 400.
+func BadRequest(c *Context) bool {
+	c.Res.WriteHeader(http.StatusBadRequest)
+	return true
+}
+
 // Match http method and url path.
 // Route path example:
-// Param route: "/:", no need to know name, because we know the order.
+// Param route: "/:" or named "/:id", values are in c.Param by order, or by name by Context.ParamByName.
 // All match route: "/*", add any path after this route will return error.
 // Static route: "/users".
 // Chain cases:
 // before -> after
 // before -> notfound -> after
 // before -> handler -> after
+// Phases between before and after chains, see Phase:
+// preflight -> match -> auto OPTIONS -> 405 -> notfound
 type Router struct {
 	// Root route table.
 	// 0=get, 1=head, 2=delete, 3=connect, 4=options,
@@ -37,8 +53,98 @@ type Router struct {
 	before []HandlerFunc
 	// Called if not match.
 	notfound []HandlerFunc
+	// Called if request is malformed.
+	badRequest []HandlerFunc
+	// Called for "OPTIONS *" and CONNECT in authority form.
+	serverOptions []HandlerFunc
+	connect       []HandlerFunc
+	// Strict request checking, nil if disabled.
+	strict *StrictRequest
 	// Called anyway.
 	after []HandlerFunc
+	// Called if handler chain panic.
+	recovery []HandlerFunc
+	// Development mode, enable debug output such as panic page.
+	devMode bool
+	// Logger of router, default use slog.Default().
+	logger Logger
+	// Built-in statistics collector, nil if disabled.
+	stats *statsCollector
+	// Called if request latency exceeds slowThreshold.
+	slowThreshold time.Duration
+	onSlow        func(*Context, time.Duration)
+	// Capture request/response pairs, nil if disabled.
+	recorder *Recorder
+	// Metrics backend, nil if disabled.
+	metrics MetricsSink
+	// Global concurrency limiter, nil if disabled.
+	limiter *Limiter
+	// Concurrency pools of routes by name.
+	pools map[string]*pool
+	// Encrypt and sign cookies.
+	cookieAEAD cipher.AEAD
+	// Info of generated OpenAPI document.
+	openAPIInfo OpenAPIInfo
+	// Handle HEAD by GET route.
+	headFallback bool
+	// Options of honeypot routes.
+	honeypot Honeypot
+	// Emit audit events, nil if disabled.
+	auditor *Auditor
+	// Mask JSON body fields before logged, audited or recorded.
+	redactor *Redactor
+	// Render html templates.
+	renderer      *Renderer
+	templateFuncs template.FuncMap
+	// CacheHandlers of static routes, see WarmStatic.
+	staticCaches []staticCache
+	// CacheHandlers of static routes by ETag, used to share data of identical files.
+	staticStore map[string]*CacheHandler
+	// Minify Renderer output and cached static files, nil if disabled.
+	minifier Minifier
+	// Dynamic compression of route responses, nil if disabled.
+	compression *Compression
+	// Protected prefixes of static routes.
+	staticAuths []staticAuth
+	// Outbound HTTP client configuration.
+	client *Client
+	// Transcode text responses by Accept-Charset.
+	transcodeCharset bool
+	// Phases after before chain, and their options.
+	phases           []Phase
+	phaseHandlers    [phaseCount][]HandlerFunc
+	cors             *CORS
+	autoOptions      bool
+	methodNotAllowed bool
+	// Requests being served, and whether server is shutting down.
+	inflight int64
+	draining int32
+	// Called before serving, and whether it is done.
+	warmup        func(ctx context.Context) error
+	warmupTimeout time.Duration
+	warmupPolicy  WarmupPolicy
+	warmed        int32
+	// Match options used when path does not match.
+	slashPolicy SlashPolicy
+	caseFolding bool
+	// Max bytes of request body, 0 is no limit.
+	maxBodySize int64
+	// Enable Context.Fallthrough.
+	fallThrough bool
+	// Checked before matching routes, nil if disabled.
+	redirects *Redirects
+	// Synchronize route tables until frozen.
+	mutex  sync.RWMutex
+	frozen int32
+	// Routes added by AddTemporary, and wake channel of their janitor, nil if janitor is not running.
+	temporaries []temporaryRoute
+	janitor     chan struct{}
+	// Do not abort handlers when client is gone.
+	keepOnDisconnect bool
+	// Called after a route is removed.
+	onRouteRemoved func(method, pattern string)
+	// Route tables set by SetMatcher, nil uses rootRoute.
+	matchers [9]Matcher
 }
 
 func (r *Router) SetBefore(funcs ...HandlerFunc) {
@@ -49,70 +155,248 @@ func (r *Router) SetNotfound(funcs ...HandlerFunc) {
 	r.notfound = funcs
 }
 
+// Set the chain called if request is malformed, such as empty method or path not starts with '/',
+// or it fails checks of SetStrictRequest, then after chain is called. Default response status code 400.
+func (r *Router) SetBadRequest(funcs ...HandlerFunc) {
+	r.badRequest = funcs
+}
+
+// Set the chain called after handlers return.
+// For streaming responses it is called when the stream terminates, see Context.StreamEnd.
 func (r *Router) SetAfter(funcs ...HandlerFunc) {
 	r.after = funcs
 }
 
+// Set the chain called when a handler panic, then after chain is called.
+// If it is empty, panic is not recovered.
+func (r *Router) SetRecovery(funcs ...HandlerFunc) {
+	r.recovery = funcs
+}
+
+// Set development mode. Never enable it in production, it may output debug information to client.
+func (r *Router) SetDevMode(devMode bool) {
+	r.devMode = devMode
+}
+
+// Return whether router is in development mode.
+func (r *Router) DevMode() bool {
+	return r.devMode
+}
+
+// Set Logger of router. If logger is nil, use slog.Default().
+func (r *Router) SetLogger(logger Logger) {
+	r.logger = logger
+}
+
+// Return Logger of router.
+func (r *Router) Logger() Logger {
+	if r.logger == nil {
+		return defaultLogger{}
+	}
+	return r.logger
+}
+
 // Implements http.Handler
 func (r *Router) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&r.inflight, 1)
+	defer atomic.AddInt64(&r.inflight, -1)
 	c := contextPool.Get().(*Context)
-	c.Req = req
-	c.Res = res
-	c.Param = c.Param[:0]
-	c.Data = nil
-	// Before.
-	for _, h := range r.before {
-		if !h(c) {
-			// After.
-			for _, h := range r.after {
-				if !h(c) {
-					break
-				}
-			}
-			return
-		}
+	c.reset(r, res, req)
+	defer c.release()
+	if r.maxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+		req.Body = http.MaxBytesReader(res, req.Body, r.maxBodySize)
 	}
-	// Try to match route.
-	rootRoute := r.root(req.Method)
-	if rootRoute != nil {
-		route := rootRoute.Match(c)
-		if route != nil && len(route.Handler) > 0 {
-			// Handler.
-			for _, h := range route.Handler {
-				if !h(c) {
-					break
-				}
-			}
-			// After.
-			for _, h := range r.after {
-				if !h(c) {
-					break
-				}
-			}
+	if len(r.recovery) > 0 {
+		defer r.recover(c)
+	}
+	if r.recorder != nil {
+		r.recorder.begin(c)
+	}
+	// Limit concurrency.
+	if r.limiter != nil {
+		if !r.limiter.acquire(c) {
+			r.limiter.reject(c)
+			r.handleAfter(c)
 			return
 		}
+		defer r.limiter.release()
 	}
-	// Notfound.
-	for _, h := range r.notfound {
-		if !h(c) {
-			break
-		}
+	if r.strict != nil && !r.strict.check(req) {
+		r.serveBadRequest(c)
+		r.handleAfter(c)
+		return
+	}
+	// Before, then the request, so Context.Next of before chain wraps the request.
+	c.run(r.before, serveRequest)
+	r.handleAfter(c)
+}
+
+// The tail of before chain.
+func serveRequest(c *Context) bool {
+	if !c.router.checkRequest(c) {
+		return false
+	}
+	// Preflight, match, auto OPTIONS, 405 and notfound.
+	c.router.servePhases(c)
+	return true
+}
+
+// Return false if request is handled, because it is malformed or its method is not supported.
+func (r *Router) checkRequest(c *Context) bool {
+	method, path := c.Req.Method, c.Req.URL.Path
+	if method == http.MethodOptions && path == "*" {
+		r.serveServerOptions(c)
+		return false
+	}
+	if method == http.MethodConnect && path == "" {
+		r.serveConnect(c)
+		return false
+	}
+	if method == "" || path == "" || path[0] != '/' {
+		r.serveBadRequest(c)
+		return false
+	}
+	if methodIndex(method) >= 0 {
+		return true
 	}
-	// After.
-	for _, h := range r.after {
-		if !h(c) {
-			break
+	if allow := r.allowMethods(c); allow != "" {
+		c.Res.Header().Set("Allow", allow)
+	}
+	c.Res.WriteHeader(http.StatusMethodNotAllowed)
+	return false
+}
+
+// Call bad request chain, or response 400 if it is empty.
+func (r *Router) serveBadRequest(c *Context) {
+	if r.badRequest != nil {
+		r.callChain(c, r.badRequest)
+		return
+	}
+	BadRequest(c)
+}
+
+// Set a hook called after response when request latency exceeds threshold.
+// If fn is nil, the hook is removed.
+func (r *Router) OnSlowRequest(threshold time.Duration, fn func(*Context, time.Duration)) {
+	r.slowThreshold = threshold
+	r.onSlow = fn
+}
+
+// Return the route which has handlers matched by request, nil if not found.
+func (r *Router) match(c *Context) *Route {
+	return r.matchPath(c, c.Req.URL.Path, false)
+}
+
+// Return the route which has handlers matched by request method and path, nil if not found.
+func (r *Router) matchPath(c *Context, path string, fold bool) *Route {
+	route := r.matchTable(c, c.Req.Method, path, fold, matchNext)
+	if route != nil {
+		return route
+	}
+	// Try GET route.
+	if r.headFallback && c.Req.Method == http.MethodHead {
+		c.Param = c.Param[:0]
+		route = r.matchTable(c, http.MethodGet, path, fold, matchNext)
+		if route != nil {
+			c.head.reset(c.res.ResponseWriter)
+			c.res.ResponseWriter = &c.head
+			return route
 		}
 	}
+	return nil
+}
+
+// Return the route which has handlers matched by method and request path, c.Param is not changed.
+func (r *Router) lookup(c *Context, method string) *Route {
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	n := len(c.Param)
+	route := r.matchTable(c, method, c.Req.URL.Path, false, matchHandler)
+	c.Param = c.Param[:n]
+	return route
+}
+
+// Match path by route table of method.
+// Matcher other than the default one only matches request path, without fold.
+func (r *Router) matchTable(c *Context, method, path string, fold bool, mode int) *Route {
+	table := r.table(method)
+	switch t := table.(type) {
+	case *rootRoute:
+		return t.match(c, path, fold, mode)
+	case *shardedRoute:
+		return t.match(c, path, fold, mode)
+	}
+	if table == nil || fold || path != c.Req.URL.Path {
+		return nil
+	}
+	n := len(c.Param)
+	route := table.Match(c)
+	if route == nil || !route.accept(c, mode) {
+		c.Param = c.Param[:n]
+		return nil
+	}
+	return route
+}
+
+// Call after chain, then collect statistics, metrics and check slow request.
+func (r *Router) handleAfter(c *Context) {
+	if c.afterDone {
+		return
+	}
+	c.afterDone = true
+	defer c.cancelTimeout()
+	c.finishing = true
+	c.endStream()
+	if c.trailers != nil {
+		c.setTrailers()
+	}
+	// Dynamic compression.
+	if c.res.ResponseWriter == &c.compress {
+		c.compress.Finish()
+		c.res.ResponseWriter = c.compress.ResponseWriter
+	}
+	// HEAD fallback.
+	if c.res.ResponseWriter == &c.head {
+		c.head.Finish()
+	}
+	c.run(r.after, nil)
+	if r.auditor != nil && c.route != nil && c.route.meta.audit != "" {
+		r.auditor.emit(c)
+	}
+	if r.stats != nil {
+		r.stats.record(c)
+	}
+	if r.recorder != nil {
+		r.recorder.end(c)
+	}
+	if c.dump != nil {
+		c.dump.end(c)
+	}
+	if r.metrics == nil && r.onSlow == nil {
+		return
+	}
+	latency := time.Since(c.start)
+	if r.metrics != nil {
+		r.emitMetrics(c, latency)
+	}
+	if r.onSlow != nil && latency > r.slowThreshold {
+		r.onSlow(c, latency)
+	}
 }
 
 // Try to add a route.
 func (r *Router) Add(method, path string, funcs ...HandlerFunc) (*Route, error) {
-	root := r.root(method)
-	if root == nil {
+	table := r.table(method)
+	if table == nil {
 		return nil, fmt.Errorf("invalid http method '%s'", method)
 	}
-	route, err := root.Add(path)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.Frozen() {
+		return nil, ErrFrozen
+	}
+	route, err := table.Add(path)
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +445,7 @@ func (r *Router) AddTrace(path string, funcs ...HandlerFunc) (*Route, error) {
 // and file extension in removeFileExt list will be removed.
 // Example: "index.html" -> "index".
 // If cache is true, use CachaHandler, else use FileHandler.
+// Use ProtectStatic to require auth for a subtree.
 func (r *Router) AddStatic(method, route, file string, cache bool, removeFileExt ...string) error {
 	fi, err := os.Stat(file)
 	if err != nil {
@@ -168,39 +453,8 @@ func (r *Router) AddStatic(method, route, file string, cache bool, removeFileExt
 	}
 	// 文件
 	if !fi.IsDir() {
-		// 静态文件处理
-		fi, err := os.Stat(file)
-		if err != nil {
-			return err
-		}
 		// 路由路径是否去掉扩展名
-		for _, ext := range removeFileExt {
-			if ext == "" {
-				continue
-			}
-			if ext[0] != '.' {
-				ext = "." + ext
-			}
-			route = strings.TrimSuffix(route, ext)
-		}
-		// 是否缓存
-		if !cache {
-			h := new(FileHandler)
-			h.File = route
-			_, err = r.Add(method, route, h.Handle)
-			return err
-		} else {
-			d, err := ioutil.ReadFile(file)
-			if err != nil {
-				return err
-			}
-			h := new(CacheHandler)
-			h.ContentType = mime.TypeByExtension(filepath.Ext(fi.Name()))
-			h.ModTime = fi.ModTime()
-			h.Data = d
-			_, err = r.Add(method, route, h.Handle)
-			return err
-		}
+		return r.AddFile(method, trimFileExt(route, removeFileExt), file, cache)
 	}
 	// 目录
 	fis, err := ioutil.ReadDir(file)
@@ -217,13 +471,121 @@ func (r *Router) AddStatic(method, route, file string, cache bool, removeFileExt
 	return nil
 }
 
+// Try to add a local file as route, route path can be different from file name,
+// such as "/" -> "index.html".
+// If cache is true, use CachaHandler, else use FileHandler.
+func (r *Router) AddFile(method, route, file string, cache bool) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", file)
+	}
+	if !cache {
+		h := new(FileHandler)
+		h.File = file
+		_, err = r.Add(method, route, r.staticChain(route, h.Handle)...)
+		return err
+	}
+	h, err := CacheHandlerFromFile(file)
+	if err == nil {
+		err = r.minifyCache(h)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.Add(method, route, r.staticChain(route, h.Handle)...)
+	if err == nil {
+		r.addStaticCache(route, h)
+	}
+	return err
+}
+
+// Remove file extension in exts from route.
+func trimFileExt(route string, exts []string) string {
+	for _, ext := range exts {
+		if ext == "" {
+			continue
+		}
+		if ext[0] != '.' {
+			ext = "." + ext
+		}
+		route = strings.TrimSuffix(route, ext)
+	}
+	return route
+}
+
+// Try to add all files in fsys as static file routes under route, such as embed.FS.
+// File extension in removeFileExt list will be removed.
+// If cache is true, use CachaHandler, else use FSFileHandler.
+func (r *Router) AddStaticFS(method, route string, fsys fs.FS, cache bool, removeFileExt ...string) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		routePath := trimFileExt(path.Join(route, name), removeFileExt)
+		if !cache {
+			h := &FSFileHandler{FS: fsys, Name: name}
+			_, err = r.Add(method, routePath, r.staticChain(routePath, h.Handle)...)
+			return err
+		}
+		h, err := CacheHandlerFromFS(fsys, name)
+		if err == nil {
+			err = r.minifyCache(h)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = r.Add(method, routePath, r.staticChain(routePath, h.Handle)...)
+		if err == nil {
+			r.addStaticCache(routePath, h)
+		}
+		return err
+	})
+}
+
 // Try to find Route from method route table by path. Return nil if not found.
 func (r *Router) Route(method, path string) *Route {
-	root := r.root(method)
-	if root == nil {
+	table := r.table(method)
+	if table == nil {
 		return nil
 	}
-	return root.Find(path)
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	return table.Find(path)
+}
+
+// Return routes which have handlers and match request path, in effective order.
+// The first one is used to handle the request, the rest are tried by Context.Fallthrough.
+// Order: higher priority first, static route first if priorities are equal.
+func (r *Router) Candidates(method, path string) []*Route {
+	table := r.table(method)
+	if table == nil {
+		return nil
+	}
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	var routes []*Route
+	trees := routeTrees(table, path)
+	if trees == nil {
+		c := &Context{Req: &http.Request{Method: method, URL: &url.URL{Path: path}}}
+		if route := table.Match(c); route != nil && len(route.Handler) > 0 {
+			routes = append(routes, route)
+		}
+		return routes
+	}
+	for _, root := range trees {
+		root.route.matchAll(path, func(route *Route) {
+			routes = append(routes, route)
+		})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].meta.priority > routes[j].meta.priority
+	})
+	return routes
 }
 
 func (r *Router) RouteGet(path string) *Route {
@@ -264,11 +626,32 @@ func (r *Router) RouteTrace(path string) *Route {
 
 // Try to remove Route from method route table by path. Return false if not found.
 func (r *Router) Remove(method, path string) bool {
-	root := r.root(method)
-	if root == nil {
+	table := r.table(method)
+	if table == nil {
 		return false
 	}
-	return root.Remove(path)
+	r.mutex.Lock()
+	if r.Frozen() {
+		r.mutex.Unlock()
+		return false
+	}
+	route := table.Find(path)
+	var pattern string
+	if route != nil && len(route.Handler) > 0 {
+		pattern = route.Pattern()
+	}
+	if !table.Remove(path) {
+		r.mutex.Unlock()
+		return false
+	}
+	if route != nil && route.meta.alias == nil {
+		removeAliases(table, route)
+	}
+	r.mutex.Unlock()
+	if pattern != "" && r.onRouteRemoved != nil {
+		r.onRouteRemoved(method, pattern)
+	}
+	return true
 }
 
 func (r *Router) RemoveGet(path string) bool {
@@ -307,34 +690,75 @@ func (r *Router) RemoveTrace(path string) bool {
 	return r.Remove(http.MethodTrace, path)
 }
 
-// Return root Route from method table.
-func (r *Router) root(method string) *rootRoute {
-	if method[0] == 'G' {
-		return &r.rootRoute[0]
-	}
-	if method[0] == 'H' {
-		return &r.rootRoute[1]
-	}
-	if method[0] == 'D' {
-		return &r.rootRoute[2]
-	}
-	if method[0] == 'C' {
-		return &r.rootRoute[3]
+// Methods of route table, same index as rootRoute.
+var methods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+}
+
+// Call fn with method and all routes which have handlers.
+func (r *Router) walk(fn func(method string, route *Route)) {
+	if r.rlock() {
+		defer r.mutex.RUnlock()
 	}
-	if method[0] == 'O' {
-		return &r.rootRoute[4]
+	for i := 0; i < len(r.rootRoute); i++ {
+		r.table(methods[i]).Walk(func(route *Route) {
+			if len(route.Handler) > 0 {
+				fn(methods[i], route)
+			}
+		})
 	}
-	if method[0] == 'T' {
-		return &r.rootRoute[5]
+}
+
+// Return root Route from method table.
+func (r *Router) root(method string) *rootRoute {
+	i := methodIndex(method)
+	if i < 0 {
+		return nil
 	}
-	if method[1] == 'O' {
-		return &r.rootRoute[6]
+	return &r.rootRoute[i]
+}
+
+// Return index of method in route tables, -1 if not supported.
+func methodIndex(method string) int {
+	// Shortest methods are "GET" and "PUT".
+	if len(method) < 3 {
+		return -1
 	}
-	if method[1] == 'U' {
-		return &r.rootRoute[7]
+	i := -1
+	switch method[0] {
+	case 'G':
+		i = 0
+	case 'H':
+		i = 1
+	case 'D':
+		i = 2
+	case 'C':
+		i = 3
+	case 'O':
+		i = 4
+	case 'T':
+		i = 5
+	case 'P':
+		switch method[1] {
+		case 'O':
+			i = 6
+		case 'U':
+			i = 7
+		case 'A':
+			i = 8
+		}
 	}
-	if method[1] == 'A' {
-		return &r.rootRoute[8]
+	// Such as "GETX" or "PURGE".
+	if i < 0 || methods[i] != method {
+		return -1
 	}
-	return nil
+	return i
 }
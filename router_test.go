@@ -1,17 +1,44 @@
 package router
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"math/big"
 	"math/rand"
 	"mime"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 	"time"
 	// "github.com/astaxie/beego"
 	// beego_context "github.com/astaxie/beego/context"
@@ -162,9 +189,10 @@ func Test_Add_Match(t *testing.T) {
 		testMustAdd(t, root, "/11/:/1")
 		testMustAdd(t, root, "/111/*")
 		// Add param route and static route to '/' at the same time.
-		testMustNotAdd(t, root, "/:")
+		testMustAdd(t, root, "/:")
+		testMustAdd(t, root, "/1/:")
+		// Add different param routes at the same time.
 		testMustNotAdd(t, root, "/*")
-		testMustNotAdd(t, root, "/1/:")
 		testMustNotAdd(t, root, "/1/*")
 		// Add route after a all match route.
 		testMustAdd(t, root, "/2/*")
@@ -206,6 +234,69 @@ func Test_Add_Match(t *testing.T) {
 		if route == nil || len(ctx.Param) != 2 || ctx.Param[0] != "4" || ctx.Param[1] != "6" {
 			t.FailNow()
 		}
+		// Static first, then backtrack to param.
+		ctx.Param = ctx.Param[:0]
+		ctx.Req.URL.Path = "/00"
+		route = root.Match(&ctx)
+		if route == nil || route.path != "/00" || len(ctx.Param) != 0 {
+			t.Fatal(route)
+		}
+		ctx.Req.URL.Path = "/0x"
+		route = root.Match(&ctx)
+		if route == nil || route.path != "/:" || len(ctx.Param) != 1 || ctx.Param[0] != "0x" {
+			t.Fatal(route, ctx.Param)
+		}
+		ctx.Param = ctx.Param[:0]
+		ctx.Req.URL.Path = "/1/1"
+		route = root.Match(&ctx)
+		if route == nil || route.path != "/1/:" || len(ctx.Param) != 1 || ctx.Param[0] != "1" {
+			t.Fatal(route, ctx.Param)
+		}
+	}
+}
+
+func Test_Router_Backtrack(t *testing.T) {
+	var router Router
+	for _, p := range []string{"/users", "/users/new", "/users/:id", "/users/:id/posts", "/:page", "/files/list/all", "/files/*path"} {
+		p := p
+		_, err := router.AddGet(p, func(c *Context) bool {
+			c.Res.Write([]byte(p + " " + strings.Join(c.Param, ",")))
+			return true
+		})
+		testFatalError(t, err)
+	}
+	for path, want := range map[string]string{
+		"/users":           "/users ",
+		"/users/new":       "/users/new ",
+		"/users/newer":     "/users/:id newer",
+		"/users/1/posts":   "/users/:id/posts 1",
+		"/about":           "/:page about",
+		"/files/list/all":  "/files/list/all ",
+		"/files/list/some": "/files/*path list/some",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Fatal(path, w.Body.String())
+		}
+	}
+	// Static route falls through to param route.
+	router.SetFallthrough(true)
+	_, err := router.AddGet("/users/me", func(c *Context) bool { return c.Fallthrough() })
+	testFatalError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/me", nil))
+	if w.Body.String() != "/users/:id me" {
+		t.Fatal(w.Body.String())
+	}
+	// Remove keeps siblings.
+	if !router.RemoveGet("/users/new") {
+		t.FailNow()
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/new", nil))
+	if w.Body.String() != "/users/:id new" {
+		t.Fatal(w.Body.String())
 	}
 }
 
@@ -302,195 +393,4464 @@ func Test_Router_AddStatic(t *testing.T) {
 	}
 }
 
-type testBenchmark struct {
-	// How many levels of directory.
-	benchRouteCount                  int
-	staticRoute, staticUrl           strings.Builder
-	paramRoute, paramUrl             [3]strings.Builder
-	staticParamRoute, staticParamUrl [3]strings.Builder
-	paramStaticRoute, paramStaticUrl [3]strings.Builder
-	myRouter                         Router
-	// ginRouter                        *gin.Engine
-	// beegoRouter                      *beego.ControllerRegister
-}
-
-func (t *testBenchmark) Init() {
-	t.benchRouteCount = 10
-	// /static1.../static_n
-	t.staticRoute.WriteString("/static")
-	t.staticUrl.WriteString("/static")
-	// /param1.../param_n
-	t.paramRoute[0].WriteString("/param")
-	t.paramUrl[0].WriteString("/param")
-	t.paramRoute[1].WriteString("/param")
-	t.paramUrl[1].WriteString("/param")
-	t.paramRoute[2].WriteString("/param")
-	t.paramUrl[2].WriteString("/param")
-	// /static1/param1.../static_n/param_n
-	t.staticParamRoute[0].WriteString("/static_param")
-	t.staticParamUrl[0].WriteString("/static_param")
-	t.staticParamRoute[1].WriteString("/static_param")
-	t.staticParamUrl[1].WriteString("/static_param")
-	t.staticParamRoute[2].WriteString("/static_param")
-	t.staticParamUrl[2].WriteString("/static_param")
-	// /param1/static1.../param_n/static_n
-	t.paramStaticRoute[0].WriteString("/param_static")
-	t.paramStaticUrl[0].WriteString("/param_static")
-	t.paramStaticRoute[1].WriteString("/param_static")
-	t.paramStaticUrl[1].WriteString("/param_static")
-	t.paramStaticRoute[2].WriteString("/param_static")
-	t.paramStaticUrl[2].WriteString("/param_static")
-	for i := 0; i < t.benchRouteCount; i++ {
-		// /static1.../static_n
-		t.staticRoute.WriteString(fmt.Sprintf("/static%d", i))
-		t.staticUrl.WriteString(fmt.Sprintf("/static%d", i))
-		// /param1.../param_n
-		t.paramRoute[0].WriteString("/:")
-		t.paramUrl[0].WriteString(fmt.Sprintf("/param%d", i))
-		t.paramRoute[1].WriteString(fmt.Sprintf("/:param%d", i))
-		t.paramUrl[1].WriteString(fmt.Sprintf("/param%d", i))
-		t.paramRoute[2].WriteString(fmt.Sprintf("/:param%d", i))
-		t.paramUrl[2].WriteString(fmt.Sprintf("/param%d", i))
-		// /static1/param1.../static_n/param_n
-		t.staticParamRoute[0].WriteString(fmt.Sprintf("/static%d/:", i))
-		t.staticParamUrl[0].WriteString(fmt.Sprintf("/static%d/param%d", i, i))
-		t.staticParamRoute[1].WriteString(fmt.Sprintf("/static%d/:param%d", i, i))
-		t.staticParamUrl[1].WriteString(fmt.Sprintf("/static%d/param%d", i, i))
-		t.staticParamRoute[2].WriteString(fmt.Sprintf("/static%d/:param%d", i, i))
-		t.staticParamUrl[2].WriteString(fmt.Sprintf("/static%d/param%d", i, i))
-		// /param1/static1.../param_n/static_n
-		t.paramStaticRoute[0].WriteString(fmt.Sprintf("/:/static%d", i))
-		t.paramStaticUrl[0].WriteString(fmt.Sprintf("/param%d/static%d", i, i))
-		t.paramStaticRoute[1].WriteString(fmt.Sprintf("/:param%d/static%d", i, i))
-		t.paramStaticUrl[1].WriteString(fmt.Sprintf("/param%d/static%d", i, i))
-		t.paramStaticRoute[2].WriteString(fmt.Sprintf("/:param%d/static%d", i, i))
-		t.paramStaticUrl[2].WriteString(fmt.Sprintf("/param%d/static%d", i, i))
-	}
-	// my
-	t.myRouter.AddGet(t.staticRoute.String(), func(c *Context) bool { return true })
-	t.myRouter.AddGet(t.paramRoute[0].String(), func(c *Context) bool { return true })
-	t.myRouter.AddGet(t.staticParamRoute[0].String(), func(c *Context) bool { return true })
-	t.myRouter.AddGet(t.paramStaticRoute[0].String(), func(c *Context) bool { return true })
-	// // gin
-	// gin.SetMode(gin.ReleaseMode)
-	// t.ginRouter = gin.New()
-	// t.ginRouter.GET(t.staticRoute.String(), func(c *gin.Context) {})
-	// t.ginRouter.GET(t.paramRoute[1].String(), func(c *gin.Context) {})
-	// t.ginRouter.GET(t.staticParamRoute[1].String(), func(c *gin.Context) {})
-	// t.ginRouter.GET(t.paramStaticRoute[1].String(), func(c *gin.Context) {})
-	// // beego
-	// t.beegoRouter = beego.NewApp().Handlers
-	// t.beegoRouter.Get(t.staticRoute.String(), func(c *beego_context.Context) {})
-	// t.beegoRouter.Get(t.paramRoute[2].String(), func(c *beego_context.Context) {})
-	// t.beegoRouter.Get(t.staticParamRoute[2].String(), func(c *beego_context.Context) {})
-	// t.beegoRouter.Get(t.paramStaticRoute[2].String(), func(c *beego_context.Context) {})
-}
-
-func testNewBenchmark() *testBenchmark {
-	p := new(testBenchmark)
-	p.Init()
-	return p
-}
-
-func (t *testBenchmark) benchmark(b *testing.B, url string, r http.Handler) {
-	req, e := http.NewRequest(http.MethodGet, url, nil)
-	if e != nil {
-		b.Fatal(e)
-	}
-	h := new(testHandler)
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		r.ServeHTTP(h, req)
+func Test_Router_Recovery(t *testing.T) {
+	var handler testHandler
+	var router Router
+	router.SetRecovery(Recovery)
+	_, err := router.AddGet("/panic/:", func(c *Context) bool {
+		panic("test panic")
+	})
+	testFatalError(t, err)
+	// Production mode, no debug information.
+	testHttpGet("/panic/1", &handler, &router)
+	if handler.buffer.Len() != 0 {
+		t.FailNow()
+	}
+	// Dev mode, debug page.
+	router.SetDevMode(true)
+	testHttpGet("/panic/1", &handler, &router)
+	body := handler.buffer.String()
+	if !strings.Contains(body, "panic: test panic") || !strings.Contains(body, "/panic/:") {
+		t.FailNow()
+	}
+}
+
+func Test_Router_RecoveryAfterPanic(t *testing.T) {
+	var router Router
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	recovered, after := 0, 0
+	router.SetRecovery(func(c *Context) bool {
+		recovered++
+		return true
+	})
+	router.SetAfter(func(c *Context) bool {
+		after++
+		if c.Req.URL.Path == "/after" {
+			panic("after panic")
+		}
+		return true
+	})
+	_, err := router.AddGet("/*", func(c *Context) bool {
+		if c.Req.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	// After chain runs once.
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/after", nil))
+	if recovered != 1 || after != 1 {
+		t.Fatal(recovered, after)
+	}
+	// ErrAbortHandler is passed to http.Server.
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatal(v)
+			}
+		}()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	}()
+	if recovered != 1 || after != 1 {
+		t.Fatal(recovered, after)
+	}
+}
+
+func Test_Router_Logger(t *testing.T) {
+	var handler testHandler
+	var router Router
+	var buf bytes.Buffer
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	router.SetAfter(AccessLog)
+	_, err := router.AddGet("/users/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	handler.Reset()
+	q, _ := http.NewRequest(http.MethodGet, "/users/1", nil)
+	q.Header.Set(RequestIDHeader, "test-request-id")
+	router.ServeHTTP(&handler, q)
+	log := buf.String()
+	if !strings.Contains(log, `"request_id":"test-request-id"`) || !strings.Contains(log, `"route":"/users/:"`) {
+		t.Fatal(log)
+	}
+}
+
+func Test_Router_Stats(t *testing.T) {
+	var handler testHandler
+	var router Router
+	router.SetStats(true)
+	_, err := router.AddGet("/stats/:", func(c *Context) bool {
+		if c.Param[0] == "error" {
+			c.Res.WriteHeader(http.StatusInternalServerError)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddPost("/stats/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	testHttpGet("/stats/1", &handler, &router)
+	testHttpGet("/stats/2", &handler, &router)
+	testHttpGet("/stats/error", &handler, &router)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/stats/1", nil))
+	stats := router.Stats()
+	if len(stats) != 2 || stats[0].Route != "/stats/:" || stats[0].Method != http.MethodGet ||
+		stats[0].Requests != 3 || stats[0].Errors != 1 {
+		t.Fatal(stats)
+	}
+	if stats[1].Method != http.MethodPost || stats[1].Requests != 1 || stats[1].Errors != 0 {
+		t.Fatal(stats)
+	}
+	if stats[0].P50 <= 0 || stats[0].P99 < stats[0].P50 {
+		t.Fatal(stats)
+	}
+}
+
+func Test_Router_OnSlowRequest(t *testing.T) {
+	var handler testHandler
+	var router Router
+	var slow []string
+	router.OnSlowRequest(10*time.Millisecond, func(c *Context, d time.Duration) {
+		slow = append(slow, c.Req.URL.Path)
+	})
+	_, err := router.AddGet("/fast", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	_, err = router.AddGet("/slow", func(c *Context) bool {
+		time.Sleep(20 * time.Millisecond)
+		return true
+	})
+	testFatalError(t, err)
+	testHttpGet("/fast", &handler, &router)
+	testHttpGet("/slow", &handler, &router)
+	if len(slow) != 1 || slow[0] != "/slow" {
+		t.Fatal(slow)
+	}
+}
+
+func Test_Router_Recorder(t *testing.T) {
+	var handler testHandler
+	var router Router
+	recorder := NewRecorder(2)
+	recorder.MaxBodySize = 4
+	router.SetRecorder(recorder)
+	_, err := router.AddPost("/echo", func(c *Context) bool {
+		b, _ := ioutil.ReadAll(c.Req.Body)
+		c.Res.Write(b)
+		return true
+	})
+	testFatalError(t, err)
+	for _, body := range []string{"1", "22", "333333"} {
+		handler.Reset()
+		q, _ := http.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+		q.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(&handler, q)
+		// Handler must read the full body.
+		if handler.buffer.String() != body {
+			t.Fatal(handler.buffer.String())
+		}
+	}
+	records := recorder.Records()
+	if len(records) != 2 || string(records[0].Body) != "22" || string(records[1].Body) != "3333" ||
+		string(records[1].ResponseBody) != "3333" || records[1].Header.Get("Authorization") != redactedValue {
+		t.Fatal(records)
+	}
+	// Read error is returned to handler.
+	router.SetMaxBodySize(2)
+	_, err = router.AddPost("/read", func(c *Context) bool {
+		b, err := ioutil.ReadAll(c.Req.Body)
+		var maxBytes *http.MaxBytesError
+		if string(b) != "22" || !errors.As(err, &maxBytes) {
+			t.Error(string(b), err)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/read", strings.NewReader("222")))
+	router.SetMaxBodySize(0)
+	// Replay.
+	router.SetRecorder(nil)
+	res, err := Replay(&router, &records[0])
+	testFatalError(t, err)
+	if res.Code != http.StatusOK || res.Body.String() != "22" {
+		t.FailNow()
 	}
 }
 
-// Test match
-func Test_Benchmark(t *testing.T) {
+func Test_Router_StatsDSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	testFatalError(t, err)
+	defer conn.Close()
+	sink, err := NewStatsDSink(conn.LocalAddr().String())
+	testFatalError(t, err)
+	defer sink.Close()
+	sink.Prefix = "test."
 	var handler testHandler
-	testBench := testNewBenchmark()
-	testBench.myRouter.SetNotfound(func(c *Context) bool {
+	var router Router
+	router.SetMetrics(MultiSink{sink})
+	_, err = router.AddGet("/metrics/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	testHttpGet("/metrics/1", &handler, &router)
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	testFatalError(t, err)
+	if string(buf[:n]) != "test.http.requests:1|c|#route:/metrics/:,method:GET,status:200" {
+		t.Fatal(string(buf[:n]))
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	tc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || tc.TraceIDString() != "4bf92f3577b34da6a3ce929d0e0e4736" || !tc.Sampled {
 		t.FailNow()
-		return false
+	}
+	if _, ok = ParseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01"); ok {
+		t.FailNow()
+	}
+	h := make(http.Header)
+	h.Set(B3Header, "a3ce929d0e0e4736-00f067aa0ba902b7-1")
+	tc, ok = ParseB3(h)
+	if !ok || tc.TraceIDString() != "0000000000000000a3ce929d0e0e4736" || !tc.Sampled {
+		t.FailNow()
+	}
+	// Proxy injects trace headers.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get(TraceparentHeader))
+	}))
+	defer upstream.Close()
+	proxy, err := NewProxyHandler(upstream.URL)
+	testFatalError(t, err)
+	var router Router
+	var spanID string
+	_, err = router.AddGet("/proxy", func(c *Context) bool {
+		spanID = c.Trace().SpanIDString()
+		return true
+	}, proxy.Handle)
+	testFatalError(t, err)
+	q := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+	q.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Body.String() != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+spanID+"-01" {
+		t.Fatal(res.Body.String())
+	}
+}
+
+func Test_Limiter(t *testing.T) {
+	var router Router
+	limiter := NewLimiter(1, 1, 0)
+	block := make(chan struct{})
+	running := make(chan struct{}, 2)
+	_, err := router.AddGet("/limit", limiter.Wrap(func(c *Context) bool {
+		running <- struct{}{}
+		<-block
+		return true
+	}))
+	testFatalError(t, err)
+	serve := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/limit", nil))
+		return res
+	}
+	done := make(chan int, 2)
+	// Running.
+	go func() { done <- serve().Code }()
+	<-running
+	// Waiting.
+	go func() { done <- serve().Code }()
+	for {
+		if _, waiting := limiter.Load(); waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Queue is full.
+	res := serve()
+	if res.Code != http.StatusServiceUnavailable || res.Header().Get("Retry-After") != "1" {
+		t.FailNow()
+	}
+	close(block)
+	if <-done != http.StatusOK || <-done != http.StatusOK {
+		t.FailNow()
+	}
+}
+
+func Test_Breaker(t *testing.T) {
+	var router Router
+	var states []string
+	breaker := NewBreaker("test")
+	breaker.MinRequests = 2
+	breaker.OpenTimeout = 20 * time.Millisecond
+	breaker.OnStateChange = func(name string, from, to BreakerState) {
+		states = append(states, to.String())
+	}
+	status := http.StatusInternalServerError
+	_, err := router.AddGet("/breaker", breaker.Wrap(func(c *Context) bool {
+		c.Res.WriteHeader(status)
+		return true
+	}))
+	testFatalError(t, err)
+	serve := func() int {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/breaker", nil))
+		return res.Code
+	}
+	// Open.
+	serve()
+	serve()
+	if breaker.State() != BreakerOpen || serve() != http.StatusServiceUnavailable {
+		t.FailNow()
+	}
+	// Half-open, then close.
+	time.Sleep(breaker.OpenTimeout)
+	status = http.StatusOK
+	if serve() != http.StatusOK || breaker.State() != BreakerClosed {
+		t.FailNow()
+	}
+	if strings.Join(states, ",") != "open,half-open,closed" {
+		t.Fatal(states)
+	}
+}
+
+func Test_RateLimiter(t *testing.T) {
+	var router Router
+	limiter := NewRateLimiter(2, time.Minute)
+	_, err := router.AddGet("/rate", limiter.Handle)
+	testFatalError(t, err)
+	serve := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/rate", nil))
+		return res
+	}
+	res := serve()
+	if res.Code != http.StatusOK || res.Header().Get("RateLimit-Limit") != "2" || res.Header().Get("RateLimit-Remaining") != "1" {
+		t.FailNow()
+	}
+	serve()
+	res = serve()
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") == "" || res.Header().Get("RateLimit-Remaining") != "0" {
+		t.FailNow()
+	}
+	// Legacy headers.
+	limiter.LegacyHeaders = true
+	res = serve()
+	if res.Header().Get("X-RateLimit-Limit") != "2" || res.Header().Get("RateLimit-Limit") != "" {
+		t.FailNow()
+	}
+}
+
+func Test_MemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	n, ttl, err := store.Incr(ctx, "a", time.Minute)
+	if err != nil || n != 1 || ttl <= 0 || ttl > time.Minute {
+		t.FailNow()
+	}
+	n, _, _ = store.Incr(ctx, "a", time.Minute)
+	if n != 2 {
+		t.FailNow()
+	}
+	now := time.Now()
+	store.SlidingWindow(ctx, "b", now, time.Second, 10)
+	store.SlidingWindow(ctx, "b", now.Add(500*time.Millisecond), time.Second, 10)
+	n, _ = store.SlidingWindow(ctx, "b", now.Add(1200*time.Millisecond), time.Second, 10)
+	if n != 2 {
+		t.Fatal(n)
+	}
+	// Rejected hits are not recorded.
+	store.SlidingWindow(ctx, "c", now, time.Second, 2)
+	store.SlidingWindow(ctx, "c", now.Add(100*time.Millisecond), time.Second, 2)
+	for i := 0; i < 3; i++ {
+		n, _ = store.SlidingWindow(ctx, "c", now.Add(900*time.Millisecond), time.Second, 2)
+		if n != 3 {
+			t.Fatal(n)
+		}
+	}
+	n, _ = store.SlidingWindow(ctx, "c", now.Add(1050*time.Millisecond), time.Second, 2)
+	if n != 2 {
+		t.Fatal(n)
+	}
+	// Idempotency keys.
+	ok, err := store.Claim(ctx, "d", time.Minute)
+	if err != nil || !ok {
+		t.FailNow()
+	}
+	ok, _ = store.Claim(ctx, "d", time.Minute)
+	if ok {
+		t.FailNow()
+	}
+	ok, _ = store.Claim(ctx, "e", -time.Second)
+	if !ok {
+		t.FailNow()
+	}
+	// Expired key can be claimed again.
+	ok, _ = store.Claim(ctx, "e", time.Minute)
+	if !ok {
+		t.FailNow()
+	}
+}
+
+func Test_Session(t *testing.T) {
+	store := NewMemorySessionStore(time.Minute)
+	defer store.Close()
+	sessions := NewSessionManager(store)
+	var router Router
+	router.SetBefore(sessions.Handle)
+	router.SetAfter(sessions.Commit)
+	_, err := router.AddGet("/set", func(c *Context) bool {
+		c.Session().Set("user", "test")
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/login", func(c *Context) bool {
+		return sessions.Regenerate(c) == nil
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/get", func(c *Context) bool {
+		v, _ := c.Session().Get("user").(string)
+		io.WriteString(c.Res, v)
+		return true
+	})
+	testFatalError(t, err)
+	serve := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			q.AddCookie(cookie)
+		}
+		router.ServeHTTP(res, q)
+		return res
+	}
+	res := serve("/set", nil)
+	cookie := res.Result().Cookies()[0]
+	if serve("/get", cookie).Body.String() != "test" {
+		t.FailNow()
+	}
+	// Unknown id from client is not adopted.
+	res = serve("/get", &http.Cookie{Name: "session_id", Value: "attacker"})
+	if res.Body.String() != "" || res.Result().Cookies()[0].Value == "attacker" {
+		t.FailNow()
+	}
+	// Regenerate.
+	res = serve("/login", cookie)
+	newCookie := res.Result().Cookies()[0]
+	if newCookie.Value == cookie.Value || serve("/get", newCookie).Body.String() != "test" {
+		t.FailNow()
+	}
+	if serve("/get", cookie).Body.String() != "" {
+		t.FailNow()
+	}
+}
+
+func Test_FileSessionStore(t *testing.T) {
+	store := &FileSessionStore{Dir: t.TempDir()}
+	ctx := context.Background()
+	session := &Session{ID: newSessionID(), Expires: time.Now().Add(time.Minute)}
+	session.Set("a", "1")
+	testFatalError(t, store.Save(ctx, session))
+	loaded, err := store.Load(ctx, session.ID)
+	testFatalError(t, err)
+	if loaded == nil || loaded.Get("a") != "1" {
+		t.FailNow()
+	}
+	if _, err = store.Load(ctx, "../a"); err != nil {
+		t.FailNow()
+	}
+	// Concurrent saves of the same session.
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			s := &Session{ID: session.ID, Expires: session.Expires, Values: map[string]interface{}{"a": strconv.Itoa(i)}}
+			for j := 0; j < 50; j++ {
+				if err := store.Save(ctx, s); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wait.Wait()
+	if loaded, _ = store.Load(ctx, session.ID); loaded == nil || loaded.Get("a") == nil {
+		t.FailNow()
+	}
+	if files, _ := filepath.Glob(filepath.Join(store.Dir, "*.tmp")); len(files) != 0 {
+		t.Fatal(files)
+	}
+	session.Expires = time.Now().Add(-time.Second)
+	testFatalError(t, store.Save(ctx, session))
+	testFatalError(t, store.GC())
+	if loaded, _ = store.Load(ctx, session.ID); loaded != nil {
+		t.FailNow()
+	}
+}
+
+func Test_Flash(t *testing.T) {
+	var router Router
+	testFatalError(t, router.SetCookieKey([]byte("test key")))
+	_, err := router.AddPost("/form", func(c *Context) bool {
+		testFatalError(t, c.Flash("error", "invalid name"))
+		testFatalError(t, c.Flash("info", "try again"))
+		http.Redirect(c.Res, c.Req, "/form", http.StatusSeeOther)
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/form", func(c *Context) bool {
+		for _, f := range c.Flashes() {
+			io.WriteString(c.Res, f.Kind+":"+f.Message+";")
+		}
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/form", nil))
+	cookies := res.Result().Cookies()
+	if len(cookies) != 1 || strings.Contains(cookies[0].Value, "invalid") {
+		t.FailNow()
+	}
+	res = httptest.NewRecorder()
+	q := httptest.NewRequest(http.MethodGet, "/form", nil)
+	q.AddCookie(cookies[0])
+	router.ServeHTTP(res, q)
+	if res.Body.String() != "error:invalid name;info:try again;" || res.Result().Cookies()[0].MaxAge >= 0 {
+		t.Fatal(res.Body.String())
+	}
+	// Tampered cookie.
+	res = httptest.NewRecorder()
+	q = httptest.NewRequest(http.MethodGet, "/form", nil)
+	q.AddCookie(&http.Cookie{Name: FlashCookieName, Value: cookies[0].Value[1:]})
+	router.ServeHTTP(res, q)
+	if res.Body.String() != "" {
+		t.FailNow()
+	}
+}
+
+func Test_JWT(t *testing.T) {
+	ctx := context.Background()
+	j := NewJWT([]byte("test key"))
+	token, err := j.IssueToken(Claims{"sub": "user1"}, time.Minute)
+	testFatalError(t, err)
+	claims, err := j.VerifyToken(ctx, token)
+	if err != nil || claims.Subject() != "user1" {
+		t.FailNow()
+	}
+	if _, err = j.VerifyToken(ctx, token+"a"); err == nil {
+		t.FailNow()
+	}
+	expired, _ := j.IssueToken(Claims{"sub": "user1"}, -time.Second)
+	if _, err = j.VerifyToken(ctx, expired); err != errTokenExpired {
+		t.FailNow()
+	}
+	// Rotation.
+	refresh1, err := j.IssueRefreshToken("user1")
+	testFatalError(t, err)
+	refresh2, claims, err := j.RotateRefreshToken(ctx, refresh1)
+	if err != nil || claims.Subject() != "user1" {
+		t.FailNow()
+	}
+	// Reuse revokes the family.
+	if _, _, err = j.RotateRefreshToken(ctx, refresh1); err != errTokenRevoked {
+		t.FailNow()
+	}
+	if _, _, err = j.RotateRefreshToken(ctx, refresh2); err != errTokenRevoked {
+		t.FailNow()
+	}
+	// Concurrent rotations, only one succeeds.
+	refresh3, err := j.IssueRefreshToken("user1")
+	testFatalError(t, err)
+	var wait sync.WaitGroup
+	var rotated int32
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if _, _, err := j.RotateRefreshToken(ctx, refresh3); err == nil {
+				atomic.AddInt32(&rotated, 1)
+			}
+		}()
+	}
+	wait.Wait()
+	if rotated != 1 {
+		t.Fatal(rotated)
+	}
+	// Middleware.
+	var router Router
+	_, err = router.AddGet("/me", j.Handle, func(c *Context) bool {
+		io.WriteString(c.Res, c.Claims().Subject())
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	q := httptest.NewRequest(http.MethodGet, "/me", nil)
+	q.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(res, q)
+	if res.Body.String() != "user1" {
+		t.FailNow()
+	}
+	res = httptest.NewRecorder()
+	q.Header.Set("Authorization", "Bearer "+refresh2)
+	router.ServeHTTP(res, q)
+	if res.Code != http.StatusUnauthorized {
+		t.FailNow()
+	}
+}
+
+func Test_OIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(crand.Reader, 2048)
+	testFatalError(t, err)
+	var issuer, nonce string
+	// Fake provider.
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"authorization_endpoint":"%[1]s/auth","token_endpoint":"%[1]s/token","jwks_uri":"%[1]s/jwks"}`, issuer)
+		case "/jwks":
+			fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+				base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+		case "/token":
+			if r.FormValue("code") != "test-code" || r.FormValue("code_verifier") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"k1"}`))
+			payload, _ := json.Marshal(map[string]interface{}{
+				"iss": issuer, "aud": "client", "sub": "user1", "nonce": nonce,
+				"exp": time.Now().Add(time.Minute).Unix(),
+			})
+			s := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+			sum := sha256.Sum256([]byte(s))
+			sig, _ := rsa.SignPKCS1v15(crand.Reader, key, crypto.SHA256, sum[:])
+			fmt.Fprintf(w, `{"id_token":"%s"}`, s+"."+base64.RawURLEncoding.EncodeToString(sig))
+		}
+	}))
+	defer provider.Close()
+	issuer = provider.URL
+	store := NewMemorySessionStore(time.Minute)
+	defer store.Close()
+	oidc := &OIDCProvider{
+		Name:        "test",
+		Issuer:      issuer,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+		Sessions:    NewSessionManager(store),
+	}
+	var router Router
+	testFatalError(t, router.AddOIDC(oidc, "/login", "/callback"))
+	_, err = router.AddGet("/me", oidc.Sessions.Handle, oidc.Handle, func(c *Context) bool {
+		io.WriteString(c.Res, c.Claims().Subject())
+		return true
 	})
-	testHttpGet(testBench.staticUrl.String(), &handler, &testBench.myRouter)
-	testHttpGet(testBench.paramUrl[0].String(), &handler, &testBench.myRouter)
-	testHttpGet(testBench.staticParamUrl[0].String(), &handler, &testBench.myRouter)
-	testHttpGet(testBench.paramStaticUrl[0].String(), &handler, &testBench.myRouter)
-	// // gin
-	// testBench.ginRouter.NoRoute(func(c *gin.Context) {
-	// 	t.FailNow()
-	// })
-	// testHttpGet(testBench.staticUrl.String(), &handler, testBench.ginRouter)
-	// testHttpGet(testBench.paramUrl[1].String(), &handler, testBench.ginRouter)
-	// testHttpGet(testBench.staticParamUrl[1].String(), &handler, testBench.ginRouter)
-	// testHttpGet(testBench.paramStaticUrl[1].String(), &handler, testBench.ginRouter)
-	// // beego
-	// beego.ErrorHandler("404", func(writer http.ResponseWriter, request *http.Request) {
-	// 	t.FailNow()
-	// })
-	// testHttpGet(testBench.staticUrl.String(), &handler, testBench.beegoRouter)
-	// testHttpGet(testBench.paramUrl[2].String(), &handler, testBench.beegoRouter)
-	// testHttpGet(testBench.staticParamUrl[2].String(), &handler, testBench.beegoRouter)
-	// testHttpGet(testBench.paramStaticUrl[2].String(), &handler, testBench.beegoRouter)
-}
-
-func Benchmark_Match_My_Static(b *testing.B) {
-	testBench := testNewBenchmark()
-	testBench.benchmark(b, testBench.staticUrl.String(), &testBench.myRouter)
-}
-
-// func Benchmark_Match_Gin_Static(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.staticUrl.String(), testBench.ginRouter)
-// }
-
-// func Benchmark_Match_Beego_Static(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.staticUrl.String(), testBench.beegoRouter)
-// }
-
-func Benchmark_Match_My_Param(b *testing.B) {
-	testBench := testNewBenchmark()
-	testBench.benchmark(b, testBench.paramUrl[0].String(), &testBench.myRouter)
-}
-
-// func Benchmark_Match_Gin_Param(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.paramUrl[1].String(), testBench.ginRouter)
-// }
-
-// func Benchmark_Match_Beego_Param(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.paramUrl[2].String(), testBench.beegoRouter)
-// }
-
-func Benchmark_Match_My_StaticParam(b *testing.B) {
-	testBench := testNewBenchmark()
-	testBench.benchmark(b, testBench.staticParamUrl[0].String(), &testBench.myRouter)
-}
-
-// func Benchmark_Match_Gin_StaticParam(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.staticParamUrl[1].String(), testBench.ginRouter)
-// }
-
-// func Benchmark_Match_Beego_StaticParam(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.staticParamUrl[2].String(), testBench.beegoRouter)
-// }
-
-func Benchmark_Match_My_ParamStatic(b *testing.B) {
-	testBench := testNewBenchmark()
-	testBench.benchmark(b, testBench.paramStaticUrl[0].String(), &testBench.myRouter)
-}
-
-// func Benchmark_Match_Gin_ParamStatic(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.paramStaticUrl[1].String(), testBench.ginRouter)
-// }
-
-// func Benchmark_Match_Beego_ParamStatic(b *testing.B) {
-// 	testBench := testNewBenchmark()
-// 	testBench.benchmark(b, testBench.paramStaticUrl[2].String(), testBench.beegoRouter)
-// }
+	testFatalError(t, err)
+	serve := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			q.AddCookie(cookie)
+		}
+		router.ServeHTTP(res, q)
+		return res
+	}
+	// Login.
+	res := serve("/login?return=/me", nil)
+	cookie := res.Result().Cookies()[0]
+	location, err := url.Parse(res.Header().Get("Location"))
+	testFatalError(t, err)
+	if location.Path != "/auth" || location.Query().Get("client_id") != "client" {
+		t.Fatal(location)
+	}
+	nonce = location.Query().Get("nonce")
+	// Invalid state.
+	if serve("/callback?code=test-code&state=bad", cookie).Code != http.StatusBadRequest {
+		t.FailNow()
+	}
+	res = serve("/login?return=/me", cookie)
+	location, _ = url.Parse(res.Header().Get("Location"))
+	nonce = location.Query().Get("nonce")
+	// Callback discovers endpoints, such as handled by another instance.
+	oidc.AuthURL, oidc.TokenURL, oidc.JWKSURL = "", "", ""
+	res = serve("/callback?code=test-code&state="+location.Query().Get("state"), cookie)
+	if res.Code != http.StatusFound || res.Header().Get("Location") != "/me" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	newCookie := res.Result().Cookies()[0]
+	if newCookie.Value == cookie.Value || serve("/me", newCookie).Body.String() != "user1" {
+		t.FailNow()
+	}
+	if serve("/me", nil).Code != http.StatusUnauthorized {
+		t.FailNow()
+	}
+}
+
+func Test_Router_SwaggerUI(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/users/:/files/*", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	_, err = router.AddPost("/users", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	doc := router.OpenAPI()
+	op := doc.Paths["/users/{param0}/files/{path}"]["get"]
+	if op == nil || len(op.Parameters) != 2 || op.Parameters[1].Name != "path" || doc.Paths["/users"]["post"] == nil {
+		t.Fatal(doc.Paths)
+	}
+	// Auth.
+	testFatalError(t, router.AddSwaggerUI("/docs", func(c *Context) bool {
+		if c.Req.Header.Get("Authorization") == "" {
+			c.Res.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	if res.Code != http.StatusUnauthorized {
+		t.FailNow()
+	}
+	res = httptest.NewRecorder()
+	q := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	q.Header.Set("Authorization", "test")
+	router.ServeHTTP(res, q)
+	if !strings.Contains(res.Body.String(), `url: "\/docs\/openapi.json"`) {
+		t.Fatal(res.Body.String())
+	}
+	res = httptest.NewRecorder()
+	q.URL.Path = "/docs/openapi.json"
+	router.ServeHTTP(res, q)
+	if !strings.Contains(res.Body.String(), `"/users/{param0}/files/{path}"`) {
+		t.Fatal(res.Body.String())
+	}
+}
+
+func Test_Router_AddStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":  {Data: []byte("<html></html>")},
+		"js/index.js": {Data: []byte("var a;")},
+	}
+	for _, cache := range []bool{true, false} {
+		var router Router
+		testFatalError(t, router.AddStaticFS(http.MethodGet, "/static", fsys, cache, "html"))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/static/index", nil))
+		if res.Body.String() != "<html></html>" || !strings.HasPrefix(res.Header().Get("Content-Type"), "text/html") {
+			t.Fatal(cache, res.Body.String(), res.Header())
+		}
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/static/js/index.js", nil))
+		if res.Body.String() != "var a;" {
+			t.FailNow()
+		}
+	}
+}
+
+func Test_Router_AddOpenAPI(t *testing.T) {
+	if p, err := openAPIToRoute("/users/{id}/files/{path*}"); err != nil || p != "/users/:/files/*" {
+		t.FailNow()
+	}
+	if _, err := openAPIToRoute("/users/{path*}/a"); err == nil {
+		t.FailNow()
+	}
+	if _, err := openAPIToRoute("/users/a{id}"); err == nil {
+		t.FailNow()
+	}
+	doc := []byte(`{"openapi":"3.0.3","paths":{
+		"/users/{id}":{"parameters":[],"get":{"operationId":"getUser"},"delete":{"operationId":"deleteUser"}}
+	}}`)
+	var router Router
+	registry := HandlerRegistry{
+		"getUser": {func(c *Context) bool {
+			io.WriteString(c.Res, "get "+c.Param[0])
+			return true
+		}},
+	}
+	if err := router.AddOpenAPI(doc, registry); err == nil {
+		t.FailNow()
+	}
+	registry["deleteUser"] = []HandlerFunc{func(c *Context) bool { return true }}
+	testFatalError(t, router.AddOpenAPI(doc, registry))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if res.Body.String() != "get 1" || router.RouteDelete("/users/:") == nil {
+		t.FailNow()
+	}
+	// All or nothing.
+	doc = []byte(`{"paths":{"/a":{"get":{"operationId":"getUser"}},"/users/{id}":{"get":{"operationId":"getUser"}}}}`)
+	if err := router.AddOpenAPI(doc, registry); err == nil {
+		t.FailNow()
+	}
+	if router.RouteGet("/a") != nil {
+		t.FailNow()
+	}
+}
+
+func Test_Route_Deprecate(t *testing.T) {
+	var router Router
+	router.SetStats(true)
+	route, err := router.AddGet("/v1/users", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	route.Deprecate(sunset, "https://example.com/deprecation")
+	// Split route, deprecation must move along with handlers.
+	_, err = router.AddGet("/v1/user", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	if !strings.HasPrefix(res.Header().Get("Deprecation"), "@") ||
+		res.Header().Get("Sunset") != "Tue, 01 Jan 2030 00:00:00 GMT" ||
+		res.Header().Get("Link") != `<https://example.com/deprecation>; rel="deprecation"` {
+		t.Fatal(res.Header())
+	}
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/user", nil))
+	if res.Header().Get("Deprecation") != "" {
+		t.FailNow()
+	}
+	for _, s := range router.Stats() {
+		if (s.Route == "/v1/users") != (s.Deprecated == 1) {
+			t.Fatal(s)
+		}
+	}
+}
+
+func Test_Context_Query(t *testing.T) {
+	var c Context
+	c.Req = httptest.NewRequest(http.MethodGet, "/?id=1&id=2&id[]=3&filter[status]=active&filter[type]=a&page=2&tag=x", nil)
+	if strings.Join(c.QueryArray("id"), ",") != "1,2,3" {
+		t.FailNow()
+	}
+	m := c.QueryMap("filter")
+	if len(m) != 2 || m["status"] != "active" || m["type"] != "a" {
+		t.FailNow()
+	}
+	var q struct {
+		ID     []int64           `query:"id"`
+		Filter map[string]string `query:"filter"`
+		Page   int               `query:"page"`
+		Tag    string            `query:"tag"`
+		Skip   string            `query:"-"`
+	}
+	testFatalError(t, c.BindQuery(&q))
+	if len(q.ID) != 3 || q.ID[2] != 3 || q.Filter["status"] != "active" || q.Page != 2 || q.Tag != "x" {
+		t.Fatal(q)
+	}
+	c.queryValues = nil
+	c.Req = httptest.NewRequest(http.MethodGet, "/?page=a", nil)
+	if c.BindQuery(&q) == nil {
+		t.FailNow()
+	}
+}
+
+func Test_Digest(t *testing.T) {
+	if Digest([]byte("hello")) != "sha-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=" {
+		t.FailNow()
+	}
+	var router Router
+	_, err := router.AddPost("/upload", DigestHandler, func(c *Context) bool {
+		b, _ := io.ReadAll(c.Req.Body)
+		c.SetDigest(b)
+		c.Res.Write(b)
+		return true
+	})
+	testFatalError(t, err)
+	serve := func(digest string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("hello"))
+		q.Header.Set("Digest", digest)
+		router.ServeHTTP(res, q)
+		return res
+	}
+	res := serve("SHA-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=")
+	if res.Code != http.StatusOK || res.Body.String() != "hello" || res.Header().Get("Digest") != Digest([]byte("hello")) {
+		t.FailNow()
+	}
+	if serve("sha-256=AAAA").Code != http.StatusBadRequest || serve("md5=AAAA").Code != http.StatusBadRequest {
+		t.FailNow()
+	}
+	// CacheHandler.
+	h := &CacheHandler{Data: []byte("hello"), Digest: true}
+	_, err = router.AddGet("/static", h.Handle)
+	testFatalError(t, err)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/static", nil))
+	if res.Header().Get("Digest") != Digest([]byte("hello")) {
+		t.FailNow()
+	}
+}
+
+func Test_Server_ConnData(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/count", func(c *Context) bool {
+		d := c.ConnData()
+		n, _ := d.Get("count").(int)
+		d.Set("count", n+1)
+		fmt.Fprint(c.Res, n+1)
+		return true
+	})
+	testFatalError(t, err)
+	server := NewServer("127.0.0.1:0", &router)
+	server.ConnContextHook = func(ctx context.Context, conn net.Conn) context.Context {
+		ctx.Value(connDataKey{}).(*ConnData).Set("count", 10)
+		return ctx
+	}
+	l, err := net.Listen("tcp", server.Addr)
+	testFatalError(t, err)
+	server.Addr = l.Addr().String()
+	l.Close()
+	go server.Run("", "")
+	defer server.Shutdown(context.Background())
+	get := func(client *http.Client) string {
+		for i := 0; i < 100; i++ {
+			res, err := client.Get("http://" + server.Addr + "/count")
+			if err != nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			b, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			return string(b)
+		}
+		t.FailNow()
+		return ""
+	}
+	// Keep-alive connection shares state.
+	client := &http.Client{Transport: &http.Transport{}}
+	if get(client) != "11" || get(client) != "12" {
+		t.FailNow()
+	}
+	// New connection.
+	if get(&http.Client{Transport: &http.Transport{}}) != "11" {
+		t.FailNow()
+	}
+}
+
+func Test_Router_HeadFallback(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/users/:", func(c *Context) bool {
+		io.WriteString(c.Res, "user "+c.Param[0])
+		return true
+	})
+	testFatalError(t, err)
+	router.SetNotfound(Notfound)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodHead, "/users/1", nil))
+	if res.Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	router.SetHeadFallback(true)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodHead, "/users/1", nil))
+	if res.Code != http.StatusOK || res.Body.Len() != 0 || res.Header().Get("Content-Length") != "6" {
+		t.Fatal(res.Code, res.Header(), res.Body.String())
+	}
+	// Standalone.
+	res = httptest.NewRecorder()
+	w := NewHeadWriter(res)
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, "abc")
+	if res.Code != http.StatusOK || len(res.Header()) != 0 {
+		t.FailNow()
+	}
+	w.Finish()
+	if res.Code != http.StatusCreated || res.Body.Len() != 0 || res.Header().Get("Content-Length") != "3" {
+		t.FailNow()
+	}
+}
+
+func Test_CORS(t *testing.T) {
+	var router Router
+	cors := &CORS{AllowOrigins: []string{"*"}, MaxAge: 10 * time.Minute, AllowPrivateNetwork: true}
+	router.SetBefore(cors.Handle)
+	_, err := router.AddPut("/public/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	route, err := router.AddPut("/admin/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	route.SetCORS(&CORS{AllowOrigins: []string{"https://admin.example.com"}, AllowCredentials: true})
+	preflight := func(path, origin string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodOptions, path, nil)
+		q.Header.Set("Origin", origin)
+		q.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		q.Header.Set("Access-Control-Request-Private-Network", "true")
+		router.ServeHTTP(res, q)
+		return res
+	}
+	res := preflight("/public/1", "https://a.com")
+	if res.Code != http.StatusNoContent || res.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		res.Header().Get("Access-Control-Max-Age") != "600" ||
+		res.Header().Get("Access-Control-Allow-Private-Network") != "true" {
+		t.Fatal(res.Header())
+	}
+	// Route override.
+	res = preflight("/admin/1", "https://a.com")
+	if res.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.FailNow()
+	}
+	res = preflight("/admin/1", "https://admin.example.com")
+	if res.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" ||
+		res.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		res.Header().Get("Access-Control-Allow-Private-Network") != "" {
+		t.Fatal(res.Header())
+	}
+	// Actual request.
+	res = httptest.NewRecorder()
+	q := httptest.NewRequest(http.MethodPut, "/admin/1", nil)
+	q.Header.Set("Origin", "https://admin.example.com")
+	router.ServeHTTP(res, q)
+	if res.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" {
+		t.FailNow()
+	}
+	// Credentials are not allowed for origins matched only by "*".
+	cors.AllowOrigins = []string{"*", "https://b.com"}
+	cors.AllowCredentials = true
+	res = preflight("/public/1", "https://a.com")
+	if res.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		res.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatal(res.Header())
+	}
+	res = preflight("/public/1", "https://b.com")
+	if res.Header().Get("Access-Control-Allow-Origin") != "https://b.com" ||
+		res.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal(res.Header())
+	}
+}
+
+func Test_Honeypot(t *testing.T) {
+	var router Router
+	deny := NewDenyList()
+	hits := 0
+	router.SetHoneypot(Honeypot{OnHit: func(c *Context) { hits++ }, DenyList: deny, DenyFor: time.Minute})
+	router.SetBefore(deny.Handle)
+	testFatalError(t, router.AddHoneypot("/wp-login.php", "/.env"))
+	_, err := router.AddGet("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	// None is added if one fails.
+	if router.AddHoneypot("/admin.php", "/") == nil || router.RoutePost("/admin.php") != nil {
+		t.FailNow()
+	}
+	serve := func(method, path string) int {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(method, path, nil)
+		q.RemoteAddr = "1.2.3.4:1234"
+		router.ServeHTTP(res, q)
+		return res.Code
+	}
+	if serve(http.MethodGet, "/") != http.StatusOK {
+		t.FailNow()
+	}
+	if serve(http.MethodPost, "/wp-login.php") != http.StatusNotFound || hits != 1 {
+		t.FailNow()
+	}
+	if serve(http.MethodGet, "/") != http.StatusForbidden || !deny.Denied("1.2.3.4") {
+		t.FailNow()
+	}
+	deny.Allow("1.2.3.4")
+	if serve(http.MethodGet, "/") != http.StatusOK {
+		t.FailNow()
+	}
+}
+
+type testGeoResolver map[string]GeoLocation
+
+func (r testGeoResolver) Resolve(ip string) (GeoLocation, error) {
+	return r[ip], nil
+}
+
+func Test_GeoIP(t *testing.T) {
+	var router Router
+	router.SetBefore(GeoIP(testGeoResolver{
+		"1.1.1.1": {Country: "US", Region: "CA"},
+		"2.2.2.2": {Country: "CN"},
+	}))
+	route, err := router.AddGet("/", func(c *Context) bool {
+		c.Res.Write([]byte(c.Geo().Region))
+		return true
+	})
+	testFatalError(t, err)
+	route.AllowCountries("us", "ca")
+	serve := func(ip string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodGet, "/", nil)
+		q.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(res, q)
+		return res
+	}
+	if res := serve("1.1.1.1"); res.Code != http.StatusOK || res.Body.String() != "CA" {
+		t.FailNow()
+	}
+	if serve("2.2.2.2").Code != http.StatusForbidden || serve("3.3.3.3").Code != http.StatusForbidden {
+		t.FailNow()
+	}
+}
+
+func Test_Audit(t *testing.T) {
+	var router Router
+	var buf bytes.Buffer
+	auditor := NewAuditor(NewAuditWriter(&buf))
+	auditor.Header = []string{"Authorization", "User-Agent"}
+	auditor.Actor = func(c *Context) string { return "alice" }
+	router.SetAuditor(auditor)
+	route, err := router.AddDelete("/users/:/posts/:", func(c *Context) bool {
+		c.AuditField("password", "123")
+		c.AuditField("reason", "spam")
+		c.Res.WriteHeader(http.StatusForbidden)
+		return true
+	})
+	testFatalError(t, err)
+	route.Audit("post.delete")
+	_, err = router.AddGet("/users", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if buf.Len() != 0 {
+		t.FailNow()
+	}
+	q := httptest.NewRequest(http.MethodDelete, "/users/1/posts/2", nil)
+	q.Header.Set("Authorization", "Bearer x")
+	q.Header.Set("User-Agent", "test")
+	router.ServeHTTP(res, q)
+	var e AuditEvent
+	testFatalError(t, json.Unmarshal(buf.Bytes(), &e))
+	if e.Actor != "alice" || e.Action != "post.delete" || e.Resource != "/users/1/posts/2" ||
+		e.Outcome != AuditFailure || e.Status != http.StatusForbidden ||
+		e.Header["Authorization"] != redactedValue || e.Header["User-Agent"] != "test" ||
+		e.Fields["password"] != redactedValue || e.Fields["reason"] != "spam" {
+		t.Fatal(buf.String())
+	}
+}
+
+func Test_Redactor(t *testing.T) {
+	redactor := NewRedactor("password", "user.token", "items.*.secret")
+	data := redactor.Redact([]byte(`{"password":"1","user":{"name":"a","token":"t","password":"2"},` +
+		`"token":"keep","items":[{"secret":"s","card":"4111 1111 1111 1111"}],"amount":12.50}`))
+	var v map[string]interface{}
+	testFatalError(t, json.Unmarshal(data, &v))
+	user := v["user"].(map[string]interface{})
+	item := v["items"].([]interface{})[0].(map[string]interface{})
+	if v["password"] != redactedValue || user["token"] != redactedValue || user["password"] != redactedValue ||
+		user["name"] != "a" || v["token"] != "keep" || item["secret"] != redactedValue ||
+		item["card"] != redactedValue || !strings.Contains(string(data), "12.50") {
+		t.Fatal(string(data))
+	}
+	if string(redactor.Redact([]byte("password=1"))) != "password=1" {
+		t.FailNow()
+	}
+	// Recorder.
+	var router Router
+	router.SetRedactor(redactor)
+	recorder := NewRecorder(1)
+	router.SetRecorder(recorder)
+	_, err := router.AddPost("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"password":"1"}`)))
+	if string(recorder.Records()[0].Body) != `{"password":"[REDACTED]"}` {
+		t.FailNow()
+	}
+}
+
+type testGRPCInvoker struct{}
+
+func (testGRPCInvoker) Invoke(ctx context.Context, method, codec string, md http.Header, req []byte) ([]byte, http.Header, error) {
+	if method != "/test.Echo/Say" {
+		return nil, nil, &GRPCError{Code: GRPCUnimplemented, Message: "no method"}
+	}
+	return append([]byte(md.Get("X-Name")+":"), req...), http.Header{"X-Done": {"1"}}, nil
+}
+
+func Test_GRPCBridge(t *testing.T) {
+	var router Router
+	_, err := router.AddGRPC("/rpc", testGRPCInvoker{})
+	testFatalError(t, err)
+	// gRPC-Web.
+	body := []byte{0, 0, 0, 0, 2, 'h', 'i'}
+	q := httptest.NewRequest(http.MethodPost, "/rpc/test.Echo/Say", bytes.NewReader(body))
+	q.Header.Set("Content-Type", "application/grpc-web+proto")
+	q.Header.Set("X-Name", "a")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	data := res.Body.Bytes()
+	if res.Code != http.StatusOK || len(data) < 9 || string(data[5:9]) != "a:hi" || data[9] != 0x80 ||
+		!strings.Contains(string(data[14:]), "grpc-status: 0\r\n") || !strings.Contains(string(data[14:]), "x-done: 1") {
+		t.Fatal(data)
+	}
+	// gRPC-Web text.
+	q = httptest.NewRequest(http.MethodPost, "/rpc/test.Echo/None", strings.NewReader(base64.StdEncoding.EncodeToString(body)))
+	q.Header.Set("Content-Type", "application/grpc-web-text")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	data, err = base64.StdEncoding.DecodeString(res.Body.String())
+	testFatalError(t, err)
+	if data[0] != 0x80 || !strings.Contains(string(data), "grpc-status: 12") {
+		t.Fatal(string(data))
+	}
+	// Connect.
+	q = httptest.NewRequest(http.MethodPost, "/rpc/test.Echo/Say", strings.NewReader(`{}`))
+	q.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Code != http.StatusOK || res.Body.String() != ":{}" || res.Header().Get("Trailer-X-Done") != "1" {
+		t.FailNow()
+	}
+	q = httptest.NewRequest(http.MethodPost, "/rpc/test.Echo/None", strings.NewReader(`{}`))
+	q.Header.Set("Content-Type", "application/proto")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Code != http.StatusNotImplemented || !strings.Contains(res.Body.String(), `"unimplemented"`) {
+		t.FailNow()
+	}
+}
+
+func Test_ImageHandler(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x * 6), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	testFatalError(t, png.Encode(&buf, src))
+	h := NewImageHandler(fstest.MapFS{"a/b.png": {Data: buf.Bytes(), ModTime: time.Now()}}, 2)
+	var router Router
+	_, err := router.AddGet("/images/*", h.Handle)
+	testFatalError(t, err)
+	get := func(url string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, url, nil))
+		return res
+	}
+	size := func(res *httptest.ResponseRecorder) image.Point {
+		img, err := png.Decode(res.Body)
+		testFatalError(t, err)
+		return img.Bounds().Size()
+	}
+	if res := get("/images/a/b.png"); res.Code != http.StatusOK || size(res) != image.Pt(40, 20) {
+		t.FailNow()
+	}
+	if res := get("/images/a/b.png?w=10"); res.Code != http.StatusOK || size(res) != image.Pt(10, 5) {
+		t.FailNow()
+	}
+	if res := get("/images/a/b.png?w=10&h=10"); size(res) != image.Pt(10, 10) || res.Header().Get("Content-Type") != "image/png" {
+		t.FailNow()
+	}
+	if h.cache.Len() != 2 {
+		t.FailNow()
+	}
+	if get("/images/a/b.png?w=x").Code != http.StatusBadRequest || get("/images/a/c.png?w=1").Code != http.StatusNotFound ||
+		get("/images/../b.png?w=10").Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	// Computed side is bounded, source is bounded.
+	h.MaxWidth = 30
+	if res := get("/images/a/b.png?h=20"); res.Code != http.StatusOK || size(res) != image.Pt(30, 15) {
+		t.FailNow()
+	}
+	h.MaxSourcePixels = 40*20 - 1
+	if get("/images/a/b.png?h=10").Code != http.StatusUnsupportedMediaType {
+		t.FailNow()
+	}
+	// Extreme aspect ratio.
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 1000, 1), image.Rect(0, 0, 1, 1000)} {
+		if size := resizeImage(image.NewRGBA(r), 1, 2048, 0, 0).Bounds().Size(); size != image.Pt(1, 2048) {
+			t.Fatal(size)
+		}
+		if size := resizeImage(image.NewRGBA(r), 2048, 1, 0, 0).Bounds().Size(); size != image.Pt(2048, 1) {
+			t.Fatal(size)
+		}
+	}
+}
+
+func Test_LRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.FailNow()
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 || c.Len() != 2 {
+		t.FailNow()
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.FailNow()
+	}
+}
+
+func Test_Renderer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html": {Data: []byte(`<title>{{block "title" .}}default{{end}}</title>` +
+			`<script src="{{asset "app.js"}}"></script>{{block "content" .}}{{end}}`)},
+		"page.html": {Data: []byte(`{{define "content"}}<form><input value="{{csrfToken}}">{{upper .}}` +
+			`{{range flashes}}[{{.Message}}]{{end}}</form>{{end}}`)},
+	}
+	var router Router
+	router.SetCookieKey([]byte("key"))
+	renderer := NewRenderer(fsys, "layout.html")
+	renderer.Manifest = map[string]string{"app.js": "app.123.js"}
+	renderer.CSRFToken = func(c *Context) string { return "token" }
+	router.SetRenderer(renderer)
+	router.AddTemplateFuncs(template.FuncMap{"upper": strings.ToUpper})
+	_, err := router.AddGet("/", func(c *Context) bool {
+		testFatalError(t, c.Render(http.StatusOK, "page.html", "<a>"))
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/flash", func(c *Context) bool {
+		c.Flash("info", "hi")
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/flash", nil))
+	q := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range res.Result().Cookies() {
+		q.AddCookie(cookie)
+	}
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Body.String() != `<title>default</title><script src="app.123.js"></script>`+
+		`<form><input value="token">&lt;A&gt;[hi]</form>` {
+		t.Fatal(res.Body.String())
+	}
+	// Not found.
+	router.SetRenderer(NewRenderer(fsys, ""))
+	router.AddGet("/none", func(c *Context) bool {
+		if c.Render(http.StatusOK, "none.html", nil) == nil {
+			t.FailNow()
+		}
+		return true
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/none", nil))
+}
+
+func Test_ProxyHedging(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-time.After(time.Second):
+			w.Write([]byte("slow"))
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast" + r.URL.Path))
+	}))
+	defer fast.Close()
+	h, err := NewProxyHandler(slow.URL, fast.URL)
+	testFatalError(t, err)
+	h.SetHedging(0.95, 20*time.Millisecond)
+	var router Router
+	_, err = router.AddGet("/*", h.Handle)
+	testFatalError(t, err)
+	start := time.Now()
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/a", nil))
+	if res.Body.String() != "fast/a" || time.Since(start) > 500*time.Millisecond {
+		t.Fatal(res.Body.String())
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request is not canceled")
+	}
+	// Not idempotent.
+	_, err = router.AddPost("/*", h.Handle)
+	testFatalError(t, err)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/a", strings.NewReader("x")))
+	if res.Body.String() != "slow" {
+		t.Fatal(res.Body.String())
+	}
+}
+
+func Test_HTTPClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(r.Header.Get(RequestIDHeader) + "," + r.Header.Get(TraceparentHeader)))
+	}))
+	defer upstream.Close()
+	var router Router
+	router.SetClient(&Client{Timeout: time.Second})
+	_, err := router.AddGet("/", func(c *Context) bool {
+		req, err := http.NewRequest(http.MethodGet, upstream.URL, nil)
+		testFatalError(t, err)
+		// Request of caller is not modified, as http.RoundTripper requires.
+		res, err := c.HTTPClient().Transport.RoundTrip(req)
+		testFatalError(t, err)
+		defer res.Body.Close()
+		if len(req.Header) != 0 {
+			t.Error(req.Header)
+		}
+		io.Copy(c.Res, res.Body)
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/slow", func(c *Context) bool {
+		_, err := c.HTTPClient().Get(upstream.URL + "/slow")
+		if err == nil {
+			t.Error("deadline is not propagated")
+		}
+		return true
+	})
+	testFatalError(t, err)
+	q := httptest.NewRequest(http.MethodGet, "/", nil)
+	q.Header.Set(RequestIDHeader, "abc")
+	q.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	parts := strings.Split(res.Body.String(), ",")
+	if parts[0] != "abc" || !strings.HasPrefix(parts[1], "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Fatal(res.Body.String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+}
+
+func Test_ParamConversion(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/:/:/:", func(c *Context) bool {
+		id, err := c.ParamInt(0)
+		if err != nil {
+			return c.BadRequest(err)
+		}
+		u, err := c.ParamUUID(1)
+		if err != nil {
+			return c.BadRequest(err)
+		}
+		d, err := c.ParamTime(2, "2006-01-02")
+		if err != nil {
+			return c.BadRequest(err)
+		}
+		if _, err = c.ParamInt64(3); err == nil {
+			t.Error("index out of range")
+		}
+		fmt.Fprintf(c.Res, "%d %s %s", id, u, d.Format("Jan 2"))
+		return true
+	})
+	testFatalError(t, err)
+	get := func(url string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, url, nil))
+		return res
+	}
+	res := get("/12/4E2B4AB2-1A2B-4C3D-8E9F-0A1B2C3D4E5F/2024-03-05")
+	if res.Body.String() != "12 4e2b4ab2-1a2b-4c3d-8e9f-0a1b2c3d4e5f Mar 5" {
+		t.Fatal(res.Body.String())
+	}
+	res = get("/x/4e2b4ab2-1a2b-4c3d-8e9f-0a1b2c3d4e5f/2024-03-05")
+	var p Problem
+	testFatalError(t, json.Unmarshal(res.Body.Bytes(), &p))
+	if res.Code != http.StatusBadRequest || res.Header().Get("Content-Type") != "application/problem+json" ||
+		p.Status != http.StatusBadRequest || !strings.Contains(p.Detail, `"x" is not a valid int`) {
+		t.Fatal(res.Body.String())
+	}
+	if get("/1/4e2b4ab2/2024-03-05").Code != http.StatusBadRequest ||
+		get("/1/4e2b4ab2-1a2b-4c3d-8e9f-0a1b2c3d4e5f/03-05").Code != http.StatusBadRequest {
+		t.FailNow()
+	}
+}
+
+func Test_BindParams(t *testing.T) {
+	type request struct {
+		UserID int64  `uri:"id" query:"-" json:"-"`
+		File   string `uri:"1" query:"-" json:"-"`
+		Page   int    `uri:"-" query:"page" json:"-"`
+		Name   string `uri:"-" query:"-" json:"name"`
+	}
+	var router Router
+	var v request
+	_, err := router.AddPost("/users/:id/files/*", func(c *Context) bool {
+		v = request{}
+		err := c.Bind(&v)
+		if err != nil {
+			return c.BadRequest(err)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	q := httptest.NewRequest(http.MethodPost, "/users/12/files/a/b.txt?page=3", strings.NewReader(`{"name":"x"}`))
+	q.Header.Set("Content-Type", "application/json; charset=utf-8")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if v != (request{UserID: 12, File: "a/b.txt", Page: 3, Name: "x"}) {
+		t.Fatal(v)
+	}
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/users/x/files/a", nil))
+	if res.Code != http.StatusBadRequest {
+		t.FailNow()
+	}
+}
+
+func Test_BindHeader(t *testing.T) {
+	type request struct {
+		Tenant   string   `header:"x-tenant-id,required"`
+		Language string   `header:"Accept-Language,default=en"`
+		Version  int      `header:"X-Version,default=1"`
+		Tags     []string `header:"X-Tag,default=a,b"`
+		Ignore   string   `header:"-"`
+	}
+	var v request
+	q := httptest.NewRequest(http.MethodGet, "/", nil)
+	q.Header.Set("X-Tenant-Id", "t1")
+	q.Header.Set("X-Version", "2")
+	q.Header.Set("Ignore", "x")
+	c := &Context{Req: q}
+	testFatalError(t, c.BindHeader(&v))
+	if v.Tenant != "t1" || v.Language != "en" || v.Version != 2 || len(v.Tags) != 2 || v.Tags[1] != "b" || v.Ignore != "" {
+		t.Fatal(v)
+	}
+	q.Header.Del("X-Tenant-Id")
+	q.Header.Set("X-Version", "x")
+	err := c.BindHeader(&request{})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Fields) != 2 || ve.Fields[0].Key != "X-Tenant-Id" ||
+		ve.Fields[0].Message != "required" || ve.Fields[1].Field != "Version" {
+		t.Fatal(err)
+	}
+}
+
+func Test_BindForm(t *testing.T) {
+	type Page struct {
+		Size int `form:"size,default=10"`
+	}
+	type request struct {
+		Page
+		Birthday time.Time   `form:"birthday,layout=2006-01-02"`
+		Created  time.Time   `form:"created"`
+		Age      *int        `form:"age"`
+		Score    *float64    `form:"score"`
+		IDs      []int64     `form:"id"`
+		Dates    []time.Time `form:"date,layout=2006-01-02"`
+	}
+	form := url.Values{
+		"birthday": {"2000-01-02"},
+		"created":  {"2024-01-02T03:04:05Z"},
+		"age":      {"20"},
+		"id":       {"1", "2"},
+		"date":     {"2024-01-01", "2024-01-02"},
+	}
+	q := httptest.NewRequest(http.MethodPost, "/?size=5", strings.NewReader(form.Encode()))
+	q.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := &Context{Req: q}
+	var v request
+	testFatalError(t, c.BindForm(&v))
+	if v.Size != 5 || v.Birthday.Day() != 2 || v.Created.Hour() != 3 || v.Age == nil || *v.Age != 20 ||
+		v.Score != nil || len(v.IDs) != 2 || v.IDs[1] != 2 || len(v.Dates) != 2 || v.Dates[1].Day() != 2 {
+		t.Fatal(v)
+	}
+	// Errors.
+	form = url.Values{"birthday": {"x"}, "age": {"y"}, "size": {"z"}}
+	q = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	q.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	c = &Context{Req: q, Res: res}
+	err := c.BindForm(&request{})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Fields) != 3 || ve.Fields[0].Field != "Page.Size" {
+		t.Fatal(err)
+	}
+	c.BadRequest(err)
+	var p Problem
+	testFatalError(t, json.Unmarshal(res.Body.Bytes(), &p))
+	if len(p.Errors) != 3 || p.Errors[1].Key != "birthday" {
+		t.Fatal(res.Body.String())
+	}
+}
+
+func Test_Charset(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/html", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, "café €")
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/json", func(c *Context) bool {
+		c.WriteJSON(http.StatusCreated, "café")
+		return true
+	})
+	testFatalError(t, err)
+	get := func(path, charset string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		if charset != "" {
+			q.Header.Set("Accept-Charset", charset)
+		}
+		router.ServeHTTP(res, q)
+		return res
+	}
+	res := get("/json", "")
+	if res.Code != http.StatusCreated || res.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.FailNow()
+	}
+	res = get("/html", "iso-8859-1")
+	if res.Header().Get("Content-Type") != "text/html; charset=utf-8" || res.Body.String() != "café €" {
+		t.FailNow()
+	}
+	router.SetTranscodeCharset(true)
+	res = get("/html", "iso-8859-1, utf-8;q=0.5")
+	if res.Header().Get("Content-Type") != "text/html; charset=iso-8859-1" || res.Body.String() != "caf\xe9 ?" {
+		t.Fatal(res.Header(), res.Body.Bytes())
+	}
+	// Offers of the same quality are in order of names.
+	if names := charsetNames(); !sort.StringsAreSorted(names) {
+		t.Fatal(names)
+	}
+	for i := 0; i < 10; i++ {
+		res = get("/html", "us-ascii, iso-8859-1")
+		if res.Header().Get("Content-Type") != "text/html; charset=iso-8859-1" {
+			t.Fatal(res.Header())
+		}
+	}
+	res = get("/html", "utf-8, *;q=0.1")
+	if res.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.FailNow()
+	}
+	res = get("/html", "koi8-r")
+	if res.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.FailNow()
+	}
+}
+
+func Test_NDJSON(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/", func(c *Context) bool {
+		w := c.NDJSON()
+		for i := 0; i < 3; i++ {
+			testFatalError(t, w.Send(map[string]int{"n": i}))
+		}
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if res.Header().Get("Content-Type") != ContentTypeNDJSON || !res.Flushed ||
+		res.Body.String() != "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n" {
+		t.Fatal(res.Body.String())
+	}
+}
+
+func Test_WriteCSV(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/", func(c *Context) bool {
+		err := c.WriteCSV(http.StatusOK, []string{"id", "name"}, func(w *csv.Writer) error {
+			for i := 1; i <= 2; i++ {
+				err := w.Write([]string{strconv.Itoa(i), "a,b"})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, CSVOptions{Filename: "导出.csv", BOM: true})
+		testFatalError(t, err)
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if res.Header().Get("Content-Type") != ContentTypeCSV ||
+		res.Header().Get("Content-Disposition") != "attachment; filename*=utf-8''%E5%AF%BC%E5%87%BA.csv" ||
+		res.Body.String() != "\xef\xbb\xbfid,name\n1,\"a,b\"\n2,\"a,b\"\n" {
+		t.Fatal(res.Header(), res.Body.String())
+	}
+}
+
+func Test_AddFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	testFatalError(t, os.WriteFile(file, []byte("<html></html>"), 0644))
+	var router Router
+	testFatalError(t, router.AddFile(http.MethodGet, "/", file, false))
+	testFatalError(t, router.AddFile(http.MethodGet, "/home", file, true))
+	if router.AddFile(http.MethodGet, "/dir", dir, true) == nil {
+		t.FailNow()
+	}
+	for _, p := range []string{"/", "/home"} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, p, nil))
+		if res.Code != http.StatusOK || res.Body.String() != "<html></html>" ||
+			!strings.HasPrefix(res.Header().Get("Content-Type"), "text/html") {
+			t.Fatal(p, res.Code)
+		}
+	}
+}
+
+func Test_AddWellKnown(t *testing.T) {
+	var router Router
+	if router.AddWellKnown(&WellKnown{SecurityTxt: &SecurityTxt{}}) == nil {
+		t.FailNow()
+	}
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	testFatalError(t, router.AddWellKnown(&WellKnown{
+		SecurityTxt: &SecurityTxt{
+			Contact:            []string{"mailto:security@example.com"},
+			Expires:            expires,
+			PreferredLanguages: []string{"en", "zh"},
+		},
+		ChangePasswordURL: "/account/password",
+		AssetLinks: []AssetLink{{
+			Relation: []string{"delegate_permission/common.handle_all_urls"},
+			Target:   AssetLinkTarget{Namespace: "android_app", PackageName: "com.example"},
+		}},
+		AppleAppSiteAssociation: map[string]interface{}{"applinks": map[string]interface{}{}},
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/.well-known/"+path, nil))
+		return res
+	}
+	res := get("security.txt")
+	if res.Body.String() != "Contact: mailto:security@example.com\nExpires: 2030-01-02T03:04:05Z\nPreferred-Languages: en, zh\n" {
+		t.Fatal(res.Body.String())
+	}
+	res = get("change-password")
+	if res.Code != http.StatusFound || res.Header().Get("Location") != "/account/password" {
+		t.FailNow()
+	}
+	res = get("assetlinks.json")
+	if res.Header().Get("Content-Type") != ContentTypeJSON || !strings.Contains(res.Body.String(), `"package_name":"com.example"`) {
+		t.FailNow()
+	}
+	if get("apple-app-site-association").Body.String() != `{"applinks":{}}` {
+		t.FailNow()
+	}
+}
+
+func Test_WhenUnless(t *testing.T) {
+	var router Router
+	var calls []string
+	mark := func(s string) HandlerFunc {
+		return func(c *Context) bool {
+			calls = append(calls, s)
+			return true
+		}
+	}
+	deny := func(c *Context) bool {
+		c.Res.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	router.SetBefore(
+		Unless(PathPrefix("/public/"), When(HasHeader("X-Deny", "1"), deny)),
+		When(ContentTypeIs("application/json"), mark("json")),
+	)
+	_, err := router.AddPost("/*", mark("handler"))
+	testFatalError(t, err)
+	serve := func(path, deny, contentType string) int {
+		calls = calls[:0]
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodPost, path, nil)
+		q.Header.Set("X-Deny", deny)
+		q.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(res, q)
+		return res.Code
+	}
+	if serve("/a", "1", "") != http.StatusUnauthorized || len(calls) != 0 {
+		t.FailNow()
+	}
+	if serve("/public/a", "1", "text/plain") != http.StatusOK || strings.Join(calls, ",") != "handler" {
+		t.FailNow()
+	}
+	if serve("/a", "0", "application/json; charset=utf-8") != http.StatusOK || strings.Join(calls, ",") != "json,handler" {
+		t.FailNow()
+	}
+}
+
+func Test_ChainWrapAround(t *testing.T) {
+	var calls []string
+	mark := func(s string, ok bool) HandlerFunc {
+		return func(c *Context) bool {
+			calls = append(calls, s)
+			return ok
+		}
+	}
+	c := new(Context)
+	if !Chain(mark("a", true), mark("b", true))(c) || strings.Join(calls, ",") != "a,b" {
+		t.FailNow()
+	}
+	calls = calls[:0]
+	if Chain(mark("a", false), mark("b", true))(c) || strings.Join(calls, ",") != "a" {
+		t.FailNow()
+	}
+	calls = calls[:0]
+	wrap := WrapAround(mark("before", true), mark("after", true))
+	if wrap(mark("a", false), mark("b", true))(c) || strings.Join(calls, ",") != "before,a,after" {
+		t.Fatal(calls)
+	}
+	calls = calls[:0]
+	if !wrap(mark("a", true))(c) || strings.Join(calls, ",") != "before,a,after" {
+		t.FailNow()
+	}
+	calls = calls[:0]
+	if WrapAround(mark("before", false), mark("after", true))(mark("a", true))(c) || strings.Join(calls, ",") != "before" {
+		t.FailNow()
+	}
+	// After is called on panic.
+	calls = calls[:0]
+	func() {
+		defer func() { recover() }()
+		wrap(func(c *Context) bool { panic("x") })(c)
+	}()
+	if strings.Join(calls, ",") != "before,after" {
+		t.FailNow()
+	}
+}
+
+func Test_RouteSetHeader(t *testing.T) {
+	var router Router
+	route, err := router.AddGet("/", func(c *Context) bool {
+		c.Res.Header().Set("Cache-Control", "no-store")
+		return true
+	})
+	testFatalError(t, err)
+	route.SetHeader("X-Frame-Options", "DENY")
+	route.SetHeader("Cache-Control", "public")
+	route.SetHeader("X-Removed", "1")
+	route.SetHeader("X-Removed", "")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if res.Header().Get("X-Frame-Options") != "DENY" || res.Header().Get("Cache-Control") != "no-store" ||
+		res.Header().Get("X-Removed") != "" {
+		t.Fatal(res.Header())
+	}
+}
+
+func Test_RouteLogSampling(t *testing.T) {
+	var router Router
+	var buf bytes.Buffer
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	router.SetAfter(AccessLog)
+	health, err := router.AddGet("/healthz", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	health.SetLogSampling(0)
+	metrics, err := router.AddGet("/metrics", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	metrics.SetLogSampling(0.5)
+	_, err = router.AddGet("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	count := func(path string, n int) int {
+		buf.Reset()
+		for i := 0; i < n; i++ {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+		return strings.Count(buf.String(), "\n")
+	}
+	if count("/healthz", 10) != 0 || count("/", 10) != 10 {
+		t.FailNow()
+	}
+	if n := count("/metrics", 1000); n < 350 || n > 650 {
+		t.Fatal(n)
+	}
+}
+
+func Test_SamplingLogger(t *testing.T) {
+	var router Router
+	var buf bytes.Buffer
+	router.SetLogger(NewSamplingLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))), &LogSampling{
+		Levels: map[string]LogSampler{"error": NewFirstNSampler(2, time.Hour)},
+		Routes: map[string]LogSampler{"/healthz": ProbabilitySampler(0)},
+	}))
+	router.SetAfter(AccessLog)
+	_, err := router.AddGet("/healthz", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	_, err = router.AddGet("/", func(c *Context) bool {
+		c.Logger().Error("db down")
+		c.Logger().Error("db down")
+		c.Logger().Error("db down")
+		c.Logger().Error("cache down")
+		return true
+	})
+	testFatalError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if buf.Len() != 0 {
+		t.FailNow()
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Count(buf.String(), "db down") != 2 || strings.Count(buf.String(), "cache down") != 1 ||
+		strings.Count(buf.String(), `"msg":"access"`) != 1 {
+		t.Fatal(buf.String())
+	}
+}
+
+func Test_Dump(t *testing.T) {
+	var router Router
+	var buf bytes.Buffer
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	dump := NewDump()
+	dump.MaxBodySize = 8
+	router.SetBefore(dump.Handle)
+	_, err := router.AddPost("/", func(c *Context) bool {
+		body, _ := io.ReadAll(c.Req.Body)
+		c.Res.Header().Set("Content-Type", "text/plain")
+		c.Res.Write(body)
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/bin", func(c *Context) bool {
+		c.Res.Write([]byte{0x89, 'P', 'N', 'G', 0, 0})
+		return true
+	})
+	testFatalError(t, err)
+	serve := func(q *http.Request) string {
+		buf.Reset()
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, q)
+		return buf.String()
+	}
+	q := httptest.NewRequest(http.MethodPost, "/?debug_dump=1", strings.NewReader("hello world"))
+	if serve(q) != "" {
+		t.FailNow()
+	}
+	router.SetDevMode(true)
+	if serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))) != "" {
+		t.FailNow()
+	}
+	out := serve(httptest.NewRequest(http.MethodPost, "/?debug_dump=1", strings.NewReader("hello world")))
+	if !strings.Contains(out, `POST /?debug_dump=1 HTTP/1.1\n`) || !strings.Contains(out, `200 OK\nContent-Type: text/plain`) ||
+		strings.Count(out, `hello wo\n[truncated 3 bytes]`) != 2 {
+		t.Fatal(out)
+	}
+	q = httptest.NewRequest(http.MethodGet, "/bin", nil)
+	q.Header.Set("X-Debug-Dump", "1")
+	if out = serve(q); !strings.Contains(out, "[binary 6 bytes]") {
+		t.Fatal(out)
+	}
+	// Read error.
+	body := io.MultiReader(strings.NewReader("hel"), iotest.ErrReader(errors.New("broken")))
+	out = serve(httptest.NewRequest(http.MethodPost, "/?debug_dump=1", body))
+	if !strings.Contains(out, `hel\n[read error: broken]\n`) {
+		t.Fatal(out)
+	}
+}
+
+func Test_ConsoleAccessLog(t *testing.T) {
+	var router Router
+	var console, structured bytes.Buffer
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&structured, nil))))
+	router.SetAfter(DevAccessLog(&console, true))
+	_, err := router.AddGet("/users/:id", func(c *Context) bool {
+		c.Res.WriteHeader(http.StatusNotFound)
+		return true
+	})
+	testFatalError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if console.Len() != 0 || !strings.Contains(structured.String(), `"status":404`) {
+		t.FailNow()
+	}
+	structured.Reset()
+	router.SetDevMode(true)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	line := console.String()
+	if structured.Len() != 0 || !strings.HasPrefix(line, "[ROUTER] ") ||
+		!strings.Contains(line, colorYellow+" 404 "+colorReset) ||
+		!strings.Contains(line, colorBlue+" GET     "+colorReset+` "/users/1" /users/:`) {
+		t.Fatal(line)
+	}
+}
+
+func Test_Phases(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	ok := func(c *Context) bool { return true }
+	_, err := router.AddGet("/users/:", ok)
+	testFatalError(t, err)
+	_, err = router.AddPost("/users/:", ok)
+	testFatalError(t, err)
+	_, err = router.AddOptions("/explicit", func(c *Context) bool {
+		c.Res.WriteHeader(http.StatusAccepted)
+		return true
+	})
+	testFatalError(t, err)
+	serve := func(method, path string, preflight bool) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		q := httptest.NewRequest(method, path, nil)
+		if preflight {
+			q.Header.Set("Origin", "https://a.com")
+			q.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		router.ServeHTTP(res, q)
+		return res
+	}
+	// Disabled by default.
+	if serve(http.MethodDelete, "/users/1", false).Code != http.StatusNotFound ||
+		serve(http.MethodOptions, "/users/1", false).Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	router.SetAutoOptions(true)
+	router.SetMethodNotAllowed(true)
+	router.SetHeadFallback(true)
+	router.SetCORS(&CORS{AllowOrigins: []string{"*"}})
+	res := serve(http.MethodDelete, "/users/1", false)
+	if res.Code != http.StatusMethodNotAllowed || res.Header().Get("Allow") != "GET, POST, HEAD, OPTIONS" {
+		t.Fatal(res.Code, res.Header())
+	}
+	res = serve(http.MethodOptions, "/users/1", false)
+	if res.Code != http.StatusNoContent || res.Header().Get("Allow") != "GET, POST, HEAD, OPTIONS" {
+		t.FailNow()
+	}
+	if serve(http.MethodDelete, "/none", false).Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	// Preflight precedes explicit OPTIONS route by default.
+	res = serve(http.MethodOptions, "/explicit", true)
+	if res.Code != http.StatusNoContent || res.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.FailNow()
+	}
+	testFatalError(t, router.SetPhaseOrder(PhaseMatch, PhasePreflight, PhaseNotfound))
+	if serve(http.MethodOptions, "/explicit", true).Code != http.StatusAccepted {
+		t.FailNow()
+	}
+	if serve(http.MethodDelete, "/users/1", false).Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	if router.SetPhaseOrder(PhaseNotfound, PhaseMatch) == nil || router.SetPhaseOrder(PhaseMatch) == nil {
+		t.FailNow()
+	}
+	// Override.
+	testFatalError(t, router.SetPhaseOrder(defaultPhaseOrder...))
+	router.SetPhaseHandler(PhaseMethodNotAllowed, func(c *Context) bool {
+		return c.WriteProblem(&Problem{Status: http.StatusMethodNotAllowed}) == nil
+	})
+	res = serve(http.MethodPut, "/users/1", false)
+	if res.Code != http.StatusMethodNotAllowed || res.Header().Get("Content-Type") != "application/problem+json" ||
+		res.Header().Get("Allow") == "" {
+		t.FailNow()
+	}
+}
+
+func Test_Server_Shutdown(t *testing.T) {
+	var handler testHandler
+	var router Router
+	router.SetStats(true)
+	var inflight, routeInflight int64
+	_, err := router.AddGet("/drain", func(c *Context) bool {
+		inflight = router.InFlight()
+		routeInflight = router.Stats()[0].InFlight
+		return true
+	})
+	testFatalError(t, err)
+	testHttpGet("/drain", &handler, &router)
+	if inflight != 1 || routeInflight != 1 {
+		t.Fatal(inflight, routeInflight)
+	}
+	if router.InFlight() != 0 || router.Stats()[0].InFlight != 0 {
+		t.Fatal(router.Stats())
+	}
+	server := NewServer(":0", &router)
+	var draining bool
+	server.OnShutdown(func(ctx context.Context) {
+		draining = router.Draining()
+	})
+	testFatalError(t, server.Shutdown(context.Background()))
+	if !draining {
+		t.Fatal("hook not called while draining")
+	}
+}
+
+func Test_New(t *testing.T) {
+	router := New(WithSlashPolicy(SlashRedirect), WithCaseFolding(true), WithMaxBodySize(4), WithDevMode(true))
+	if !router.DevMode() {
+		t.FailNow()
+	}
+	_, err := router.AddGet("/Users/:", func(c *Context) bool {
+		c.Res.Write([]byte(c.Param[0]))
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/docs", func(c *Context) bool {
+		c.Res.Write([]byte("docs"))
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddPost("/upload", func(c *Context) bool {
+		_, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			c.Res.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	// Case folding, param keeps case.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/Bob", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Bob" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	// Slash redirect.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/Docs/?a=1", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/Docs?a=1" {
+		t.Fatal(w.Code, w.Header())
+	}
+	// Slash ignore.
+	router.SetSlashPolicy(SlashIgnore)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "docs" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	// Max body size.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("12345")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(w.Code)
+	}
+	// Strict.
+	router.SetSlashPolicy(SlashStrict)
+	router.SetNotfound(Notfound)
+	for _, p := range []string{"/docs/", "/users/Bob/"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatal(p, w.Code)
+		}
+	}
+}
+
+func Test_Route_Pattern(t *testing.T) {
+	var router Router
+	route, err := router.AddGet("/users/:id/files/*file", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	if route.Pattern() != "/users/:id/files/*file" {
+		t.Fatal(route.Pattern())
+	}
+	if params := route.Params(); len(params) != 2 || params[0] != "id" || params[1] != "file" {
+		t.Fatal(params)
+	}
+	route, err = router.AddGet("/groups/:/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	if route.Pattern() != "/groups/:/:" {
+		t.Fatal(route.Pattern())
+	}
+	if params := route.Params(); len(params) != 2 || params[0] != "" || params[1] != "" {
+		t.Fatal(params)
+	}
+	if router.OpenAPI().Paths["/users/{id}/files/{file}"] == nil {
+		t.Fatal(router.OpenAPI().Paths)
+	}
+}
+
+func Test_Context_Fallthrough(t *testing.T) {
+	router := New()
+	router.SetHeadFallback(true)
+	router.SetNotfound(Notfound)
+	_, err := router.AddHead("/ab", func(c *Context) bool {
+		if c.Req.Header.Get("X-Experiment") == "" {
+			return c.Fallthrough()
+		}
+		c.Res.Header().Set("X-Route", "head")
+		return true
+	})
+	testFatalError(t, err)
+	route, err := router.AddGet("/ab", func(c *Context) bool {
+		c.Res.Header().Set("X-Route", "get")
+		return true
+	})
+	testFatalError(t, err)
+	route.SetHeader("X-Get", "1")
+	_, err = router.AddGet("/none", func(c *Context) bool {
+		return c.Fallthrough()
+	})
+	testFatalError(t, err)
+	// Disabled, chain just stops.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/ab", nil))
+	if w.Header().Get("X-Route") != "" {
+		t.Fatal(w.Header())
+	}
+	router.SetFallthrough(true)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/ab", nil))
+	if w.Header().Get("X-Route") != "get" {
+		t.Fatal(w.Header())
+	}
+	w = httptest.NewRecorder()
+	q := httptest.NewRequest(http.MethodHead, "/ab", nil)
+	q.Header.Set("X-Experiment", "1")
+	router.ServeHTTP(w, q)
+	if w.Header().Get("X-Route") != "head" {
+		t.Fatal(w.Header())
+	}
+	// No next route.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/none", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatal(w.Code)
+	}
+	// Headers of the route fell through are removed, headers before matching are kept.
+	router.SetBefore(func(c *Context) bool {
+		c.Res.Header().Set("X-Before", "1")
+		return true
+	})
+	route, err = router.AddGet("/cd", func(c *Context) bool {
+		c.Res.Header().Set("X-Handler", "1")
+		return c.Fallthrough()
+	})
+	testFatalError(t, err)
+	route.SetHeader("X-Static", "1")
+	route.Deprecate(time.Now().Add(time.Hour), "")
+	_, err = router.AddGet("/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cd", nil))
+	if w.Header().Get("X-Before") != "1" || w.Header().Get("X-Static") != "" || w.Header().Get("X-Handler") != "" ||
+		w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Fatal(w.Header())
+	}
+}
+
+func Test_Route_Priority(t *testing.T) {
+	var router Router
+	for _, p := range []string{"/users/new", "/users/:id", "/users/:id/*path", "/users/new/edit"} {
+		p := p
+		_, err := router.AddGet(p, func(c *Context) bool {
+			c.Res.Write([]byte(p + " " + strings.Join(c.Param, ",")))
+			return true
+		})
+		testFatalError(t, err)
+	}
+	testGet := func(path, want string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Fatal(path, w.Body.String())
+		}
+	}
+	testGet("/users/new", "/users/new ")
+	testGet("/users/new/edit", "/users/new/edit ")
+	router.RouteGet("/users/:id").SetPriority(10)
+	router.RouteGet("/users/:id/*path").SetPriority(1)
+	testGet("/users/new", "/users/:id new")
+	testGet("/users/new/edit", "/users/:id/*path new,edit")
+	router.RouteGet("/users/new/edit").SetPriority(1)
+	testGet("/users/new/edit", "/users/new/edit ")
+	// Introspection.
+	var order []string
+	for _, route := range router.Candidates(http.MethodGet, "/users/new") {
+		order = append(order, route.Pattern())
+	}
+	if strings.Join(order, " ") != "/users/:id /users/new" {
+		t.Fatal(order)
+	}
+	order = order[:0]
+	for _, route := range router.Candidates(http.MethodGet, "/users/new/edit") {
+		order = append(order, route.Pattern())
+	}
+	if strings.Join(order, " ") != "/users/new/edit /users/:id/*path" {
+		t.Fatal(order)
+	}
+}
+
+func Test_Context_WildcardPath(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/files/:/*", func(c *Context) bool {
+		c.Res.Write([]byte(c.WildcardPath() + " " + c.DecodedWildcardPath()))
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/users/:", func(c *Context) bool {
+		c.Res.Write([]byte("[" + c.WildcardPath() + "]"))
+		return true
+	})
+	testFatalError(t, err)
+	for path, want := range map[string]string{
+		"/files/x/a/b.txt":     "a/b.txt a/b.txt",
+		"/files/x/a%2Fb/c%20d": "a%2Fb/c%20d a/b/c d",
+		"/users/1":             "[]",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Fatal(path, w.Body.String())
+		}
+	}
+}
+
+func Test_Router_Redirects(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	testFatalError(t, router.AddRedirects(map[string]string{
+		"/blog/:id":       "/posts/:id",
+		"/blog/:id/*rest": "https://example.com/p/:0/*rest",
+		"/old":            "/new?from=old",
+	}, http.StatusMovedPermanently))
+	if err := router.AddRedirects(map[string]string{"/a/:id": "/b/:name"}, http.StatusFound); err == nil {
+		t.FailNow()
+	}
+	file := filepath.Join(t.TempDir(), "redirects.txt")
+	testFatalError(t, os.WriteFile(file, []byte("# comment\n/docs/* /manual/*0 308\n"), 0600))
+	testFatalError(t, router.Redirects().Load(file, http.StatusFound))
+	testRedirect := func(method, path string, code int, location string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if w.Code != code || w.Header().Get("Location") != location {
+			t.Fatal(path, w.Code, w.Header())
+		}
+	}
+	testRedirect(http.MethodGet, "/blog/1?a=b", http.StatusMovedPermanently, "/posts/1?a=b")
+	testRedirect(http.MethodGet, "/blog/1/x/y", http.StatusMovedPermanently, "https://example.com/p/1/x/y")
+	testRedirect(http.MethodGet, "/old?a=b", http.StatusMovedPermanently, "/new?from=old")
+	testRedirect(http.MethodPost, "/docs/a/b", http.StatusPermanentRedirect, "/manual/a/b")
+	// Admin API.
+	_, err := router.AddGet("/admin/redirects", router.Redirects().AdminHandler)
+	testFatalError(t, err)
+	_, err = router.AddDelete("/admin/redirects", router.Redirects().AdminHandler)
+	testFatalError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/redirects?source=/blog/:id", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatal(w.Code)
+	}
+	testRedirect(http.MethodGet, "/blog/1", http.StatusNotFound, "")
+	testRedirect(http.MethodGet, "/blog/1/x", http.StatusMovedPermanently, "https://example.com/p/1/x")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/redirects", nil))
+	var rules []Redirect
+	testFatalError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	if len(rules) != 3 || rules[0].Source != "/blog/:id/*rest" || rules[1].Code != http.StatusPermanentRedirect {
+		t.Fatal(rules)
+	}
+	// Replace all.
+	testFatalError(t, router.Redirects().add([]*Redirect{{Source: "/x", Target: "/y", Code: http.StatusFound}}, true))
+	testRedirect(http.MethodGet, "/old", http.StatusNotFound, "")
+	testRedirect(http.MethodGet, "/x", http.StatusFound, "/y")
+	// Invalid rule keeps all rules.
+	if err := router.Redirects().add([]*Redirect{
+		{Source: "/z", Target: "/y", Code: http.StatusFound},
+		{Source: "/w", Target: "/y", Code: http.StatusOK},
+	}, false); err == nil {
+		t.FailNow()
+	}
+	if rules := router.Redirects().Rules(); len(rules) != 1 || rules[0].Source != "/x" {
+		t.Fatal(rules)
+	}
+	testRedirect(http.MethodGet, "/z", http.StatusNotFound, "")
+	// Param is escaped.
+	testFatalError(t, router.AddRedirects(map[string]string{
+		"/e/:id":   "/f/:id",
+		"/g/*rest": "/h/*rest",
+	}, http.StatusFound))
+	testRedirect(http.MethodGet, "/e/a%3Fb%23c", http.StatusFound, "/f/a%3Fb%23c")
+	testRedirect(http.MethodGet, "/g/a/b%20c", http.StatusFound, "/h/a/b%20c")
+}
+
+func Test_Router_AddVariants(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"logo.webp", "logo.png"} {
+		file := filepath.Join(dir, name)
+		testFatalError(t, os.WriteFile(file, []byte(name), 0600))
+		files = append(files, file)
+	}
+	var router Router
+	testFatalError(t, router.AddVariants(http.MethodGet, "/logo", true, files...))
+	for accept, want := range map[string]string{
+		"":                                 "logo.webp",
+		"image/png":                        "logo.png",
+		"image/avif,image/webp,*/*;q=0.8":  "logo.webp",
+		"image/png,image/*;q=0.5":          "logo.png",
+		"image/webp;q=0.1,image/png;q=0.9": "logo.png",
+		"text/html":                        "logo.webp",
+	} {
+		w := httptest.NewRecorder()
+		q := httptest.NewRequest(http.MethodGet, "/logo", nil)
+		q.Header.Set("Accept", accept)
+		router.ServeHTTP(w, q)
+		if w.Body.String() != want || w.Header().Get("Vary") != "Accept" {
+			t.Fatal(accept, w.Body.String(), w.Header())
+		}
+		if w.Header().Get("Content-Type") != mime.TypeByExtension(filepath.Ext(want)) {
+			t.Fatal(w.Header())
+		}
+	}
+}
+
+func Test_CacheHandler_ContentLength(t *testing.T) {
+	h := &CacheHandler{ContentType: "text/plain", Data: bytes.Repeat([]byte("abcdefgh"), 1024)}
+	if h.ContentLength("unknown") != -1 {
+		t.FailNow()
+	}
+	// Compressed lazily.
+	size := h.ContentLength("gzip")
+	if size <= 0 || size >= int64(len(h.Data)) || h.ContentLength("") != int64(len(h.Data)) {
+		t.Fatal(size)
+	}
+	var router Router
+	router.SetHeadFallback(true)
+	_, err := router.AddGet("/data", h.Handle)
+	testFatalError(t, err)
+	serve := func(method, rng string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		q := httptest.NewRequest(method, "/data", nil)
+		q.Header.Set("Accept-Encoding", "gzip")
+		if rng != "" {
+			q.Header.Set("Range", rng)
+		}
+		router.ServeHTTP(w, q)
+		return w
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := serve(method, "")
+		if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != strconv.FormatInt(size, 10) {
+			t.Fatal(method, w.Header())
+		}
+	}
+	w := serve(http.MethodGet, "bytes=0-9")
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Length") != "10" ||
+		w.Header().Get("Content-Range") != fmt.Sprintf("bytes 0-9/%d", size) {
+		t.Fatal(w.Code, w.Header())
+	}
+	// Bounds are checked by compressed length.
+	w = serve(http.MethodGet, fmt.Sprintf("bytes=%d-", size+1))
+	if w.Code != http.StatusRequestedRangeNotSatisfiable || w.Header().Get("Content-Encoding") != "" {
+		t.Fatal(w.Code, w.Header())
+	}
+}
+
+func Test_Router_Freeze(t *testing.T) {
+	var router Router
+	route, err := router.AddGet("/a", func(c *Context) bool {
+		c.Res.Write([]byte("a"))
+		return true
+	})
+	testFatalError(t, err)
+	// Route is still valid after it is split.
+	_, err = router.AddGet("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	if router.RouteGet("/a") != route {
+		t.FailNow()
+	}
+	// Add and serve at the same time.
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		for i := 0; i < 100; i++ {
+			router.AddGet(fmt.Sprintf("/b/%d", i), func(c *Context) bool { return true })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+		if w.Body.String() != "a" {
+			t.Fatal(w.Body.String())
+		}
+	}
+	wait.Wait()
+	router.Freeze()
+	if !router.Frozen() {
+		t.FailNow()
+	}
+	if _, err = router.AddGet("/c", func(c *Context) bool { return true }); err != ErrFrozen {
+		t.Fatal(err)
+	}
+	if router.RemoveGet("/a") {
+		t.FailNow()
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+	if w.Body.String() != "a" {
+		t.Fatal(w.Body.String())
+	}
+}
+
+// Implements Matcher, match exact paths only.
+type testExactMatcher map[string]*Route
+
+func (m testExactMatcher) Add(path string) (*Route, error) {
+	if route, ok := m[path]; ok {
+		return route, nil
+	}
+	route, err := NewRoute(path)
+	if err != nil {
+		return nil, err
+	}
+	m[path] = route
+	return route, nil
+}
+
+func (m testExactMatcher) Remove(path string) bool {
+	_, ok := m[path]
+	delete(m, path)
+	return ok
+}
+
+func (m testExactMatcher) Find(path string) *Route {
+	return m[path]
+}
+
+func (m testExactMatcher) Match(c *Context) *Route {
+	return m[c.Req.URL.Path]
+}
+
+func (m testExactMatcher) Walk(fn func(*Route)) {
+	for _, route := range m {
+		fn(route)
+	}
+}
+
+func Test_Router_SetMatcher(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	router.SetMatcher(func() Matcher { return make(testExactMatcher) })
+	route, err := router.AddGet("/users/list", func(c *Context) bool {
+		c.Res.Write([]byte("list"))
+		return true
+	})
+	testFatalError(t, err)
+	if route.Pattern() != "/users/list" || router.RouteGet("/users/list") != route {
+		t.Fatal(route.Pattern())
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/list", nil))
+	if w.Body.String() != "list" {
+		t.Fatal(w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/other", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatal(w.Code)
+	}
+	if len(router.OpenAPI().Paths) != 1 || !router.RemoveGet("/users/list") {
+		t.FailNow()
+	}
+	for path, pattern := range map[string]string{
+		"/users/:id/files/*file": "/users/:id/files/*file",
+		"/a/:/:":                 "/a/:/:",
+	} {
+		route, err := NewRoute(path)
+		testFatalError(t, err)
+		if route.Pattern() != pattern {
+			t.Fatal(route.Pattern())
+		}
+	}
+	// Default matcher.
+	router.SetMatcher(nil)
+	_, err = router.AddGet("/users/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	if router.RouteGet("/users/:") == nil {
+		t.FailNow()
+	}
+}
+
+func Test_Router_ExactMatch(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	for _, p := range []string{"/", "/users", "/users/new", "/files/list"} {
+		p := p
+		_, err := router.AddGet(p, func(c *Context) bool {
+			c.Res.Write([]byte(p))
+			return true
+		})
+		testFatalError(t, err)
+	}
+	root := router.root(http.MethodGet)
+	if root.dynamic || len(root.exact) != 4 {
+		t.Fatal(root.dynamic, len(root.exact))
+	}
+	testServe := func(path, want string, code int) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code || w.Body.String() != want {
+			t.Fatal(path, w.Code, w.Body.String())
+		}
+	}
+	testServe("/", "/", http.StatusOK)
+	testServe("/users/new", "/users/new", http.StatusOK)
+	// Prefix route has no handlers.
+	testServe("/files", "", http.StatusNotFound)
+	testServe("/users/old", "", http.StatusNotFound)
+	// Case folding uses the tree.
+	router.SetCaseFolding(true)
+	testServe("/USERS", "/users", http.StatusOK)
+	router.SetCaseFolding(false)
+	// Map is rebuilt after remove.
+	if !router.RemoveGet("/users/new") {
+		t.FailNow()
+	}
+	testServe("/users/new", "", http.StatusNotFound)
+	testServe("/users", "/users", http.StatusOK)
+	// Param route switches to the tree.
+	_, err := router.AddGet("/users/:id", func(c *Context) bool {
+		c.Res.Write([]byte(c.Param[0]))
+		return true
+	})
+	testFatalError(t, err)
+	if !root.dynamic || root.exact != nil {
+		t.FailNow()
+	}
+	testServe("/users/1", "1", http.StatusOK)
+	// Back to map after param route is removed.
+	if !router.RemoveGet("/users/:id") {
+		t.FailNow()
+	}
+	if root.dynamic || root.exact == nil {
+		t.FailNow()
+	}
+	testServe("/users/1", "", http.StatusNotFound)
+	testServe("/files/list", "/files/list", http.StatusOK)
+}
+
+func Test_Context_Alloc(t *testing.T) {
+	c := new(Context)
+	s1 := c.AllocString("/a", "/")
+	b := c.Alloc(arenaSize)
+	for i := range b {
+		b[i] = 'x'
+	}
+	s2 := c.AllocString("/b")
+	if s1 != "/a/" || s2 != "/b" || len(b) != arenaSize || cap(b) != arenaSize {
+		t.Fatal(s1, s2, len(b), cap(b))
+	}
+	// Reused after reset.
+	c.arena.reset()
+	n := testing.AllocsPerRun(100, func() {
+		c.arena.reset()
+		c.AllocString("/users/", "1")
+		c.Alloc(16)
+	})
+	if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+// Return a request path matches route path.
+func testRouteURL(route string) string {
+	part := strings.Split(path.Clean("/"+route), "/")
+	for i, s := range part {
+		if s == "" {
+			continue
+		}
+		switch s[0] {
+		case ':':
+			part[i] = "v"
+		case '*':
+			part[i] = "w/z"
+		}
+	}
+	return strings.Join(part, "/")
+}
+
+// Check invariants of tree: every added route is findable and matched,
+// removed routes are not matched.
+func testCheckTree(t *testing.T, root *rootRoute, added []string, removed []*Route) {
+	c := new(Context)
+	for _, p := range added {
+		route := root.Find(p)
+		if route == nil || len(route.Handler) < 1 {
+			t.Fatalf("route %q is not found", p)
+		}
+		c.Param = c.Param[:0]
+		url := testRouteURL(p)
+		if m := root.match(c, url, false, matchHandler); m == nil {
+			t.Fatalf("route %q is not matched by %q", p, url)
+		}
+	}
+	for _, r := range removed {
+		if route := root.Find(r.path); route != nil && len(route.Handler) > 0 {
+			t.Fatalf("removed route %q is found", r.path)
+		}
+		c.Param = c.Param[:0]
+		url := testRouteURL(r.path)
+		if m := root.match(c, url, false, matchHandler); m == r {
+			t.Fatalf("removed route %q is matched by %q", r.path, url)
+		}
+	}
+}
+
+func FuzzSplitRoute(f *testing.F) {
+	for _, s := range []string{"", "/", "/users/:/status", "/:id/*file", "a//b/../:x", "/:/:", "/*/a", "/:", "."} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		part, err := splitRoute(p)
+		if err != nil {
+			return
+		}
+		if len(part) < 1 || part[0] == "" || part[0][0] != '/' {
+			t.Fatalf("%q -> %q", p, part)
+		}
+		// Static routes are merged, param routes consume the following '/'.
+		static := false
+		for i, s := range part {
+			isStatic := s != ":" && s != "*"
+			if s == "" || (isStatic && static) || (i > 0 && isStatic && s[0] == '/') {
+				t.Fatalf("%q -> %q", p, part)
+			}
+			static = isStatic
+		}
+	})
+}
+
+func FuzzAddMatch(f *testing.F) {
+	for _, s := range [][2]string{
+		{"/users/:id", "/users/new"},
+		{"/files/*path", "/files/list/all"},
+		{"/", "/:page"},
+		{"/a/b", "/a"},
+		{"/:/:", "/a/:"},
+		{"", "/users/:id/posts"},
+	} {
+		f.Add(s[0], s[1])
+	}
+	f.Fuzz(func(t *testing.T, a, b string) {
+		root := new(rootRoute)
+		h := []HandlerFunc{func(c *Context) bool { return true }}
+		var added []string
+		for _, p := range []string{a, b} {
+			route, err := root.Add(p)
+			if err != nil {
+				continue
+			}
+			route.Handler = h
+			added = append(added, p)
+		}
+		testCheckTree(t, root, added, nil)
+		if len(added) < 1 {
+			return
+		}
+		removed := root.Find(added[0])
+		var remain []string
+		for _, p := range added[1:] {
+			// Sub routes are removed too.
+			route := root.Find(p)
+			if route != removed && removed.path != "/" && !strings.HasPrefix(route.path, removed.path+"/") {
+				remain = append(remain, p)
+			}
+		}
+		if !root.Remove(added[0]) {
+			t.Fatalf("route %q is not removed", added[0])
+		}
+		testCheckTree(t, root, remain, []*Route{removed})
+	})
+}
+
+func Test_Router_BadRequest(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/users", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	_, err = router.Add("", "/users")
+	if err == nil {
+		t.FailNow()
+	}
+	for _, m := range []string{"", "G", "GE", "GETX", "PURGE", "get"} {
+		if router.Route(m, "/users") != nil {
+			t.Fatal(m)
+		}
+	}
+	testServe := func(method, path string, code int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Method = method
+		req.URL.Path = path
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatal(method, path, w.Code)
+		}
+		return w
+	}
+	testServe(http.MethodGet, "/users", http.StatusOK)
+	testServe("", "/users", http.StatusBadRequest)
+	testServe(http.MethodGet, "", http.StatusBadRequest)
+	testServe(http.MethodGet, "users", http.StatusBadRequest)
+	// Unsupported method.
+	w := testServe("P", "/users", http.StatusMethodNotAllowed)
+	if w.Header().Get("Allow") != "GET" {
+		t.Fatal(w.Header())
+	}
+	testServe("PURGE", "/none", http.StatusMethodNotAllowed)
+	// Custom chain.
+	router.SetBadRequest(func(c *Context) bool {
+		c.Res.WriteHeader(http.StatusTeapot)
+		return true
+	})
+	testServe("", "/users", http.StatusTeapot)
+}
+
+func Test_Context_NotFoundReason(t *testing.T) {
+	var router Router
+	router.SetFallthrough(true)
+	h := func(c *Context) bool { return true }
+	for _, p := range []string{"/files/list", "/users/:id/posts"} {
+		_, err := router.AddGet(p, h)
+		testFatalError(t, err)
+	}
+	_, err := router.AddPost("/users", h)
+	testFatalError(t, err)
+	_, err = router.AddGet("/items/:id", func(c *Context) bool { return c.Fallthrough() })
+	testFatalError(t, err)
+	var reason NotFoundReason
+	router.SetNotfound(func(c *Context) bool {
+		reason = c.NotFoundReason()
+		c.Res.WriteHeader(http.StatusNotFound)
+		return true
+	})
+	for path, want := range map[string]NotFoundReason{
+		"/none":        NotFoundNoRoute,
+		"/files/none":  NotFoundNoRoute,
+		"/filesystem":  NotFoundNoRoute,
+		"/files":       NotFoundNoHandler,
+		"/files/":      NotFoundNoHandler,
+		"/users/1":     NotFoundNoHandler,
+		"/users":       NotFoundMethodMismatch,
+		"/items/1":     NotFoundConstraint,
+		"/users/1/xxx": NotFoundNoRoute,
+	} {
+		reason = NotFoundNone
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if reason != want {
+			t.Fatal(path, reason, want)
+		}
+	}
+	// Not in notfound chain.
+	router.SetAfter(func(c *Context) bool {
+		reason = c.NotFoundReason()
+		return true
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/list", nil))
+	if reason != NotFoundNone || NotFoundMethodMismatch.String() != "method_mismatch" {
+		t.Fatal(reason)
+	}
+}
+
+func Test_Router_ServerOptions(t *testing.T) {
+	var router Router
+	h := func(c *Context) bool { return true }
+	_, err := router.AddGet("/a", h)
+	testFatalError(t, err)
+	_, err = router.AddPost("/b", h)
+	testFatalError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "*", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, POST, OPTIONS" {
+		t.Fatal(w.Code, w.Header())
+	}
+	router.SetServerOptions(func(c *Context) bool {
+		c.Res.WriteHeader(http.StatusOK)
+		return true
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "*", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code)
+	}
+}
+
+func Test_ConnectHandler(t *testing.T) {
+	// Echo server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testFatalError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	var router Router
+	server := httptest.NewServer(&router)
+	defer server.Close()
+	connect := func(target string) (net.Conn, *bufio.Reader, *http.Response) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		testFatalError(t, err)
+		fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+		reader := bufio.NewReader(conn)
+		res, err := http.ReadResponse(reader, nil)
+		testFatalError(t, err)
+		return conn, reader, res
+	}
+	// Not enabled.
+	conn, _, res := connect(l.Addr().String())
+	conn.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(res.StatusCode)
+	}
+	router.SetConnect((&ConnectHandler{
+		Allow: func(c *Context, host string) bool {
+			return host == l.Addr().String()
+		},
+		Timeout: time.Second,
+	}).Handle)
+	conn, _, res = connect("127.0.0.1:1")
+	conn.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatal(res.StatusCode)
+	}
+	conn, reader, res := connect(l.Addr().String())
+	defer conn.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode)
+	}
+	_, err = conn.Write([]byte("ping"))
+	testFatalError(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(reader, b)
+	testFatalError(t, err)
+	if string(b) != "ping" {
+		t.Fatal(string(b))
+	}
+}
+
+func Test_Route_SetExpectContinue(t *testing.T) {
+	var router Router
+	route, err := router.AddPut("/upload", func(c *Context) bool {
+		b, _ := io.ReadAll(c.Req.Body)
+		c.Res.Write(b)
+		return true
+	})
+	testFatalError(t, err)
+	route.SetExpectContinue(func(c *Context) bool {
+		if c.Req.Header.Get("Authorization") == "" {
+			c.Res.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return c.Req.ContentLength <= 4
+	})
+	server := httptest.NewServer(&router)
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Minute}}
+	put := func(body, auth string) (int, string) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/upload", strings.NewReader(body))
+		testFatalError(t, err)
+		req.Header.Set("Expect", "100-continue")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		res, err := client.Do(req)
+		testFatalError(t, err)
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+	if code, body := put("ping", "token"); code != http.StatusOK || body != "ping" {
+		t.Fatal(code, body)
+	}
+	if code, _ := put("ping", ""); code != http.StatusUnauthorized {
+		t.Fatal(code)
+	}
+	if code, _ := put("too large", "token"); code != http.StatusExpectationFailed {
+		t.Fatal(code)
+	}
+}
+
+func Test_Context_SetTrailer(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/stream", func(c *Context) bool {
+		c.DeclareTrailer("x-count")
+		c.SetTrailer("X-Status", "ok")
+		w := c.NDJSON()
+		for i := 0; i < 3; i++ {
+			w.Send(i)
+		}
+		c.SetTrailer("X-Count", "3")
+		// Not declared.
+		c.SetTrailer("X-Extra", "1")
+		return true
+	})
+	testFatalError(t, err)
+	server := httptest.NewServer(&router)
+	defer server.Close()
+	res, err := http.Get(server.URL + "/stream")
+	testFatalError(t, err)
+	defer res.Body.Close()
+	if len(res.Trailer) != 2 {
+		t.Fatal(res.Header)
+	}
+	b, err := io.ReadAll(res.Body)
+	testFatalError(t, err)
+	if string(b) != "0\n1\n2\n" || res.Trailer.Get("X-Count") != "3" || res.Trailer.Get("X-Status") != "ok" ||
+		res.Trailer.Get("X-Extra") != "1" {
+		t.Fatal(string(b), res.Trailer)
+	}
+}
+
+func Test_Server_ProxyProtocol(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/ip", func(c *Context) bool {
+		c.Res.Write([]byte(c.ClientIP()))
+		return true
+	})
+	testFatalError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testFatalError(t, err)
+	server := &http.Server{Handler: &router}
+	go server.Serve(&proxyListener{Listener: l, timeout: time.Second})
+	defer server.Close()
+	get := func(header []byte) string {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		testFatalError(t, err)
+		defer conn.Close()
+		conn.Write(header)
+		conn.Write([]byte("GET /ip HTTP/1.1\r\nHost: a\r\n\r\n"))
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return "error"
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return string(b)
+	}
+	if ip := get([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\r\n")); ip != "192.0.2.1" {
+		t.Fatal(ip)
+	}
+	if ip := get([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 80\r\n")); ip != "2001:db8::1" {
+		t.Fatal(ip)
+	}
+	if ip := get([]byte("PROXY UNKNOWN\r\n")); ip != "127.0.0.1" {
+		t.Fatal(ip)
+	}
+	v2 := append([]byte(nil), proxySignature...)
+	v2 = append(v2, 0x21, 0x11, 0, 12, 198, 51, 100, 7, 192, 0, 2, 2, 0x04, 0xd2, 0, 80)
+	if ip := get(v2); ip != "198.51.100.7" {
+		t.Fatal(ip)
+	}
+	// LOCAL command.
+	v2 = append(append([]byte(nil), proxySignature...), 0x20, 0, 0, 0)
+	if ip := get(v2); ip != "127.0.0.1" {
+		t.Fatal(ip)
+	}
+	// No header.
+	if ip := get(nil); ip != "error" {
+		t.Fatal(ip)
+	}
+}
+
+type testConnSink struct {
+	mutex    sync.Mutex
+	rejected []string
+}
+
+func (s *testConnSink) Count(name string, n int64, tags ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name == MetricConnRejected {
+		s.rejected = append(s.rejected, tags...)
+	}
+}
+
+func (s *testConnSink) Timing(name string, d time.Duration, tags ...string) {}
+
+func (s *testConnSink) Gauge(name string, v float64, tags ...string) {}
+
+func Test_Server_ConnLimit(t *testing.T) {
+	var router Router
+	_, err := router.AddGet("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	sink := new(testConnSink)
+	server := &Server{MaxConns: 2, MaxConnsPerIP: 1, Metrics: sink}
+	server.Handler = &router
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testFatalError(t, err)
+	go server.Serve(server.limitListener(l))
+	defer server.Close()
+	if server.IdleTimeout != defaultLimitIdleTimeout {
+		t.Fatal(server.IdleTimeout)
+	}
+	get := func(conn net.Conn) error {
+		_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+		if err != nil {
+			return err
+		}
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		return nil
+	}
+	conn1, err := net.Dial("tcp", l.Addr().String())
+	testFatalError(t, err)
+	testFatalError(t, get(conn1))
+	// Same IP.
+	conn2, err := net.Dial("tcp", l.Addr().String())
+	testFatalError(t, err)
+	defer conn2.Close()
+	if get(conn2) == nil {
+		t.FailNow()
+	}
+	if server.Conns() != 1 {
+		t.Fatal(server.Conns())
+	}
+	// Released after close.
+	conn1.Close()
+	for i := 0; i < 100 && server.Conns() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn3, err := net.Dial("tcp", l.Addr().String())
+	testFatalError(t, err)
+	defer conn3.Close()
+	testFatalError(t, get(conn3))
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if len(sink.rejected) != 1 || sink.rejected[0] != "reason:ip" {
+		t.Fatal(sink.rejected)
+	}
+}
+
+func Test_Router_StrictRequest(t *testing.T) {
+	router := New(WithStrictRequest(&StrictRequest{MaxHeaders: 3}))
+	called := false
+	router.SetBefore(func(c *Context) bool {
+		called = true
+		return true
+	})
+	_, err := router.AddPost("/", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	testServe := func(code int, fn func(req *http.Request)) {
+		called = false
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a"))
+		fn(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != code || called != (code == http.StatusOK) {
+			t.Fatal(req.Header, w.Code, called)
+		}
+	}
+	testServe(http.StatusOK, func(req *http.Request) {
+		req.Header.Set("Content-Length", "1")
+		req.Header.Set("X-A", "a\tb")
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.Header.Set("Content-Length", "1")
+		req.Header.Set("Transfer-Encoding", "chunked")
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.TransferEncoding = []string{"gzip", "chunked"}
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.Header["Content-Length"] = []string{"1", "2"}
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.Header.Set("Content-Length", "-1")
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.Header["X-A"] = []string{"1", "2", "3", "4"}
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.Header.Set("X-A", "a\x00b")
+	})
+	testServe(http.StatusBadRequest, func(req *http.Request) {
+		req.URL.Path = "/a\nb"
+	})
+	// Disabled.
+	router.SetStrictRequest(nil)
+	testServe(http.StatusOK, func(req *http.Request) {
+		req.Header["X-A"] = []string{"1", "2", "3", "4"}
+	})
+}
+
+func Test_HTTPSRedirect(t *testing.T) {
+	trusted, err := TrustedProxies("10.0.0.0/8", "127.0.0.1")
+	testFatalError(t, err)
+	_, err = TrustedProxies("10.0.0.300")
+	if err == nil {
+		t.FailNow()
+	}
+	h := &HTTPSRedirect{Host: "example.com", TrustForwarded: trusted}
+	var router Router
+	router.SetBefore(h.Handle)
+	_, err = router.AddGet("/a", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	_, err = router.AddPost("/a", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	testServe := func(method, url, remote, proto string, code int, location string) {
+		req := httptest.NewRequest(method, url, nil)
+		req.RemoteAddr = remote + ":1234"
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != code || w.Header().Get("Location") != location {
+			t.Fatal(url, w.Code, w.Header())
+		}
+	}
+	testServe(http.MethodGet, "http://example.com/a?q=1", "1.1.1.1", "", http.StatusMovedPermanently, "https://example.com/a?q=1")
+	testServe(http.MethodPost, "http://www.example.com:8080/a", "1.1.1.1", "", http.StatusPermanentRedirect, "https://example.com/a")
+	testServe(http.MethodGet, "https://example.com/a", "1.1.1.1", "", http.StatusOK, "")
+	testServe(http.MethodGet, "https://www.example.com:8443/a", "1.1.1.1", "", http.StatusMovedPermanently, "https://example.com:8443/a")
+	// Forwarded by trusted proxy.
+	testServe(http.MethodGet, "http://example.com/a", "10.1.2.3", "https", http.StatusOK, "")
+	testServe(http.MethodGet, "http://example.com/a", "1.1.1.1", "https", http.StatusMovedPermanently, "https://example.com/a")
+	// Redirect server.
+	h.Port = "8443"
+	h.Code = http.StatusFound
+	server := NewRedirectServer(":0", h)
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://www.example.com/b", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com:8443/b" {
+		t.Fatal(w.Code, w.Header())
+	}
+}
+
+func Test_Router_WarmStatic(t *testing.T) {
+	data := []byte(strings.Repeat("console.log(1);", 100))
+	var router Router
+	testFatalError(t, router.AddStaticFS(http.MethodGet, "/static", fstest.MapFS{"app.js": {Data: data}}, true))
+	manifest, err := router.WarmStatic()
+	testFatalError(t, err)
+	if manifest["/static/app.js"] != Integrity(data) || !strings.HasPrefix(Integrity(data), "sha384-") {
+		t.Fatal(manifest)
+	}
+	renderer := NewRenderer(fstest.MapFS{
+		"page.html": {Data: []byte(`<script src="/static/app.js" integrity="{{integrity "/static/app.js"}}"></script>`)},
+	}, "")
+	renderer.Integrity = manifest
+	router.SetRenderer(renderer)
+	_, err = router.AddGet("/", func(c *Context) bool {
+		testFatalError(t, c.Render(http.StatusOK, "page.html", nil))
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	// html/template escapes '+' in attribute, browsers unescape it.
+	if strings.ReplaceAll(res.Body.String(), "&#43;", "+") != `<script src="/static/app.js" integrity="`+Integrity(data)+`"></script>` {
+		t.Fatal(res.Body.String())
+	}
+	// Concurrent requests read the warmed data only.
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			q := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+			q.Header.Set("Accept-Encoding", "deflate")
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, q)
+			if res.Header().Get("Content-Encoding") != "deflate" {
+				t.Error(res.Header())
+			}
+		}()
+	}
+	wait.Wait()
+}
+
+func Test_CacheHandler_Concurrent(t *testing.T) {
+	data := []byte(strings.Repeat("hello world ", 100))
+	h := &CacheHandler{Data: data, Digest: true}
+	var wait sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wait.Add(1)
+		go func(encoding string) {
+			defer wait.Done()
+			c := new(Context)
+			c.Req = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Req.Header.Set("Accept-Encoding", encoding)
+			res := httptest.NewRecorder()
+			c.Res = res
+			// Length is read concurrently with compression.
+			if h.ContentLength(encoding) < 1 {
+				t.Error(encoding)
+			}
+			h.Handle(c)
+			if res.Header().Get("Content-Encoding") != encoding || res.Header().Get("Digest") != Digest(res.Body.Bytes()) {
+				t.Error(res.Header())
+			}
+		}(compressName[i%len(compressName)])
+	}
+	wait.Wait()
+	for i, name := range compressName {
+		if h.ContentLength(name) != int64(len(h.compressedData[i])) {
+			t.Fatal(name)
+		}
+	}
+	r, err := gzip.NewReader(bytes.NewReader(h.compressedData[gzipCompress]))
+	testFatalError(t, err)
+	b, err := io.ReadAll(r)
+	testFatalError(t, err)
+	if !bytes.Equal(b, data) {
+		t.FailNow()
+	}
+}
+
+func Test_Context_CSPNonce(t *testing.T) {
+	for _, v := range [][2]string{
+		{"default-src 'self'; script-src 'self'", "default-src 'self'; script-src 'self' 'nonce-n'"},
+		{"default-src 'self';", "default-src 'self'; script-src 'self' 'nonce-n'"},
+		{"default-src 'none'", "default-src 'none'; script-src 'nonce-n'"},
+		{"img-src *", "img-src *"},
+	} {
+		if s := addCSPNonce(v[0], "n"); s != v[1] {
+			t.Fatal(v[0], s)
+		}
+	}
+	var router Router
+	headers := &SecurityHeaders{ContentSecurityPolicy: "script-src 'self'", NoSniff: true}
+	router.SetBefore(headers.Handle)
+	router.SetRenderer(NewRenderer(fstest.MapFS{
+		"page.html": {Data: []byte(`<script nonce="{{cspNonce}}"></script>`)},
+	}, ""))
+	var nonce string
+	_, err := router.AddGet("/", func(c *Context) bool {
+		testFatalError(t, c.Render(http.StatusOK, "page.html", nil))
+		nonce = c.CSPNonce()
+		return true
+	})
+	testFatalError(t, err)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == "" || res.Body.String() != `<script nonce="`+nonce+`"></script>` ||
+		res.Header().Get("Content-Security-Policy") != "script-src 'self' 'nonce-"+nonce+"'" ||
+		res.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal(res.Header(), res.Body.String())
+	}
+	// New nonce of each request.
+	last := nonce
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == last {
+		t.FailNow()
+	}
+}
+
+func Test_Router_ProtectStatic(t *testing.T) {
+	dir := t.TempDir()
+	testFatalError(t, os.MkdirAll(filepath.Join(dir, "private"), 0755))
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "private", "b.txt"), []byte("b"), 0644))
+	var router Router
+	router.SetCookieKey([]byte("key"))
+	router.ProtectStatic("/static/private", &StaticAuth{Users: map[string]string{"u": "p"}, Realm: "files", Cookie: "auth"})
+	testFatalError(t, router.AddStatic(http.MethodGet, "/static", dir, false))
+	_, err := router.AddGet("/login", func(c *Context) bool {
+		testFatalError(t, c.SetSecureCookie(&http.Cookie{Name: "auth", Value: "u"}))
+		return true
+	})
+	testFatalError(t, err)
+	get := func(path string, fn func(*http.Request)) *httptest.ResponseRecorder {
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		if fn != nil {
+			fn(q)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, q)
+		return res
+	}
+	if res := get("/static/a.txt", nil); res.Code != http.StatusOK || res.Body.String() != "a" {
+		t.Fatal(res.Code)
+	}
+	res := get("/static/private/b.txt", nil)
+	if res.Code != http.StatusUnauthorized || res.Header().Get("WWW-Authenticate") != `Basic realm="files"` {
+		t.Fatal(res.Code, res.Header())
+	}
+	if res := get("/static/private/b.txt", func(q *http.Request) { q.SetBasicAuth("u", "x") }); res.Code != http.StatusUnauthorized {
+		t.Fatal(res.Code)
+	}
+	if res := get("/static/private/b.txt", func(q *http.Request) { q.SetBasicAuth("u", "p") }); res.Body.String() != "b" {
+		t.Fatal(res.Code)
+	}
+	cookies := get("/login", nil).Result().Cookies()
+	res = get("/static/private/b.txt", func(q *http.Request) {
+		for _, cookie := range cookies {
+			q.AddCookie(cookie)
+		}
+	})
+	if res.Body.String() != "b" {
+		t.Fatal(res.Code)
+	}
+	// Protect routes added before, concurrently with serving.
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		get("/static/a.txt", nil)
+	}()
+	router.ProtectStatic("/static/a.txt", &StaticAuth{})
+	wait.Wait()
+	if res := get("/static/a.txt", nil); res.Code != http.StatusForbidden {
+		t.Fatal(res.Code)
+	}
+}
+
+func Test_Tenancy(t *testing.T) {
+	tenancy := &Tenancy{Domain: "example.com", Header: "X-Tenant-ID", Path: true,
+		Valid: func(tenant string) bool { return tenant != "bad" }}
+	store := NewMemorySessionStore(time.Minute)
+	defer store.Close()
+	sessions := NewSessionManager(store)
+	limiter := NewRateLimiter(1, time.Minute)
+	var router Router
+	router.SetBefore(tenancy.Handle, sessions.Handle)
+	router.SetAfter(sessions.Commit)
+	_, err := router.AddGet("/orders", limiter.Handle, func(c *Context) bool {
+		c.Session().Set("n", 1)
+		c.WriteHTML(http.StatusOK, c.Tenant()+c.Req.URL.Path)
+		return true
+	})
+	testFatalError(t, err)
+	get := func(host, path, header string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		q.Host = host
+		if header != "" {
+			q.Header.Set("X-Tenant-ID", header)
+		}
+		for _, cookie := range cookies {
+			q.AddCookie(cookie)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, q)
+		return res
+	}
+	res := get("acme.example.com:8080", "/orders", "", nil)
+	if res.Body.String() != "acme/orders" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	if res := get("example.com", "/orders", "beta", nil); res.Body.String() != "beta/orders" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	if res := get("example.com", "/gamma/orders", "", nil); res.Body.String() != "gamma/orders" {
+		t.Fatal(res.Code, res.Body.String())
+	}
+	if res := get("bad.example.com", "/orders", "", nil); res.Code != http.StatusNotFound {
+		t.Fatal(res.Code)
+	}
+	// Rate limit of each tenant.
+	if res := get("acme.example.com", "/orders", "", nil); res.Code != http.StatusTooManyRequests {
+		t.Fatal(res.Code)
+	}
+	// Session of acme is not loaded by delta.
+	cookies := res.Result().Cookies()
+	res = get("delta.example.com", "/orders", "", cookies)
+	if res.Code != http.StatusOK || len(res.Result().Cookies()) != 1 || res.Result().Cookies()[0].Value == cookies[0].Value {
+		t.Fatal(res.Code, res.Result().Cookies())
+	}
+	// Session of delta is loaded by delta.
+	cookies = res.Result().Cookies()
+	res = get("delta.example.com", "/orders", "", cookies)
+	if len(res.Result().Cookies()) != 0 {
+		t.Fatal(res.Result().Cookies())
+	}
+}
+
+func Test_Router_AddAliases(t *testing.T) {
+	var router Router
+	var product *Route
+	product, err := router.AddGet("/products/:id", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, c.Locale()+" "+c.Param[0]+" "+c.URLFor(product, "a b"))
+		return true
+	})
+	testFatalError(t, err)
+	testFatalError(t, router.AddAliases(http.MethodGet, product, map[string]string{
+		"en": "/en/products/:id",
+		"de": "/de/produkte/:id",
+	}))
+	if router.AddAliases(http.MethodGet, product, map[string]string{"fr": "/fr/produits"}) == nil ||
+		router.RouteGet("/fr/produits") != nil {
+		t.FailNow()
+	}
+	// Added aliases are removed if one fails.
+	if router.AddAliases(http.MethodGet, product, map[string]string{"es": "/es/productos/:id", "fr": "/fr/produits"}) == nil ||
+		router.RouteGet("/es/productos/:id") != nil {
+		t.FailNow()
+	}
+	if router.AddAliases(http.MethodGet, product, map[string]string{"es": "/es/productos/:id", "fr": "/en/products/:id"}) == nil ||
+		router.RouteGet("/es/productos/:id") != nil {
+		t.FailNow()
+	}
+	for path, body := range map[string]string{
+		"/products/1":    " 1 /products/a%20b",
+		"/en/products/1": "en 1 /en/products/a%20b",
+		"/de/produkte/1": "de 1 /de/produkte/a%20b",
+	} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		if res.Body.String() != body {
+			t.Fatal(path, res.Body.String())
+		}
+	}
+}
+
+func Test_Router_AddRoutes(t *testing.T) {
+	var router Router
+	h := func(c *Context) bool { return true }
+	_, err := router.AddGet("/users/:id", h)
+	testFatalError(t, err)
+	// All nodes, not only routes with handlers.
+	snapshot := func() string {
+		var s []string
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			router.root(method).route.walk(func(route *Route) {
+				s = append(s, fmt.Sprintf("%s %s %d", method, route.path, len(route.Handler)))
+			})
+		}
+		return strings.Join(s, ",")
+	}
+	before := snapshot()
+	for _, specs := range [][]RouteSpec{
+		// Conflict with existing route.
+		{{Method: http.MethodGet, Path: "/user"}, {Method: http.MethodGet, Path: "/users/:id", Handler: []HandlerFunc{h}}},
+		// Parent of existing route.
+		{{Method: http.MethodGet, Path: "/users", Handler: []HandlerFunc{h}}, {Method: http.MethodGet, Path: "/users/:id", Handler: []HandlerFunc{h}}},
+		// Duplicate in batch.
+		{{Method: http.MethodPost, Path: "/a", Handler: []HandlerFunc{h}}, {Method: http.MethodPost, Path: "/a", Handler: []HandlerFunc{h}}},
+		// Param conflict.
+		{{Method: http.MethodGet, Path: "/users/new", Handler: []HandlerFunc{h}}, {Method: http.MethodGet, Path: "/users/*", Handler: []HandlerFunc{h}}},
+		// Invalid method.
+		{{Method: http.MethodGet, Path: "/b", Handler: []HandlerFunc{h}}, {Method: "GO", Path: "/c"}},
+	} {
+		if router.AddRoutes(specs) == nil {
+			t.Fatal(specs)
+		}
+		if after := snapshot(); before != after {
+			t.Fatal(before, after)
+		}
+		if router.RouteGet("/users/:id").Params()[0] != "id" {
+			t.FailNow()
+		}
+	}
+	testFatalError(t, router.AddRoutes([]RouteSpec{
+		{Method: http.MethodGet, Path: "/users/new", Handler: []HandlerFunc{h}},
+		{Method: http.MethodPost, Path: "/users", Handler: []HandlerFunc{h}},
+	}))
+	if router.RouteGet("/users/new") == nil || router.RoutePost("/users") == nil {
+		t.FailNow()
+	}
+}
+
+type testController struct {
+	prefix string
+	Show   HandlerFunc `route:"GET /:id"`
+	Skip   HandlerFunc `route:"GET /skip"`
+}
+
+func (t *testController) Get(c *Context) bool {
+	c.WriteHTML(http.StatusOK, t.prefix+"list")
+	return true
+}
+
+func (t *testController) PostUserProfile(c *Context) bool {
+	c.WriteHTML(http.StatusOK, t.prefix+"profile")
+	return true
+}
+
+func (t *testController) GetHTTPStatus(c *Context) bool {
+	c.WriteHTML(http.StatusOK, t.prefix+"status")
+	return true
+}
+
+func (t *testController) Getter() string { return "" }
+
+func (t *testController) Header(c *Context) bool { return true }
+
+func Test_Router_AddController(t *testing.T) {
+	var router Router
+	ctrl := &testController{prefix: "users:"}
+	ctrl.Show = func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, ctrl.prefix+c.Param[0])
+		return true
+	}
+	testFatalError(t, router.AddController("/users", ctrl))
+	for _, v := range [][3]string{
+		{http.MethodGet, "/users", "users:list"},
+		{http.MethodPost, "/users/user-profile", "users:profile"},
+		{http.MethodGet, "/users/http-status", "users:status"},
+		{http.MethodGet, "/users/1", "users:1"},
+	} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(v[0], v[1], nil))
+		if res.Body.String() != v[2] {
+			t.Fatal(v, res.Code, res.Body.String())
+		}
+	}
+	if router.RouteGet("/users/skip") != nil || router.RouteHead("/users/er") != nil {
+		t.FailNow()
+	}
+	// Added again, all conflict.
+	if router.AddController("/users", ctrl) == nil || router.AddController("/", nil) == nil {
+		t.FailNow()
+	}
+}
+
+func Test_Router_SetPool(t *testing.T) {
+	var router Router
+	router.SetMetrics(new(testConnSink))
+	router.SetPool("export", NewLimiter(1, 0, 0))
+	started, done := make(chan struct{}), make(chan struct{})
+	route, err := router.AddGet("/export", func(c *Context) bool {
+		close(started)
+		<-done
+		return true
+	})
+	testFatalError(t, err)
+	route.SetPool("export")
+	_, err = router.AddGet("/api", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", nil))
+	<-started
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatal(res.Code)
+	}
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if res.Code != http.StatusOK {
+		t.Fatal(res.Code)
+	}
+	if stats := router.Pools(); len(stats) != 1 || stats[0] != (PoolStats{Name: "export", Running: 1, Rejected: 1}) {
+		t.Fatal(stats)
+	}
+	close(done)
+}
+
+func Test_Router_SetWarmup(t *testing.T) {
+	var router Router
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	_, err := router.AddGet("/readyz", router.ReadyzHandler)
+	testFatalError(t, err)
+	readyz := func() int {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return res.Code
+	}
+	if readyz() != http.StatusOK {
+		t.FailNow()
+	}
+	errWarmup := errors.New("warmup")
+	router.SetWarmup(func(ctx context.Context) error {
+		<-ctx.Done()
+		return errWarmup
+	}, time.Millisecond, WarmupFailStop)
+	if readyz() != http.StatusServiceUnavailable {
+		t.FailNow()
+	}
+	// Server does not listen.
+	if err := NewServer("127.0.0.1:0", &router).Run("", ""); err != errWarmup {
+		t.Fatal(err)
+	}
+	if readyz() != http.StatusServiceUnavailable {
+		t.FailNow()
+	}
+	router.SetWarmup(func(ctx context.Context) error { return errWarmup }, 0, WarmupFailServe)
+	if router.Warmup(context.Background()) != errWarmup || readyz() != http.StatusOK {
+		t.FailNow()
+	}
+}
+
+func Test_Router_DedupStatic(t *testing.T) {
+	data := []byte(strings.Repeat("bundle", 100))
+	var router Router
+	testFatalError(t, router.AddStaticFS(http.MethodGet, "/", fstest.MapFS{
+		"a/app.js": {Data: data},
+		"b/app.js": {Data: append([]byte{}, data...)},
+		"c.js":     {Data: []byte("c")},
+	}, true))
+	if len(router.staticCaches) != 3 || len(router.staticStore) != 2 {
+		t.FailNow()
+	}
+	a, b := router.staticCaches[0].handler, router.staticCaches[1].handler
+	// Compressed lazily.
+	if &a.Data[0] != &b.Data[0] || a.compressedData[gzipCompress] != nil || b.compressedData[gzipCompress] != nil {
+		t.FailNow()
+	}
+	gzipA, _ := a.compressed(gzipCompress)
+	gzipB, _ := b.compressed(gzipCompress)
+	if &gzipA[0] != &gzipB[0] {
+		t.FailNow()
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/b/app.js", nil))
+	etag := res.Header().Get("ETag")
+	if res.Body.String() != string(data) || etag == "" {
+		t.Fatal(res.Header())
+	}
+	q := httptest.NewRequest(http.MethodGet, "/a/app.js", nil)
+	q.Header.Set("If-None-Match", etag)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Code != http.StatusNotModified {
+		t.Fatal(res.Code)
+	}
+	q = httptest.NewRequest(http.MethodGet, "/a/app.js", nil)
+	q.Header.Set("Accept-Encoding", "gzip")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, q)
+	if res.Header().Get("ETag") != strings.TrimSuffix(etag, `"`)+`-gzip"` {
+		t.Fatal(res.Header())
+	}
+}
+
+func Test_ContentType(t *testing.T) {
+	RegisterContentType("glb", "model/gltf-binary")
+	if ContentTypeByExtension(".GLB") != "model/gltf-binary" || ContentTypeByExtension(".wasm") != "application/wasm" {
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "app.wasm"), []byte("\x00asm"), 0644))
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "page.unknown"), []byte("<html><body>"), 0644))
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "model.glb"), []byte("glTF"), 0644))
+	for _, cache := range []bool{true, false} {
+		var router Router
+		testFatalError(t, router.AddStatic(http.MethodGet, "/", dir, cache))
+		for path, contentType := range map[string]string{
+			"/app.wasm":     "application/wasm",
+			"/page.unknown": "text/html; charset=utf-8",
+			"/model.glb":    "model/gltf-binary",
+		} {
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+			if res.Header().Get("Content-Type") != contentType {
+				t.Fatal(cache, path, res.Header())
+			}
+		}
+	}
+}
+
+func Test_Router_Alias(t *testing.T) {
+	var router Router
+	router.SetFallthrough(true)
+	router.SetNotfound(Notfound)
+	route, err := router.AddGet("/users/:id", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, c.Param[0])
+		return true
+	})
+	testFatalError(t, err)
+	route.SetHeader("X-Route", "users")
+	_, err = router.Alias(http.MethodGet, "/members/:id", "/users/:id")
+	testFatalError(t, err)
+	// Alias of alias is an alias of the route.
+	alias, err := router.Alias(http.MethodGet, "/people/:id", "/members/:id")
+	testFatalError(t, err)
+	if alias.meta.alias != route {
+		t.FailNow()
+	}
+	if _, err = router.Alias(http.MethodGet, "/members", "/users/:id"); err == nil {
+		t.FailNow()
+	}
+	if _, err = router.Alias(http.MethodGet, "/users/:id", "/members/:id"); err == nil {
+		t.FailNow()
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res
+	}
+	if res := get("/members/1"); res.Body.String() != "1" || res.Header().Get("X-Route") != "users" {
+		t.Fatal(res.Code, res.Header())
+	}
+	// Remove alias.
+	if !router.RemoveGet("/members/:id") || get("/members/1").Code != http.StatusNotFound || get("/users/1").Code != http.StatusOK {
+		t.FailNow()
+	}
+	// Remove route.
+	if !router.RemoveGet("/users/:id") || get("/people/1").Code != http.StatusNotFound {
+		t.FailNow()
+	}
+	// Fallthrough does not loop between alias and route.
+	_, err = router.AddGet("/a", func(c *Context) bool {
+		c.Fallthrough()
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.Alias(http.MethodGet, "/b", "/a")
+	testFatalError(t, err)
+	if res := get("/b"); res.Code != http.StatusNotFound {
+		t.Fatal(res.Code)
+	}
+}
+
+func Test_Sampling(t *testing.T) {
+	var router Router
+	var small, large, all int
+	count := func(n *int) HandlerFunc {
+		return func(c *Context) bool {
+			*n++
+			return true
+		}
+	}
+	router.SetBefore(Sampling(0.5, count(&large)), Sampling(0.1, count(&small)), Sampling(0, count(&all)))
+	_, err := router.AddGet("/", func(c *Context) bool {
+		// Sampled by 0.1 implies sampled by 0.5.
+		if c.Sampled(0.1) && !c.Sampled(0.5) {
+			t.Error()
+		}
+		return true
+	})
+	testFatalError(t, err)
+	for i := 0; i < 1000; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if all != 0 || small < 50 || small > 150 || large < 400 || large > 600 || small > large {
+		t.Fatal(small, large, all)
+	}
+}
+
+func Test_Route_SetMaxResponseSize(t *testing.T) {
+	var router Router
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	var writeErr error
+	add := func(path string, policy ResponseSizePolicy) {
+		route, err := router.AddGet(path, func(c *Context) bool {
+			writeErr = nil
+			for i := 0; i < 3 && writeErr == nil; i++ {
+				_, writeErr = c.Res.Write([]byte("abcd"))
+			}
+			return true
+		})
+		testFatalError(t, err)
+		route.SetMaxResponseSize(6, policy)
+	}
+	add("/truncate", ResponseTruncate)
+	add("/error", ResponseError)
+	add("/log", ResponseLog)
+	for _, v := range []struct {
+		path string
+		code int
+		body string
+		err  error
+	}{
+		{"/truncate", http.StatusOK, "abcdab", ErrResponseTooLarge},
+		{"/error", http.StatusOK, "abcd", ErrResponseTooLarge},
+		{"/log", http.StatusOK, "abcdabcdabcd", nil},
+	} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, v.path, nil))
+		if res.Code != v.code || res.Body.String() != v.body || writeErr != v.err {
+			t.Fatal(v.path, res.Code, res.Body.String(), writeErr)
+		}
+	}
+	// Nothing written yet.
+	route, err := router.AddGet("/big", func(c *Context) bool {
+		_, writeErr = c.Res.Write(make([]byte, 10))
+		return true
+	})
+	testFatalError(t, err)
+	route.SetMaxResponseSize(6, ResponseError)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/big", nil))
+	if res.Code != http.StatusInternalServerError || res.Body.Len() != 0 || writeErr != ErrResponseTooLarge {
+		t.Fatal(res.Code)
+	}
+}
+
+func Test_Router_SetMinifier(t *testing.T) {
+	var mediaTypes []string
+	var router Router
+	router.SetMinifier(MinifierFunc(func(mediaType string, data []byte) ([]byte, error) {
+		mediaTypes = append(mediaTypes, mediaType)
+		return []byte(strings.Join(strings.Fields(string(data)), " ")), nil
+	}))
+	testFatalError(t, router.AddStaticFS(http.MethodGet, "/", fstest.MapFS{
+		"app.css": {Data: []byte("a {\n  color: red;\n}\n")},
+	}, true))
+	router.SetRenderer(NewRenderer(fstest.MapFS{"page.html": {Data: []byte("<p>\n  {{.}}\n</p>\n")}}, ""))
+	_, err := router.AddGet("/page", func(c *Context) bool {
+		testFatalError(t, c.Render(http.StatusOK, "page.html", "hi"))
+		return true
+	})
+	testFatalError(t, err)
+	for path, body := range map[string]string{"/app.css": "a { color: red; }", "/page": "<p> hi </p>"} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		if res.Body.String() != body {
+			t.Fatal(path, res.Body.String())
+		}
+	}
+	if strings.Join(mediaTypes, ",") != "text/css,text/html" {
+		t.Fatal(mediaTypes)
+	}
+}
+
+func Test_Router_SetCompression(t *testing.T) {
+	var router Router
+	router.SetCompression(new(Compression))
+	body := strings.Repeat("hello ", 100)
+	_, err := router.AddGet("/html", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, body)
+		return true
+	})
+	testFatalError(t, err)
+	route, err := router.AddGet("/proxy", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, body)
+		return true
+	})
+	testFatalError(t, err)
+	route.SetCompression(false)
+	_, err = router.AddGet("/events", func(c *Context) bool {
+		c.Res.Header().Set("Content-Type", "text/event-stream")
+		c.Res.Write([]byte(body))
+		return true
+	})
+	testFatalError(t, err)
+	get := func(path, encoding string) *httptest.ResponseRecorder {
+		q := httptest.NewRequest(http.MethodGet, path, nil)
+		q.Header.Set("Accept-Encoding", encoding)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, q)
+		return res
+	}
+	for i := 0; i < 2; i++ {
+		res := get("/html", "br, gzip")
+		if res.Header().Get("Content-Encoding") != "gzip" || res.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatal(res.Header())
+		}
+		r, err := gzip.NewReader(res.Body)
+		testFatalError(t, err)
+		b, err := io.ReadAll(r)
+		testFatalError(t, err)
+		if string(b) != body {
+			t.Fatal(string(b))
+		}
+	}
+	for _, v := range [][2]string{{"/html", "gzip;q=0"}, {"/html", ""}, {"/proxy", "gzip"}, {"/events", "gzip"}} {
+		if res := get(v[0], v[1]); res.Header().Get("Content-Encoding") != "" || res.Body.String() != body {
+			t.Fatal(v, res.Header())
+		}
+	}
+}
+
+func Test_Router_StreamAfter(t *testing.T) {
+	router := new(Router)
+	var end StreamEnd
+	var size int64
+	var duration time.Duration
+	var streaming bool
+	router.SetAfter(func(c *Context) bool {
+		end, size, duration, streaming = c.StreamEnd(), c.Size(), c.StreamDuration(), c.Streaming()
+		return true
+	})
+	_, err := router.AddGet("/events", func(c *Context) bool {
+		w := c.SSE()
+		for i := 0; ; i++ {
+			if i == 2 {
+				if c.Query("close") != "" {
+					return true
+				}
+				// Client goes away.
+				c.Req.Context().Value(testCancelKey{}).(context.CancelFunc)()
+			}
+			if w.Send("tick", "a\nb") != nil {
+				return true
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/plain", func(c *Context) bool {
+		c.WriteHTML(http.StatusOK, "ok")
+		return true
+	})
+	testFatalError(t, err)
+	serve := func(path string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx = context.WithValue(ctx, testCancelKey{}, cancel)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		return res
+	}
+	res := serve("/events")
+	want := "event: tick\ndata: a\ndata: b\n\n"
+	if res.Header().Get("Content-Type") != ContentTypeEventStream || res.Body.String() != want+want {
+		t.Fatal(res.Header(), res.Body.String())
+	}
+	if !streaming || end != StreamEndClient || size != int64(len(want)*2) || duration <= 0 {
+		t.Fatal(streaming, end, size, duration)
+	}
+	serve("/events?close=1")
+	if end != StreamEndServer || end.String() != "server" {
+		t.Fatal(end)
+	}
+	serve("/plain")
+	if streaming || end != StreamEndNone || duration != 0 {
+		t.Fatal(streaming, end, duration)
+	}
+}
+
+type testCancelKey struct{}
+
+func Test_Router_RequestBudget(t *testing.T) {
+	var router Router
+	router.SetBefore((&RequestBudget{Max: time.Minute, Default: 10 * time.Second}).Handle)
+	var budget time.Duration
+	var outbound string
+	var ctx context.Context
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Get(RequestTimeoutHeader)
+	}))
+	defer upstream.Close()
+	_, err := router.AddGet("/", Timeout(5*time.Second), func(c *Context) bool {
+		budget, _ = c.Budget()
+		ctx = c.Req.Context()
+		res, err := c.HTTPClient().Get(upstream.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		return true
+	})
+	testFatalError(t, err)
+	for _, v := range []struct {
+		header, value string
+		min, max      time.Duration
+	}{
+		{RequestTimeoutHeader, "200ms", 100 * time.Millisecond, 200 * time.Millisecond},
+		{RequestTimeoutHeader, "1.5", time.Second, 1500 * time.Millisecond},
+		{GRPCTimeoutHeader, "300m", 200 * time.Millisecond, 300 * time.Millisecond},
+		{GRPCTimeoutHeader, "2H", 4 * time.Second, 5 * time.Second},
+		{RequestTimeoutHeader, "bad", 4 * time.Second, 5 * time.Second},
+		{RequestTimeoutHeader, "-1s", 4 * time.Second, 5 * time.Second},
+	} {
+		q := httptest.NewRequest(http.MethodGet, "/", nil)
+		q.Header.Set(v.header, v.value)
+		router.ServeHTTP(httptest.NewRecorder(), q)
+		if budget <= v.min || budget > v.max {
+			t.Fatal(v, budget)
+		}
+		d, err := time.ParseDuration(outbound)
+		if err != nil || d <= v.min || d > v.max {
+			t.Fatal(v, outbound)
+		}
+		if ctx.Err() == nil {
+			t.Fatal("context is not canceled")
+		}
+	}
+	for _, s := range []string{"", "1", "10x", "123456789S", "0S"} {
+		if _, ok := parseGRPCTimeout(s); ok {
+			t.Fatal(s)
+		}
+	}
+}
+
+func Test_Router_ACMEChallenge(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	dir := t.TempDir()
+	testFatalError(t, os.WriteFile(filepath.Join(dir, "file-token"), []byte("file-token.key"), 0644))
+	tokens, err := router.AddACMEChallenge(dir)
+	testFatalError(t, err)
+	tokens.Set("mem_token", "mem_token.key")
+	get := func(token string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, ACMEChallengePrefix+token, nil))
+		return res
+	}
+	for token, keyAuth := range map[string]string{"file-token": "file-token.key", "mem_token": "mem_token.key"} {
+		if res := get(token); res.Code != http.StatusOK || res.Body.String() != keyAuth {
+			t.Fatal(token, res.Code, res.Body.String())
+		}
+	}
+	tokens.Delete("mem_token")
+	for _, token := range []string{"mem_token", "missing", "..%2Fsecret", "a.b"} {
+		if res := get(token); res.Code != http.StatusNotFound {
+			t.Fatal(token, res.Code)
+		}
+	}
+}
+
+func Test_Router_SetShards(t *testing.T) {
+	newRouter := func(shards int) *Router {
+		router := New(WithCaseFolding(true))
+		router.SetNotfound(Notfound)
+		router.SetShards(shards)
+		for _, path := range []string{"/", "/users", "/users/new", "/users/:id", "/Files/*", "/:id", "/:id/info", "/a/b/c"} {
+			route, err := router.AddGet(path, func(c *Context) bool {
+				c.Res.Write([]byte(c.route.Pattern() + " " + strings.Join(c.Param, ",")))
+				return true
+			})
+			testFatalError(t, err)
+			if path == "/:id/info" {
+				route.SetPriority(1)
+			}
+		}
+		return router
+	}
+	sharded, one := newRouter(4), newRouter(0)
+	for _, path := range []string{"/", "/users", "/users/new", "/users/1", "/files/a/b", "/x", "/users/info", "/x/info", "/a/b/c", "/a/b", "/a/b/c/d", "/USERS/NEW"} {
+		a, b := httptest.NewRecorder(), httptest.NewRecorder()
+		sharded.ServeHTTP(a, httptest.NewRequest(http.MethodGet, path, nil))
+		one.ServeHTTP(b, httptest.NewRequest(http.MethodGet, path, nil))
+		if a.Code != b.Code || a.Body.String() != b.Body.String() {
+			t.Fatal(path, a.Code, a.Body.String(), b.Code, b.Body.String())
+		}
+	}
+	if len(sharded.Candidates(http.MethodGet, "/users/new")) != 2 || sharded.Candidates(http.MethodGet, "/users/info")[0].Pattern() != "/:id/info" {
+		t.Fatal(sharded.Candidates(http.MethodGet, "/users/new"))
+	}
+	if sharded.RouteGet("/users/:id") == nil || !sharded.RemoveGet("/users") || sharded.RouteGet("/users/:id") != nil {
+		t.FailNow()
+	}
+	if !sharded.RemoveGet("/") || len(sharded.Candidates(http.MethodGet, "/a/b/c")) != 0 {
+		t.FailNow()
+	}
+}
+
+func Test_Context_ParamByName(t *testing.T) {
+	var router Router
+	var id, postID, missing string
+	var index int
+	var allocs float64
+	_, err := router.AddGet("/users/:id/posts/:postID", func(c *Context) bool {
+		id, postID, missing, index = c.ParamByName("id"), c.ParamByName("postID"), c.ParamByName("x"), c.ParamIndex("postID")
+		allocs = testing.AllocsPerRun(10, func() { c.ParamByName("postID") })
+		return true
+	})
+	testFatalError(t, err)
+	_, err = router.AddGet("/files/:", func(c *Context) bool {
+		id, index = c.ParamByName(""), c.ParamIndex("")
+		return true
+	})
+	testFatalError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1/posts/2", nil))
+	if id != "1" || postID != "2" || missing != "" || index != 1 || allocs != 0 {
+		t.Fatal(id, postID, missing, index, allocs)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a", nil))
+	if id != "" || index != -1 {
+		t.Fatal(id, index)
+	}
+}
+
+func Test_Router_AddTemporary(t *testing.T) {
+	var router Router
+	router.SetNotfound(Notfound)
+	removed := make(chan string, 4)
+	router.OnRouteRemoved(func(method, pattern string) {
+		removed <- method + " " + pattern
+	})
+	ok := func(c *Context) bool {
+		c.Res.Write([]byte("ok"))
+		return true
+	}
+	_, err := router.AddTemporary(http.MethodPost, "/hooks/:id", 30*time.Millisecond, ok)
+	testFatalError(t, err)
+	_, err = router.AddPost("/hooks/:id/status", ok)
+	testFatalError(t, err)
+	_, err = router.AddTemporary(http.MethodPost, "/tmp", time.Hour, ok)
+	testFatalError(t, err)
+	post := func(path string) int {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, nil))
+		return res.Code
+	}
+	if post("/tmp") != http.StatusOK {
+		t.FailNow()
+	}
+	select {
+	case s := <-removed:
+		if s != "POST /hooks/:id" {
+			t.Fatal(s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("route is not removed")
+	}
+	if post("/hooks/1") != http.StatusNotFound || post("/hooks/1/status") != http.StatusOK {
+		t.FailNow()
+	}
+	// Replaced by a permanent route.
+	_, err = router.AddPost("/tmp", ok)
+	testFatalError(t, err)
+	if !router.RemovePost("/hooks/:id/status") || <-removed != "POST /hooks/:id/status" {
+		t.FailNow()
+	}
+	if next, expired := router.expire(time.Now().Add(2 * time.Hour)); !next.IsZero() || len(expired) != 0 || post("/tmp") != http.StatusOK {
+		t.Fatal(next, expired)
+	}
+}
+
+func Test_Context_Next(t *testing.T) {
+	var router Router
+	var trace []string
+	mark := func(s string, ok bool) HandlerFunc {
+		return func(c *Context) bool {
+			trace = append(trace, s)
+			return ok
+		}
+	}
+	wrap := func(s string) HandlerFunc {
+		return func(c *Context) bool {
+			trace = append(trace, s+"<")
+			ok := c.Next()
+			trace = append(trace, fmt.Sprintf("%s>%v", s, ok))
+			return ok
+		}
+	}
+	router.SetBefore(wrap("before"), mark("b", true))
+	router.SetAfter(mark("after", true))
+	_, err := router.AddGet("/", wrap("m"), mark("h1", true), mark("h2", true))
+	testFatalError(t, err)
+	_, err = router.AddGet("/abort", wrap("m"), func(c *Context) bool {
+		trace = append(trace, "abort")
+		c.Abort()
+		return true
+	}, mark("h", true))
+	testFatalError(t, err)
+	_, err = router.AddGet("/stop", wrap("m"), mark("h1", false), mark("h2", true))
+	testFatalError(t, err)
+	for path, want := range map[string]string{
+		"/":      "before< b m< h1 h2 m>true before>true after",
+		"/abort": "before< b m< abort m>false before>true after",
+		"/stop":  "before< b m< h1 m>false before>true after",
+	} {
+		trace = trace[:0]
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if s := strings.Join(trace, " "); s != want {
+			t.Fatal(path, s)
+		}
+	}
+}
+
+type testQueueSink struct {
+	testConnSink
+	waits []string
+}
+
+func (s *testQueueSink) Timing(name string, d time.Duration, tags ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name == MetricQueueWait && d > 0 {
+		s.waits = append(s.waits, tags[0])
+	}
+}
+
+func Test_Router_QueueStats(t *testing.T) {
+	var router Router
+	router.SetStats(true)
+	sink := new(testQueueSink)
+	router.SetMetrics(sink)
+	router.SetPool("export", NewLimiter(1, 2, 0))
+	started, done := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	route, err := router.AddGet("/export", func(c *Context) bool {
+		once.Do(func() {
+			close(started)
+			<-done
+		})
+		return true
+	})
+	testFatalError(t, err)
+	route.SetPool("export")
+	var wg sync.WaitGroup
+	serve := func() {
+		defer wg.Done()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", nil))
+	}
+	wg.Add(2)
+	go serve()
+	<-started
+	go serve()
+	for router.Stats()[0].Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(2 * time.Millisecond)
+	close(done)
+	wg.Wait()
+	stats := router.Stats()[0]
+	if stats.Requests != 2 || stats.Queued != 1 || stats.Waiting != 0 || stats.WaitP50 < 2*time.Millisecond {
+		t.Fatal(stats)
+	}
+	if len(sink.waits) != 1 || sink.waits[0] != "route:/export" {
+		t.Fatal(sink.waits)
+	}
+}
+
+func Test_Context_Disconnected(t *testing.T) {
+	var router Router
+	var handled, after, closed bool
+	router.SetBefore(func(c *Context) bool {
+		c.Req.Context().Value(testCancelKey{}).(context.CancelFunc)()
+		select {
+		case <-c.Disconnected():
+			closed = true
+		default:
+		}
+		return true
+	})
+	router.SetAfter(func(c *Context) bool {
+		after = true
+		return true
+	})
+	_, err := router.AddGet("/", func(c *Context) bool {
+		handled = true
+		return true
+	})
+	testFatalError(t, err)
+	serve := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx = context.WithValue(ctx, testCancelKey{}, cancel)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}
+	serve()
+	if handled || !after || !closed {
+		t.Fatal(handled, after, closed)
+	}
+	router.SetAbortOnDisconnect(false)
+	serve()
+	if !handled {
+		t.FailNow()
+	}
+}
+
+func Test_Router_SlashRedirectOpenRedirect(t *testing.T) {
+	router := New(WithSlashPolicy(SlashRedirect))
+	_, err := router.AddGet("/:", func(c *Context) bool { return true })
+	testFatalError(t, err)
+	for path, location := range map[string]string{
+		"//evil.com/":  "/evil.com",
+		"/\\evil.com/": "/evil.com",
+	} {
+		q := httptest.NewRequest(http.MethodGet, "/", nil)
+		q.URL.Path = path
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, q)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Fatal(path, w.Code, w.Header())
+		}
+	}
+}
+
+func Test_Context_DisconnectTimeout(t *testing.T) {
+	var router Router
+	router.SetBefore(Timeout(10*time.Millisecond), func(c *Context) bool {
+		time.Sleep(30 * time.Millisecond)
+		return true
+	})
+	_, err := router.AddGet("/", func(c *Context) bool {
+		if c.Req.Context().Err() == context.DeadlineExceeded {
+			c.Res.WriteHeader(http.StatusGatewayTimeout)
+		}
+		return true
+	})
+	testFatalError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatal(w.Code)
+	}
+}
+
+func Test_Router_CompressionHijack(t *testing.T) {
+	var router Router
+	router.SetCompression(new(Compression))
+	hijack := func(c *Context) bool {
+		conn, rw, err := c.Res.(http.Hijacker).Hijack()
+		if err != nil {
+			c.WriteHTML(http.StatusInternalServerError, err.Error())
+			return true
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		return true
+	}
+	_, err := router.AddGet("/ws", hijack)
+	testFatalError(t, err)
+	_, err = router.AddGet("/raw", hijack)
+	testFatalError(t, err)
+	server := httptest.NewServer(&router)
+	defer server.Close()
+	for path, upgrade := range map[string]string{"/ws": "Upgrade: test\r\nConnection: Upgrade\r\n", "/raw": ""} {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		testFatalError(t, err)
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\n%s\r\n", path, upgrade)
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		conn.Close()
+		testFatalError(t, err)
+		if res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatal(path, res.StatusCode)
+		}
+	}
+	q := httptest.NewRequest(http.MethodConnect, "/", nil)
+	q.Header.Set("Accept-Encoding", "gzip")
+	if compressRequest(q) {
+		t.FailNow()
+	}
+}
+
+func Test_Context_BindParamsArena(t *testing.T) {
+	c := new(Context)
+	c.Param = append(c.Param, c.AllocString("1"))
+	var p struct {
+		ID string `uri:"0"`
+	}
+	testFatalError(t, c.BindParams(&p))
+	// Arena is reused by the next request.
+	c.arena.reset()
+	c.AllocString("2")
+	if p.ID != "1" {
+		t.Fatal(p.ID)
+	}
+}
+
+func Test_Router_LoggerRace(t *testing.T) {
+	var router Router
+	var wait sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			router.Logger().With("a", 1).Debug("test")
+		}()
+	}
+	wait.Wait()
+	if router.logger != nil {
+		t.FailNow()
+	}
+	// Request id of concurrent requests.
+	router.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	_, err := router.AddGet("/", func(c *Context) bool {
+		c.Logger().Info("test")
+		return true
+	})
+	testFatalError(t, err)
+	ids := make([]string, 8)
+	for i := range ids {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			for j := 0; j < 100; j++ {
+				res := httptest.NewRecorder()
+				router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+				ids[i] = res.Header().Get(RequestIDHeader)
+			}
+		}(i)
+	}
+	wait.Wait()
+	if len(ids[0]) != 16 || ids[0] == ids[1] {
+		t.Fatal(ids)
+	}
+}
+
+func testNewExactRoot(b *testing.B) (*rootRoute, []string) {
+	root := new(rootRoute)
+	var paths []string
+	for i := 0; i < 100; i++ {
+		p := fmt.Sprintf("/static%d/static%d/static%d", i%10, i/10, i)
+		route, err := root.Add(p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		route.Handler = []HandlerFunc{func(c *Context) bool { return true }}
+		paths = append(paths, p)
+	}
+	return root, paths
+}
+
+func Benchmark_Match_Exact_Map(b *testing.B) {
+	root, paths := testNewExactRoot(b)
+	c := new(Context)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.match(c, paths[i%len(paths)], false, matchHandler)
+	}
+}
+
+func Benchmark_Match_Exact_Trie(b *testing.B) {
+	root, paths := testNewExactRoot(b)
+	c := new(Context)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.route.match(c, paths[i%len(paths)], false, matchHandler)
+	}
+}
@@ -0,0 +1,32 @@
+package router
+
+import (
+	"math/rand"
+)
+
+// Return a HandlerFunc runs funcs for rate of requests in range [0,1], such as Dump or tracing with full payloads.
+// Skipped funcs are treated as passed. See Context.Sampled for the decision.
+func Sampling(rate float64, funcs ...HandlerFunc) HandlerFunc {
+	return func(c *Context) bool {
+		if !c.Sampled(rate) {
+			return true
+		}
+		for _, h := range funcs {
+			if !h(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Return true if request is sampled by rate in range [0,1].
+// A random value is drawn once per request and compared with rate, so decisions across the chain are consistent,
+// a request sampled by rate 0.1 is sampled by rate 0.5 too.
+func (c *Context) Sampled(rate float64) bool {
+	if !c.sampleDrawn {
+		c.sampleValue = rand.Float64()
+		c.sampleDrawn = true
+	}
+	return c.sampleValue < rate
+}
@@ -0,0 +1,182 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Run a Router as http server.
+// Each connection has a ConnData, read it by Context.ConnData().
+type Server struct {
+	http.Server
+	// If it is nil, use router's Logger.
+	Logger Logger
+	// Called when a new connection is accepted, after ConnData is attached to ctx.
+	// Use it to keep per-connection state by ConnData.
+	ConnContextHook func(ctx context.Context, conn net.Conn) context.Context
+	// Parse PROXY protocol v1/v2 header of connections, used behind HAProxy or NLB in TCP mode.
+	// RemoteAddr of requests and Context.ClientIP are the client address in header.
+	ProxyProtocol bool
+	// Return true if header of connection from addr should be parsed, nil trusts all.
+	// Connections not trusted are served as normal.
+	ProxyProtocolTrusted func(addr net.Addr) bool
+	// Max open connections and max open connections of a client IP, 0 is no limit.
+	// Connections over limits are closed, and counted by MetricConnRejected.
+	// If limits are enabled and IdleTimeout is 0, IdleTimeout is 1 minute.
+	MaxConns      int
+	MaxConnsPerIP int
+	// Run warmup of router in background after the listener accepts, instead of before,
+	// router is not ready until it is done, see Router.ReadyzHandler.
+	WarmupInBackground bool
+	// Metrics of connections, if it is nil, use router's MetricsSink.
+	Metrics     MetricsSink
+	connLimiter connLimiter
+	prepare     sync.Once
+	onShutdown  []func(ctx context.Context)
+}
+
+// Return a Server listen on addr and serve router.
+func NewServer(addr string, router *Router) *Server {
+	s := new(Server)
+	s.Addr = addr
+	s.Handler = router
+	return s
+}
+
+func (s *Server) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	if r, ok := s.Handler.(*Router); ok {
+		return r.Logger()
+	}
+	return NewSlogLogger(nil)
+}
+
+// Listen and serve until Shutdown is called.
+// If certFile and keyFile are not empty, serve TLS.
+// Warmup of router is called before listening, unless WarmupInBackground is true.
+// It returns nil if server is shutdown.
+func (s *Server) Run(certFile, keyFile string) error {
+	logger := s.logger()
+	s.prepare.Do(func() {
+		if s.ErrorLog == nil {
+			s.ErrorLog = log.New(logWriter{logger: logger}, "", 0)
+		}
+		hook := s.ConnContextHook
+		if s.ConnContext != nil {
+			// Keep the hook set to http.Server.
+			userHook := s.ConnContext
+			hook = func(ctx context.Context, conn net.Conn) context.Context {
+				ctx = userHook(ctx, conn)
+				if s.ConnContextHook != nil {
+					ctx = s.ConnContextHook(ctx, conn)
+				}
+				return ctx
+			}
+		}
+		s.ConnContext = connContext(hook)
+	})
+	if r, ok := s.Handler.(*Router); ok {
+		if s.WarmupInBackground {
+			go r.Warmup(context.Background())
+		} else if err := r.Warmup(context.Background()); err != nil {
+			return err
+		}
+	}
+	var err error
+	tls := certFile != "" && keyFile != ""
+	logger.Info("server start", "addr", s.Addr, "tls", tls, "proxyProtocol", s.ProxyProtocol)
+	switch {
+	case s.ProxyProtocol || s.MaxConns > 0 || s.MaxConnsPerIP > 0:
+		err = s.serveListener(certFile, keyFile)
+	case tls:
+		err = s.ListenAndServeTLS(certFile, keyFile)
+	default:
+		err = s.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		logger.Info("server stop", "addr", s.Addr)
+		return nil
+	}
+	logger.Error("server stop", "addr", s.Addr, "error", err)
+	return err
+}
+
+// Listen and serve connections with PROXY protocol header or connection limits.
+func (s *Server) serveListener(certFile, keyFile string) error {
+	tls := certFile != "" && keyFile != ""
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+		if tls {
+			addr = ":https"
+		}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.ProxyProtocol {
+		timeout := s.ReadHeaderTimeout
+		if timeout <= 0 {
+			timeout = proxyHeaderTimeout
+		}
+		l = &proxyListener{Listener: l, trusted: s.ProxyProtocolTrusted, timeout: timeout}
+	}
+	if s.MaxConns > 0 || s.MaxConnsPerIP > 0 {
+		l = s.limitListener(l)
+	}
+	if tls {
+		return s.ServeTLS(l, certFile, keyFile)
+	}
+	return s.Serve(l)
+}
+
+// Wrap l by connection limits.
+func (s *Server) limitListener(l net.Listener) net.Listener {
+	if s.IdleTimeout == 0 {
+		s.IdleTimeout = defaultLimitIdleTimeout
+	}
+	metrics := s.Metrics
+	if r, ok := s.Handler.(*Router); ok && metrics == nil {
+		metrics = r.metrics
+	}
+	s.connLimiter.maxConns = s.MaxConns
+	s.connLimiter.maxPerIP = s.MaxConnsPerIP
+	s.connLimiter.metrics = metrics
+	return &limitListener{Listener: l, limiter: &s.connLimiter}
+}
+
+// Return count of open connections, it is counted only if connection limits are enabled.
+func (s *Server) Conns() int {
+	return s.connLimiter.count()
+}
+
+// Add a hook called by Shutdown before the listener closes, such as flushing queues.
+// Hooks are called in order, with the ctx of Shutdown.
+func (s *Server) OnShutdown(fn func(ctx context.Context)) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Mark router draining, call OnShutdown hooks, then shutdown gracefully.
+// In-flight requests can be watched by Router.InFlight and Router.Stats during draining.
+func (s *Server) Shutdown(ctx context.Context) error {
+	r, _ := s.Handler.(*Router)
+	if r != nil {
+		atomic.StoreInt32(&r.draining, 1)
+	}
+	for _, fn := range s.onShutdown {
+		fn(ctx)
+	}
+	err := s.Server.Shutdown(ctx)
+	if r != nil {
+		s.logger().Info("server drained", "addr", s.Addr, "inFlight", r.InFlight())
+	}
+	return err
+}
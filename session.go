@@ -0,0 +1,369 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var errInvalidSessionID = errors.New("session: invalid id")
+
+// Session data of a client.
+// Values are encoded in JSON by FileSessionStore, so numbers become float64 after loading.
+type Session struct {
+	ID      string                 `json:"id"`
+	Values  map[string]interface{} `json:"values"`
+	Expires time.Time              `json:"expires"`
+	// Tenant of request when the session is created, it is not loaded by other tenants.
+	Tenant string `json:"tenant,omitempty"`
+	// Values changed, need to save.
+	changed bool
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	if s.Values == nil {
+		s.Values = make(map[string]interface{})
+	}
+	s.Values[key] = value
+	s.changed = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.changed = true
+}
+
+// Storage of sessions.
+// A Redis implementation may use: Load -> GET <id>, Save -> SET <id> <json> PXAT <expires>, Delete -> DEL <id>.
+type SessionStore interface {
+	// Return the session, nil if not found or expired.
+	Load(ctx context.Context, id string) (*Session, error)
+	// Save the session until s.Expires.
+	Save(ctx context.Context, s *Session) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Return a random session id.
+func newSessionID() string {
+	var b [32]byte
+	rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// Check id only contains base64 url characters, so it can be used as file name.
+func validSessionID(id string) bool {
+	if len(id) < 1 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// In-memory SessionStore, expired sessions are removed by a janitor goroutine.
+type MemorySessionStore struct {
+	mutex    sync.RWMutex
+	sessions map[string]*Session
+	quit     chan struct{}
+}
+
+// Default interval of MemorySessionStore janitor.
+const defaultSessionGCInterval = time.Minute
+
+// Return a MemorySessionStore, janitor runs every gcInterval, 0 or negative uses one minute.
+func NewMemorySessionStore(gcInterval time.Duration) *MemorySessionStore {
+	if gcInterval <= 0 {
+		gcInterval = defaultSessionGCInterval
+	}
+	s := new(MemorySessionStore)
+	s.sessions = make(map[string]*Session)
+	s.quit = make(chan struct{})
+	go s.janitor(gcInterval)
+	return s
+}
+
+func (s *MemorySessionStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			for id, session := range s.sessions {
+				if now.After(session.Expires) {
+					delete(s.sessions, id)
+				}
+			}
+			s.mutex.Unlock()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Stop the janitor.
+func (s *MemorySessionStore) Close() {
+	close(s.quit)
+}
+
+func (s *MemorySessionStore) Load(ctx context.Context, id string) (*Session, error) {
+	s.mutex.RLock()
+	session := s.sessions[id]
+	s.mutex.RUnlock()
+	if session == nil || time.Now().After(session.Expires) {
+		return nil, nil
+	}
+	// Copy, so changes are not visible until saved.
+	ss := &Session{ID: session.ID, Expires: session.Expires, Tenant: session.Tenant, Values: make(map[string]interface{})}
+	for k, v := range session.Values {
+		ss.Values[k] = v
+	}
+	return ss, nil
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, session *Session) error {
+	ss := &Session{ID: session.ID, Expires: session.Expires, Tenant: session.Tenant, Values: make(map[string]interface{})}
+	for k, v := range session.Values {
+		ss.Values[k] = v
+	}
+	s.mutex.Lock()
+	s.sessions[session.ID] = ss
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	delete(s.sessions, id)
+	s.mutex.Unlock()
+	return nil
+}
+
+// SessionStore saves each session in a JSON file under Dir.
+type FileSessionStore struct {
+	Dir string
+}
+
+func (s *FileSessionStore) file(id string) (string, error) {
+	if !validSessionID(id) {
+		return "", errInvalidSessionID
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+func (s *FileSessionStore) Load(ctx context.Context, id string) (*Session, error) {
+	file, err := s.file(id)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	session := new(Session)
+	err = json.Unmarshal(data, session)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.Expires) {
+		os.Remove(file)
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (s *FileSessionStore) Save(ctx context.Context, session *Session) error {
+	file, err := s.file(session.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	// Write to a unique temp file then rename, so readers never see partial data,
+	// and concurrent saves of the same session do not write the same temp file.
+	tmp, err := os.CreateTemp(s.Dir, session.ID+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *FileSessionStore) Delete(ctx context.Context, id string) error {
+	file, err := s.file(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Remove expired session files.
+func (s *FileSessionStore) GC() error {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var session Session
+		if json.Unmarshal(data, &session) != nil || now.After(session.Expires) {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
+
+// Load session from cookie before handlers, and save it after handlers.
+// Session id from client that does not exist in store is never adopted, a new id is generated instead,
+// and Regenerate should be called after login to prevent session fixation.
+type SessionManager struct {
+	Store SessionStore
+	// Cookie attributes.
+	CookieName string
+	Path       string
+	Domain     string
+	Secure     bool
+	SameSite   http.SameSite
+	// Session lifetime.
+	MaxAge time.Duration
+}
+
+// Return a SessionManager with default settings.
+func NewSessionManager(store SessionStore) *SessionManager {
+	m := new(SessionManager)
+	m.Store = store
+	m.CookieName = "session_id"
+	m.Path = "/"
+	m.SameSite = http.SameSiteLaxMode
+	m.MaxAge = 24 * time.Hour
+	return m
+}
+
+func (m *SessionManager) setCookie(c *Context, s *Session) {
+	http.SetCookie(c.Res, &http.Cookie{
+		Name:     m.CookieName,
+		Value:    s.ID,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		Expires:  s.Expires,
+		Secure:   m.Secure,
+		HttpOnly: true,
+		SameSite: m.SameSite,
+	})
+}
+
+func (m *SessionManager) newSession(c *Context) *Session {
+	s := &Session{ID: newSessionID(), Expires: time.Now().Add(m.MaxAge), Tenant: c.tenant, changed: true}
+	m.setCookie(c, s)
+	return s
+}
+
+// Load session into context, create a new one if not found. Can be use as HandlerFunc.
+func (m *SessionManager) Handle(c *Context) bool {
+	c.session = nil
+	c.sessionManager = m
+	cookie, err := c.Req.Cookie(m.CookieName)
+	if err == nil {
+		c.session, err = m.Store.Load(c.Req.Context(), cookie.Value)
+		if err != nil {
+			c.Logger().Error("session load", "error", err)
+			c.Res.WriteHeader(http.StatusInternalServerError)
+			return false
+		}
+		// Session of other tenant.
+		if c.session != nil && c.session.Tenant != c.tenant {
+			c.session = nil
+		}
+	}
+	if c.session == nil {
+		c.session = m.newSession(c)
+	}
+	return true
+}
+
+// Save session if it is changed. Use it in after chain.
+func (m *SessionManager) Commit(c *Context) bool {
+	if c.session == nil || c.sessionManager != m || !c.session.changed {
+		return true
+	}
+	err := m.Store.Save(c.Req.Context(), c.session)
+	if err != nil {
+		c.Logger().Error("session save", "error", err)
+		return true
+	}
+	c.session.changed = false
+	return true
+}
+
+// Move session values to a new id and delete the old one, call it when privilege changes such as login.
+// It must be called before response body is written.
+func (m *SessionManager) Regenerate(c *Context) error {
+	old := c.session
+	if old == nil {
+		return nil
+	}
+	err := m.Store.Delete(c.Req.Context(), old.ID)
+	if err != nil {
+		return err
+	}
+	s := m.newSession(c)
+	s.Values = old.Values
+	c.session = s
+	return nil
+}
+
+// Delete session from store and client.
+func (m *SessionManager) Destroy(c *Context) error {
+	if c.session == nil {
+		return nil
+	}
+	err := m.Store.Delete(c.Req.Context(), c.session.ID)
+	if err != nil {
+		return err
+	}
+	c.session = nil
+	http.SetCookie(c.Res, &http.Cookie{Name: m.CookieName, Path: m.Path, Domain: m.Domain, MaxAge: -1})
+	return nil
+}
+
+// Return session loaded by SessionManager.Handle, nil if no session.
+func (c *Context) Session() *Session {
+	return c.session
+}
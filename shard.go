@@ -0,0 +1,159 @@
+package router
+
+import (
+	"path"
+	"strings"
+)
+
+// Route table of a method split into trees by the first path segment.
+// Routes whose first segment is a param route, and "/", are in the wild tree,
+// which is tried after the shard tree, same as a static route is tried before a param route.
+type shardedRoute struct {
+	shards []rootRoute
+	wild   rootRoute
+}
+
+// Split route tables of all methods into n trees by hash of the first path segment, n < 2 to use one tree.
+// It is used by gateways with huge route tables, Add and Remove rebuild only one tree, so they are faster,
+// but they still hold the router lock, which blocks matching of all trees.
+// Match, slash policy, case folding, priority and Fallthrough work same as one tree.
+// Routes added before are dropped, so call it before adding routes.
+func (r *Router) SetShards(n int) {
+	if n < 2 {
+		r.SetMatcher(nil)
+		return
+	}
+	r.SetMatcher(func() Matcher {
+		return &shardedRoute{shards: make([]rootRoute, n)}
+	})
+}
+
+// Return the first segment of path, false if it is empty or a param route.
+func firstSegment(_path string) (string, bool) {
+	_path = strings.TrimPrefix(_path, "/")
+	if i := strings.IndexByte(_path, '/'); i >= 0 {
+		_path = _path[:i]
+	}
+	if _path == "" || _path[0] == ':' || _path[0] == '*' {
+		return "", false
+	}
+	return _path, true
+}
+
+// Return the shard tree of path, nil if path has no static first segment.
+// Segment is hashed by FNV-1a ignoring ASCII case, so case folding matches the same tree.
+func (s *shardedRoute) shard(path string) *rootRoute {
+	seg, ok := firstSegment(path)
+	if !ok {
+		return nil
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(seg); i++ {
+		b := seg[i]
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	return &s.shards[h%uint32(len(s.shards))]
+}
+
+// Return the tree which route of path is added to.
+func (s *shardedRoute) tree(_path string) *rootRoute {
+	// Route path is cleaned, same as splitRoute.
+	if root := s.shard(path.Clean("/" + _path)); root != nil {
+		return root
+	}
+	return &s.wild
+}
+
+func (s *shardedRoute) Add(_path string) (*Route, error) {
+	return s.tree(_path).Add(_path)
+}
+
+// Remove "/" removes routes of all trees.
+func (s *shardedRoute) Remove(_path string) bool {
+	if path.Clean("/"+_path) != "/" {
+		return s.tree(_path).Remove(_path)
+	}
+	ok := s.wild.Remove(_path)
+	for i := range s.shards {
+		if s.shards[i].Remove(_path) {
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (s *shardedRoute) Find(_path string) *Route {
+	return s.tree(_path).Find(_path)
+}
+
+func (s *shardedRoute) Match(c *Context) *Route {
+	return s.match(c, c.Req.URL.Path, false, matchAny)
+}
+
+func (s *shardedRoute) match(c *Context, path string, fold bool, mode int) *Route {
+	if root := s.shard(path); root != nil {
+		n := len(c.Param)
+		if route := root.match(c, path, fold, mode); route != nil {
+			return s.matchPriority(c, path, fold, mode, route, n)
+		}
+		c.Param = c.Param[:n]
+	}
+	return s.wild.match(c, path, fold, mode)
+}
+
+// Return the param route of wild tree if it has higher priority than route matched by shard tree,
+// otherwise return route, same as Route.matchPriority.
+func (s *shardedRoute) matchPriority(c *Context, path string, fold bool, mode int, route *Route, n int) *Route {
+	slash := s.wild.route.static['/']
+	if slash == nil || slash.param == nil || (!slash.param.prioritized && route.meta.priority >= 0) {
+		return route
+	}
+	param := append([]string(nil), c.Param[n:]...)
+	c.Param = c.Param[:n]
+	if sub := s.wild.match(c, path, fold, mode); sub != nil && sub.meta.priority > route.meta.priority {
+		return sub
+	}
+	c.Param = append(c.Param[:n], param...)
+	return route
+}
+
+func (s *shardedRoute) Walk(fn func(*Route)) {
+	for i := range s.shards {
+		s.shards[i].Walk(fn)
+	}
+	s.wild.Walk(fn)
+}
+
+// Return trees may match path, the shard one first.
+func (s *shardedRoute) trees(path string) []*rootRoute {
+	if root := s.shard(path); root != nil {
+		return []*rootRoute{root, &s.wild}
+	}
+	return []*rootRoute{&s.wild}
+}
+
+// Return trees of table may match path, nil if table is not a tree.
+func routeTrees(table Matcher, path string) []*rootRoute {
+	switch t := table.(type) {
+	case *rootRoute:
+		return []*rootRoute{t}
+	case *shardedRoute:
+		return t.trees(path)
+	}
+	return nil
+}
+
+// Return the tree of table which route of path is added to, nil if table is not a tree.
+func routeTree(table Matcher, _path string) *rootRoute {
+	switch t := table.(type) {
+	case *rootRoute:
+		return t
+	case *shardedRoute:
+		return t.tree(_path)
+	}
+	return nil
+}
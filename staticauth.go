@@ -0,0 +1,88 @@
+package router
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Require basic auth or a secure cookie for static routes, see Router.ProtectStatic.
+type StaticAuth struct {
+	// Basic auth users, username -> password.
+	Users map[string]string
+	// Realm of WWW-Authenticate header.
+	Realm string
+	// Name of cookie set by Context.SetSecureCookie, request passes if it is valid.
+	Cookie string
+	// Called if request is not authorized, nil responses 401 with WWW-Authenticate if Users is set, else 403.
+	Unauthorized HandlerFunc
+}
+
+// Can be use as HandlerFunc.
+func (a *StaticAuth) Handle(c *Context) bool {
+	if a.Cookie != "" {
+		if _, err := c.SecureCookie(a.Cookie); err == nil {
+			return true
+		}
+	}
+	if len(a.Users) > 0 {
+		user, password, ok := c.Req.BasicAuth()
+		if ok {
+			if s, ok := a.Users[user]; ok && subtle.ConstantTimeCompare([]byte(s), []byte(password)) == 1 {
+				return true
+			}
+		}
+	}
+	if a.Unauthorized != nil {
+		return a.Unauthorized(c)
+	}
+	if len(a.Users) > 0 {
+		c.Res.Header().Set("WWW-Authenticate", `Basic realm="`+strings.ReplaceAll(a.Realm, `"`, `\"`)+`"`)
+		c.Res.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	c.Res.WriteHeader(http.StatusForbidden)
+	return false
+}
+
+// Protected prefix of static routes.
+type staticAuth struct {
+	prefix string
+	auth   *StaticAuth
+}
+
+// Protect static routes under prefix, such as "/private" protects "/private" and "/private/a.pdf".
+// auth.Handle is called before the file handlers added by AddStatic, AddFile, AddStaticFS and AddVariants,
+// whether they are added before or after it. Call it before Freeze.
+func (r *Router) ProtectStatic(prefix string, auth *StaticAuth) {
+	r.mutex.Lock()
+	r.staticAuths = append(r.staticAuths, staticAuth{prefix: path.Clean("/" + prefix), auth: auth})
+	r.mutex.Unlock()
+}
+
+// Return protected prefixes of static routes.
+func (r *Router) loadStaticAuths() []staticAuth {
+	if r.rlock() {
+		defer r.mutex.RUnlock()
+	}
+	return r.staticAuths
+}
+
+// Return handler chain of static route, auths of protected prefixes are checked before h when serving.
+func (r *Router) staticChain(route string, h HandlerFunc) []HandlerFunc {
+	route = path.Clean("/" + route)
+	return []HandlerFunc{func(c *Context) bool { return r.checkStaticAuth(c, route) }, h}
+}
+
+// Call auth handlers of the prefixes protect route, return false if one of them stops the chain.
+func (r *Router) checkStaticAuth(c *Context, route string) bool {
+	for _, s := range r.loadStaticAuths() {
+		if s.prefix == "/" || route == s.prefix || strings.HasPrefix(route, s.prefix+"/") {
+			if !s.auth.Handle(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
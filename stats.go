@@ -0,0 +1,231 @@
+package router
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Latency histogram buckets, bucket i counts latency < 1<<i microseconds.
+const statsBuckets = 32
+
+// Statistics of a route.
+type RouteStats struct {
+	// Request method and route pattern, example: "GET" and "/users/:".
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	// Total requests.
+	Requests int64 `json:"requests"`
+	// Requests response status code >= 500 or panic.
+	Errors int64 `json:"errors"`
+	// Requests of deprecated route.
+	Deprecated int64 `json:"deprecated"`
+	// Requests being served, used to watch draining progress during shutdown.
+	InFlight int64 `json:"inFlight"`
+	// Latency percentiles, estimated by histogram bucket upper bound.
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	// Requests waited in queue of a Limiter, global, pool or per-route.
+	Queued int64 `json:"queued"`
+	// Requests waiting in queue of a pool or per-route Limiter now, global Limiter is before match so not counted.
+	Waiting int64 `json:"waiting"`
+	// Wait time percentiles of queued requests, estimated same as latency.
+	WaitP50 time.Duration `json:"waitP50"`
+	WaitP95 time.Duration `json:"waitP95"`
+	WaitP99 time.Duration `json:"waitP99"`
+}
+
+// Counters of a route.
+type routeCounter struct {
+	requests   int64
+	errors     int64
+	deprecated int64
+	inflight   int64
+	buckets    [statsBuckets]int64
+	// Queue of Limiter.
+	queued      int64
+	waiting     int64
+	waitBuckets [statsBuckets]int64
+}
+
+func (rc *routeCounter) add(latency time.Duration, isError, isDeprecated bool) {
+	atomic.AddInt64(&rc.requests, 1)
+	if isError {
+		atomic.AddInt64(&rc.errors, 1)
+	}
+	if isDeprecated {
+		atomic.AddInt64(&rc.deprecated, 1)
+	}
+	atomic.AddInt64(&rc.buckets[statsBucket(latency)], 1)
+}
+
+// Count a request waited in queue for d.
+func (rc *routeCounter) addWait(d time.Duration) {
+	atomic.AddInt64(&rc.queued, 1)
+	atomic.AddInt64(&rc.waitBuckets[statsBucket(d)], 1)
+}
+
+// Return histogram bucket index of d.
+func statsBucket(d time.Duration) int {
+	us := d.Microseconds()
+	i := 0
+	for ; i < statsBuckets-1 && us >= 1<<i; i++ {
+	}
+	return i
+}
+
+// Return the upper bound of the bucket where percentile p located.
+func statsPercentile(buckets *[statsBuckets]int64, total int64, p float64) time.Duration {
+	if total < 1 {
+		return 0
+	}
+	n := int64(float64(total)*p + 0.5)
+	if n < 1 {
+		n = 1
+	}
+	var sum int64
+	for i := 0; i < statsBuckets; i++ {
+		sum += buckets[i]
+		if sum >= n {
+			return time.Duration(1<<i) * time.Microsecond
+		}
+	}
+	return time.Duration(1<<(statsBuckets-1)) * time.Microsecond
+}
+
+func (rc *routeCounter) stats(key statsKey) RouteStats {
+	var s RouteStats
+	var buckets [statsBuckets]int64
+	s.Method = key.method
+	s.Route = key.route
+	s.Requests = atomic.LoadInt64(&rc.requests)
+	s.Errors = atomic.LoadInt64(&rc.errors)
+	s.Deprecated = atomic.LoadInt64(&rc.deprecated)
+	s.InFlight = atomic.LoadInt64(&rc.inflight)
+	var total int64
+	for i := 0; i < statsBuckets; i++ {
+		buckets[i] = atomic.LoadInt64(&rc.buckets[i])
+		total += buckets[i]
+	}
+	s.P50 = statsPercentile(&buckets, total, 0.50)
+	s.P95 = statsPercentile(&buckets, total, 0.95)
+	s.P99 = statsPercentile(&buckets, total, 0.99)
+	s.Queued = atomic.LoadInt64(&rc.queued)
+	s.Waiting = atomic.LoadInt64(&rc.waiting)
+	for i := 0; i < statsBuckets; i++ {
+		buckets[i] = atomic.LoadInt64(&rc.waitBuckets[i])
+	}
+	s.WaitP50 = statsPercentile(&buckets, s.Queued, 0.50)
+	s.WaitP95 = statsPercentile(&buckets, s.Queued, 0.95)
+	s.WaitP99 = statsPercentile(&buckets, s.Queued, 0.99)
+	return s
+}
+
+// Key of statsCollector, routes of different methods may have the same pattern.
+type statsKey struct {
+	method string
+	route  string
+}
+
+// Collect statistics of routes, key is statsKey.
+type statsCollector struct {
+	routes sync.Map
+}
+
+// Return counter of matched route of c.
+func (s *statsCollector) counter(c *Context) *routeCounter {
+	key := statsKey{method: c.Req.Method, route: c.route.path}
+	v, ok := s.routes.Load(key)
+	if !ok {
+		v, _ = s.routes.LoadOrStore(key, new(routeCounter))
+	}
+	return v.(*routeCounter)
+}
+
+// Count in-flight request of matched route.
+func (s *statsCollector) begin(c *Context) {
+	c.statsCounter = s.counter(c)
+	atomic.AddInt64(&c.statsCounter.inflight, 1)
+}
+
+// Stop counting in-flight request of matched route.
+func (s *statsCollector) done(c *Context) {
+	if c.statsCounter != nil {
+		atomic.AddInt64(&c.statsCounter.inflight, -1)
+		c.statsCounter = nil
+	}
+}
+
+func (s *statsCollector) record(c *Context) {
+	s.done(c)
+	if c.route == nil {
+		return
+	}
+	rc := s.counter(c)
+	rc.add(time.Since(c.start),
+		c.panicValue != nil || c.Status() >= http.StatusInternalServerError,
+		c.route.meta.deprecated)
+	if c.queued {
+		rc.addWait(c.queueWait)
+	}
+}
+
+// Return number of requests being served.
+func (r *Router) InFlight() int64 {
+	return atomic.LoadInt64(&r.inflight)
+}
+
+// Return true if server is shutting down, set by Server.Shutdown.
+func (r *Router) Draining() bool {
+	return atomic.LoadInt32(&r.draining) != 0
+}
+
+// Enable or disable the built-in statistics collector.
+// Disable it will discard all collected statistics.
+func (r *Router) SetStats(enable bool) {
+	if !enable {
+		r.stats = nil
+		return
+	}
+	if r.stats == nil {
+		r.stats = new(statsCollector)
+	}
+}
+
+// Return statistics of all routes sorted by route pattern and method, nil if statistics is disabled.
+func (r *Router) Stats() []RouteStats {
+	if r.stats == nil {
+		return nil
+	}
+	var stats []RouteStats
+	r.stats.routes.Range(func(k, v interface{}) bool {
+		stats = append(stats, v.(*routeCounter).stats(k.(statsKey)))
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Route != stats[j].Route {
+			return stats[i].Route < stats[j].Route
+		}
+		return stats[i].Method < stats[j].Method
+	})
+	return stats
+}
+
+// Publish Stats() to expvar with name, then it can be read from /debug/vars.
+func (r *Router) PublishStats(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.Stats()
+	}))
+}
+
+// Response Stats() in JSON. Can be use as HandlerFunc.
+func (r *Router) StatsHandler(c *Context) bool {
+	c.Res.Header().Set("Content-Type", ContentTypeJSON)
+	c.Res.WriteHeader(http.StatusOK)
+	return json.NewEncoder(c.Res).Encode(r.Stats()) == nil
+}
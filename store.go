@@ -0,0 +1,198 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Storage of counters and idempotency keys, shared by RateLimiter and other components across router instances.
+type LimiterStore interface {
+	// Increase counter of key by 1, the counter expires after ttl since it is created.
+	// Return current count and remaining ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error)
+	// Remove hits of key before now-window, add a hit at now if hits in window are less than limit.
+	// Return hits count in window including this hit, it is greater than limit if the hit is rejected and not recorded.
+	SlidingWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit int64) (int64, error)
+	// Claim an idempotency key for ttl, return false if it is claimed and not expired.
+	// Example: process a request only if Claim(ctx, "idempotency:"+c.Req.Header.Get("Idempotency-Key"), time.Hour) is true.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// In-memory LimiterStore, only for single instance.
+type MemoryStore struct {
+	mutex     sync.Mutex
+	counters  map[string]*memoryCounter
+	windows   map[string]*memoryWindow
+	claims    map[string]time.Time
+	nextPrune time.Time
+}
+
+type memoryCounter struct {
+	count  int64
+	expire time.Time
+}
+
+type memoryWindow struct {
+	hits   []time.Time
+	expire time.Time
+}
+
+// Return a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := new(MemoryStore)
+	s.counters = make(map[string]*memoryCounter)
+	s.windows = make(map[string]*memoryWindow)
+	s.claims = make(map[string]time.Time)
+	return s
+}
+
+// Remove expired keys at most once per second.
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	for k, c := range s.counters {
+		if !now.Before(c.expire) {
+			delete(s.counters, k)
+		}
+	}
+	for k, w := range s.windows {
+		if !now.Before(w.expire) {
+			delete(s.windows, k)
+		}
+	}
+	for k, expire := range s.claims {
+		if !now.Before(expire) {
+			delete(s.claims, k)
+		}
+	}
+	s.nextPrune = now.Add(time.Second)
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune(now)
+	c := s.counters[key]
+	if c == nil || !now.Before(c.expire) {
+		c = &memoryCounter{expire: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.expire.Sub(now), nil
+}
+
+func (s *MemoryStore) SlidingWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit int64) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune(now)
+	w := s.windows[key]
+	if w == nil {
+		w = new(memoryWindow)
+		s.windows[key] = w
+	}
+	begin := now.Add(-window)
+	i := 0
+	for ; i < len(w.hits) && !w.hits[i].After(begin); i++ {
+	}
+	w.hits = append(w.hits[:0], w.hits[i:]...)
+	// Rejected hit is not recorded, so it does not delay the next accepted one.
+	if int64(len(w.hits)) >= limit {
+		return int64(len(w.hits)) + 1, nil
+	}
+	w.hits = append(w.hits, now)
+	w.expire = now.Add(window)
+	return int64(len(w.hits)), nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune(now)
+	if expire, ok := s.claims[key]; ok && now.Before(expire) {
+		return false, nil
+	}
+	s.claims[key] = now.Add(ttl)
+	return true, nil
+}
+
+// The subset of a Redis client used by RedisStore.
+// Adapt your client, example go-redis: client.Eval(ctx, script, keys, args...).Result().
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Lua scripts of RedisStore.
+const (
+	redisIncrScript = `local n = redis.call("INCR", KEYS[1])
+if n == 1 then redis.call("PEXPIRE", KEYS[1], ARGV[1]) end
+return {n, redis.call("PTTL", KEYS[1])}`
+	redisSlidingWindowScript = `redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1] - ARGV[2])
+local n = redis.call("ZCARD", KEYS[1])
+if n >= tonumber(ARGV[4]) then return n + 1 end
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return n + 1`
+	redisClaimScript = `if redis.call("SET", KEYS[1], 1, "NX", "PX", ARGV[1]) then return 1 end
+return 0`
+)
+
+// Example LimiterStore backed by Redis, shared across router instances.
+type RedisStore struct {
+	Client RedisClient
+	// Prepend to each key, example: "ratelimit:".
+	Prefix string
+	// Make members of sliding window unique.
+	seq   uint64
+	mutex sync.Mutex
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	v, err := s.Client.Eval(ctx, redisIncrScript, []string{s.Prefix + key}, ttl.Milliseconds())
+	if err != nil {
+		return 0, 0, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 2 {
+		return 0, 0, fmt.Errorf("redis store: unexpected result %v", v)
+	}
+	n, ok1 := a[0].(int64)
+	pttl, ok2 := a[1].(int64)
+	if !ok1 || !ok2 {
+		return 0, 0, fmt.Errorf("redis store: unexpected result %v", v)
+	}
+	return n, time.Duration(pttl) * time.Millisecond, nil
+}
+
+func (s *RedisStore) SlidingWindow(ctx context.Context, key string, now time.Time, window time.Duration, limit int64) (int64, error) {
+	s.mutex.Lock()
+	s.seq++
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), s.seq)
+	s.mutex.Unlock()
+	v, err := s.Client.Eval(ctx, redisSlidingWindowScript, []string{s.Prefix + key},
+		now.UnixMilli(), window.Milliseconds(), member, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis store: unexpected result %v", v)
+	}
+	return n, nil
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	v, err := s.Client.Eval(ctx, redisClaimScript, []string{s.Prefix + key}, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return false, fmt.Errorf("redis store: unexpected result %v", v)
+	}
+	return n == 1, nil
+}
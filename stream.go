@@ -0,0 +1,145 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Content-Type of server-sent events.
+const ContentTypeEventStream = "text/event-stream"
+
+// How a streaming response ended, see Context.StreamEnd.
+type StreamEnd int
+
+const (
+	// Response is not streaming, or handlers are still running.
+	StreamEndNone StreamEnd = iota
+	// Handlers returned while client is connected.
+	StreamEndServer
+	// Client disconnected or request is canceled before handlers returned.
+	StreamEndClient
+	// Handlers panicked.
+	StreamEndPanic
+)
+
+func (e StreamEnd) String() string {
+	switch e {
+	case StreamEndServer:
+		return "server"
+	case StreamEndClient:
+		return "client"
+	case StreamEndPanic:
+		return "panic"
+	}
+	return ""
+}
+
+// Return whether response has been flushed before handlers returned, such as SSE or NDJSON.
+// After chain runs when the stream terminates, headers are sent already,
+// so after handlers of streaming responses should not write.
+func (c *Context) Streaming() bool {
+	return !c.res.streamStart.IsZero()
+}
+
+// Return duration from the first flush to the termination of stream,
+// or to now if handlers are still running, 0 if response is not streaming.
+// Bytes streamed is Context.Size.
+func (c *Context) StreamDuration() time.Duration {
+	if c.res.streamStart.IsZero() {
+		return 0
+	}
+	if c.streamEnd == StreamEndNone {
+		return time.Since(c.res.streamStart)
+	}
+	return c.streamStop.Sub(c.res.streamStart)
+}
+
+// Return how the streaming response ended, used in after chain.
+func (c *Context) StreamEnd() StreamEnd {
+	return c.streamEnd
+}
+
+// Record termination of stream, called before after chain.
+func (c *Context) endStream() {
+	if c.res.streamStart.IsZero() || c.streamEnd != StreamEndNone {
+		return
+	}
+	c.streamStop = time.Now()
+	switch {
+	case c.panicValue != nil:
+		c.streamEnd = StreamEndPanic
+	case c.Req.Context().Err() != nil:
+		c.streamEnd = StreamEndClient
+	default:
+		c.streamEnd = StreamEndServer
+	}
+}
+
+// Write server-sent events, each is flushed to client immediately.
+type SSEWriter struct {
+	c      *Context
+	header bool
+}
+
+// Return a SSEWriter, response header is written at the first Send.
+func (c *Context) SSE() *SSEWriter {
+	return &SSEWriter{c: c}
+}
+
+func (w *SSEWriter) writeHeader() {
+	if w.header {
+		return
+	}
+	w.header = true
+	w.c.Res.Header().Set("Content-Type", ContentTypeEventStream)
+	w.c.Res.Header().Set("Cache-Control", "no-cache")
+	w.c.Res.WriteHeader(http.StatusOK)
+}
+
+// Write an event and flush, event is omitted if it is empty, multi-line data is split into "data:" lines.
+// It returns error of the context if client is gone.
+func (w *SSEWriter) Send(event, data string) error {
+	return w.SendID("", event, data)
+}
+
+// Write an event with id and flush, id and event are omitted if they are empty.
+func (w *SSEWriter) SendID(id, event, data string) error {
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+	return w.write(b.String())
+}
+
+// Write a comment line and flush, used as keep-alive.
+func (w *SSEWriter) Comment(text string) error {
+	return w.write(": " + text + "\n\n")
+}
+
+// Write "retry" field, client reconnects after d.
+func (w *SSEWriter) Retry(d time.Duration) error {
+	return w.write(fmt.Sprintf("retry: %d\n\n", d.Milliseconds()))
+}
+
+func (w *SSEWriter) write(s string) error {
+	err := w.c.Req.Context().Err()
+	if err != nil {
+		return err
+	}
+	w.writeHeader()
+	_, err = w.c.Res.Write([]byte(s))
+	if err != nil {
+		return err
+	}
+	w.c.res.Flush()
+	return nil
+}
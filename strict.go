@@ -0,0 +1,84 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Default max count of header fields of StrictRequest.
+const defaultMaxHeaders = 100
+
+// Options of strict request checking, used by edge deployments against request smuggling.
+// Requests fail the checks are handled by bad request chain before any handler runs, see SetBadRequest.
+type StrictRequest struct {
+	// Max count of header fields, 0 is 100.
+	MaxHeaders int
+}
+
+// Enable strict request checking, nil to disable.
+// Rejected requests are:
+// both Content-Length and Transfer-Encoding, Content-Length of different or invalid values,
+// Transfer-Encoding other than "chunked", too many header fields,
+// and control characters in path or header values.
+func (r *Router) SetStrictRequest(s *StrictRequest) {
+	r.strict = s
+}
+
+// Option of SetStrictRequest.
+func WithStrictRequest(s *StrictRequest) Option {
+	return func(r *Router) {
+		r.SetStrictRequest(s)
+	}
+}
+
+// Return false if req fails the checks.
+func (s *StrictRequest) check(req *http.Request) bool {
+	max := s.MaxHeaders
+	if max < 1 {
+		max = defaultMaxHeaders
+	}
+	n := 0
+	for _, v := range req.Header {
+		n += len(v)
+		if n > max {
+			return false
+		}
+		for _, value := range v {
+			if hasCTL(value, true) {
+				return false
+			}
+		}
+	}
+	if hasCTL(req.URL.Path, false) || hasCTL(req.Host, false) {
+		return false
+	}
+	// Conflicting framing.
+	cl := req.Header.Values("Content-Length")
+	te := req.TransferEncoding
+	if len(te) < 1 {
+		te = req.Header.Values("Transfer-Encoding")
+	}
+	if len(te) > 0 && len(cl) > 0 {
+		return false
+	}
+	if len(te) > 1 || (len(te) == 1 && !strings.EqualFold(te[0], "chunked")) {
+		return false
+	}
+	for i, v := range cl {
+		if _, err := strconv.ParseUint(v, 10, 63); err != nil || (i > 0 && v != cl[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return true if s has control characters, tab is allowed if tab is true.
+func hasCTL(s string, tab bool) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < ' ' && !(tab && s[i] == '\t')) || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
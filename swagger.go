@@ -0,0 +1,47 @@
+package router
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"path"
+	"time"
+)
+
+//go:embed swagger/index.html
+var swaggerFS embed.FS
+
+// Base URL of swagger-ui-dist assets (swagger-ui.css and swagger-ui-bundle.js).
+// Set it to a local path such as "/swagger/assets" and serve the files by AddStaticFS to avoid CDN.
+var SwaggerUIAssetsURL = "https://unpkg.com/swagger-ui-dist@5"
+
+// Add Swagger UI page at prefix and generated OpenAPI document at prefix+"/openapi.json".
+// auth handlers are called before both, use them to protect the document.
+func (r *Router) AddSwaggerUI(prefix string, auth ...HandlerFunc) error {
+	prefix = path.Clean("/" + prefix)
+	specPath := path.Join(prefix, "openapi.json")
+	tpl, err := template.ParseFS(swaggerFS, "swagger/index.html")
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]string{
+		"Title":  r.OpenAPI().Info.Title,
+		"Assets": SwaggerUIAssetsURL,
+		"Spec":   specPath,
+	})
+	if err != nil {
+		return err
+	}
+	page := &CacheHandler{
+		ContentType: ContentTypeHTML,
+		ModTime:     time.Now(),
+		Data:        buf.Bytes(),
+	}
+	_, err = r.AddGet(prefix, append(append([]HandlerFunc{}, auth...), page.Handle)...)
+	if err != nil {
+		return err
+	}
+	_, err = r.AddGet(specPath, append(append([]HandlerFunc{}, auth...), r.OpenAPIHandler)...)
+	return err
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"net/http"
+	"time"
+)
+
+// A route added by AddTemporary.
+type temporaryRoute struct {
+	method  string
+	path    string
+	pattern string
+	route   *Route
+	handler []HandlerFunc
+	expires time.Time
+}
+
+// Return true if route of table still has handlers added by AddTemporary, not replaced or removed.
+func (t *temporaryRoute) alive(table Matcher) bool {
+	if table.Find(t.path) != t.route {
+		return false
+	}
+	h := t.route.Handler
+	return len(h) > 0 && len(h) == len(t.handler) && &h[0] == &t.handler[0]
+}
+
+// Add a route removed after ttl, such as a webhook callback url provisioned dynamically.
+// Expired routes are removed by a janitor goroutine, which exits when there is no temporary route,
+// and they response 404 before removed, or forever after Freeze. Sub routes of path are kept.
+// Add the path again by Add replaces it with a permanent route.
+func (r *Router) AddTemporary(method, path string, ttl time.Duration, funcs ...HandlerFunc) (*Route, error) {
+	expires := time.Now().Add(ttl)
+	handler := make([]HandlerFunc, 0, len(funcs)+1)
+	handler = append(handler, func(c *Context) bool {
+		if time.Now().Before(expires) {
+			return true
+		}
+		c.Res.WriteHeader(http.StatusNotFound)
+		return false
+	})
+	handler = append(handler, funcs...)
+	route, err := r.Add(method, path, handler...)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	r.temporaries = append(r.temporaries, temporaryRoute{
+		method:  method,
+		path:    path,
+		pattern: route.Pattern(),
+		route:   route,
+		handler: route.Handler,
+		expires: expires,
+	})
+	if r.janitor == nil {
+		r.janitor = make(chan struct{}, 1)
+		go r.removeExpired(r.janitor)
+	} else {
+		select {
+		case r.janitor <- struct{}{}:
+		default:
+		}
+	}
+	r.mutex.Unlock()
+	return route, nil
+}
+
+// Set a hook called after a route is removed by Remove or expired, with method and pattern of the route.
+func (r *Router) OnRouteRemoved(fn func(method, pattern string)) {
+	r.onRouteRemoved = fn
+}
+
+// Janitor of temporary routes, wake is signaled when a route is added.
+func (r *Router) removeExpired(wake chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+		next, removed := r.expire(time.Now())
+		if r.onRouteRemoved != nil {
+			for _, t := range removed {
+				r.onRouteRemoved(t.method, t.pattern)
+			}
+		}
+		if next.IsZero() {
+			return
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// Remove routes expired at now, return them and the next expiry.
+// Zero next means there is no temporary route, janitor exits.
+func (r *Router) expire(now time.Time) (next time.Time, removed []temporaryRoute) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	routes := r.temporaries[:0]
+	for _, t := range r.temporaries {
+		table := r.table(t.method)
+		if r.Frozen() || !t.alive(table) {
+			continue
+		}
+		if now.Before(t.expires) {
+			routes = append(routes, t)
+			if next.IsZero() || t.expires.Before(next) {
+				next = t.expires
+			}
+			continue
+		}
+		removeAliases(table, t.route)
+		discardRoute(table, t.path, t.route)
+		removed = append(removed, t)
+	}
+	for i := len(routes); i < len(r.temporaries); i++ {
+		r.temporaries[i] = temporaryRoute{}
+	}
+	r.temporaries = routes
+	if next.IsZero() {
+		r.janitor = nil
+	}
+	return
+}
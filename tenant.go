@@ -0,0 +1,104 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Resolve tenant of request, read it by Context.Tenant, Handle can be used in before chain.
+// Sources are tried in order: subdomain, header, path prefix.
+// RateLimiter keys and sessions of SessionManager are scoped by tenant.
+type Tenancy struct {
+	// Resolve tenant from subdomain of Domain, such as "acme" of "acme.example.com" if Domain is "example.com".
+	Domain string
+	// Resolve tenant from header, such as "X-Tenant-ID".
+	Header string
+	// Resolve tenant from the first path segment, such as "acme" of "/acme/orders",
+	// the segment is removed before matching, so routes are added as "/orders".
+	Path bool
+	// Return true if tenant is valid, nil allows all tenants.
+	Valid func(tenant string) bool
+	// Called if tenant is not resolved or invalid, nil responses 404.
+	Unresolved HandlerFunc
+}
+
+// Can be use as HandlerFunc.
+func (t *Tenancy) Handle(c *Context) bool {
+	tenant := t.subdomain(c.Req.Host)
+	if tenant == "" && t.Header != "" {
+		tenant = c.Req.Header.Get(t.Header)
+	}
+	fromPath := false
+	if tenant == "" && t.Path {
+		tenant, fromPath = tenantSegment(c.Req.URL.Path), true
+	}
+	if tenant == "" || (t.Valid != nil && !t.Valid(tenant)) {
+		if t.Unresolved != nil {
+			return t.Unresolved(c)
+		}
+		c.Res.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	c.tenant = tenant
+	if fromPath {
+		c.Req = stripPathSegment(c.Req, len(tenant)+1)
+	}
+	return true
+}
+
+// Return the subdomain of host under t.Domain, empty if it is not or it has more than one label.
+func (t *Tenancy) subdomain(host string) string {
+	if t.Domain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, "."+t.Domain) {
+		return ""
+	}
+	s := host[:len(host)-len(t.Domain)-1]
+	if strings.IndexByte(s, '.') >= 0 {
+		return ""
+	}
+	return s
+}
+
+// Return the first segment of path, such as "a" of "/a/b".
+func tenantSegment(path string) string {
+	if len(path) < 2 || path[0] != '/' {
+		return ""
+	}
+	s, _, _ := strings.Cut(path[1:], "/")
+	return s
+}
+
+// Return a shallow copy of req, with the first n bytes of path removed, like http.StripPrefix.
+func stripPathSegment(req *http.Request, n int) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+	r.URL.Path = req.URL.Path[n:]
+	if r.URL.Path == "" {
+		r.URL.Path = "/"
+	}
+	r.URL.RawPath = ""
+	return r
+}
+
+// Return tenant resolved by Tenancy, empty if it is not resolved.
+func (c *Context) Tenant() string {
+	return c.tenant
+}
+
+// Return key scoped by tenant of request.
+func (c *Context) tenantKey(key string) string {
+	if c.tenant == "" {
+		return key
+	}
+	return c.tenant + ":" + key
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Trace context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+	B3Header          = "b3"
+	B3TraceIDHeader   = "X-B3-TraceId"
+	B3SpanIDHeader    = "X-B3-SpanId"
+	B3ParentIDHeader  = "X-B3-ParentSpanId"
+	B3SampledHeader   = "X-B3-Sampled"
+)
+
+// Trace and span ids of a request, compatible with W3C trace context and B3.
+type TraceContext struct {
+	TraceID [16]byte
+	// Span id of current request.
+	SpanID [8]byte
+	// Span id of caller, zero if request has no trace context.
+	ParentID [8]byte
+	Sampled  bool
+	// Value of tracestate header, pass to outbound requests.
+	State string
+}
+
+// Return hex string of TraceID.
+func (t *TraceContext) TraceIDString() string {
+	return hex.EncodeToString(t.TraceID[:])
+}
+
+// Return hex string of SpanID.
+func (t *TraceContext) SpanIDString() string {
+	return hex.EncodeToString(t.SpanID[:])
+}
+
+// Return traceparent header value, example: "00-<trace-id>-<span-id>-01".
+func (t *TraceContext) Traceparent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceIDString() + "-" + t.SpanIDString() + "-" + flags
+}
+
+// Return b3 single header value, example: "<trace-id>-<span-id>-1".
+func (t *TraceContext) B3() string {
+	if t.Sampled {
+		return t.TraceIDString() + "-" + t.SpanIDString() + "-1"
+	}
+	return t.TraceIDString() + "-" + t.SpanIDString() + "-0"
+}
+
+// Set W3C and B3 headers to h, current span becomes the parent of the outbound request.
+func (t *TraceContext) Inject(h http.Header) {
+	h.Set(TraceparentHeader, t.Traceparent())
+	if t.State != "" {
+		h.Set(TracestateHeader, t.State)
+	}
+	h.Set(B3Header, t.B3())
+	h.Del(B3TraceIDHeader)
+	h.Del(B3SpanIDHeader)
+	h.Del(B3ParentIDHeader)
+	h.Del(B3SampledHeader)
+}
+
+// Try to parse traceparent header value, example: "00-<trace-id>-<parent-id>-<flags>".
+func ParseTraceparent(s string) (t TraceContext, ok bool) {
+	part := strings.Split(s, "-")
+	if len(part) < 4 || len(part[0]) != 2 || part[0] == "ff" {
+		return t, false
+	}
+	if !decodeTraceID(t.TraceID[:], part[1]) || !decodeTraceID(t.ParentID[:], part[2]) {
+		return t, false
+	}
+	var flags [1]byte
+	if !decodeTraceID(flags[:], part[3]) {
+		return t, false
+	}
+	t.Sampled = flags[0]&1 == 1
+	return t, true
+}
+
+// Try to parse B3 single header or multi headers.
+func ParseB3(h http.Header) (t TraceContext, ok bool) {
+	var traceID, spanID, sampled string
+	if s := h.Get(B3Header); s != "" {
+		part := strings.Split(s, "-")
+		if len(part) < 2 {
+			return t, false
+		}
+		traceID, spanID = part[0], part[1]
+		if len(part) > 2 {
+			sampled = part[2]
+		}
+	} else {
+		traceID = h.Get(B3TraceIDHeader)
+		spanID = h.Get(B3SpanIDHeader)
+		sampled = h.Get(B3SampledHeader)
+	}
+	// 64 bits trace id.
+	if len(traceID) == 16 {
+		traceID = "0000000000000000" + traceID
+	}
+	if !decodeTraceID(t.TraceID[:], traceID) || !decodeTraceID(t.ParentID[:], spanID) {
+		return t, false
+	}
+	t.Sampled = sampled == "1" || sampled == "d" || sampled == "true"
+	return t, true
+}
+
+// Decode hex s into b, s must be exact length and not all zero.
+func decodeTraceID(b []byte, s string) bool {
+	if len(s) != len(b)*2 {
+		return false
+	}
+	_, err := hex.Decode(b, []byte(s))
+	if err != nil {
+		return false
+	}
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return len(b) == 1
+}
+
+// Return trace context of request.
+// It is parsed from traceparent or B3 headers, or generated if request has none,
+// and a new span id is generated for current request.
+func (c *Context) Trace() *TraceContext {
+	if !c.traceOK {
+		var ok bool
+		c.trace, ok = ParseTraceparent(c.Req.Header.Get(TraceparentHeader))
+		if ok {
+			c.trace.State = c.Req.Header.Get(TracestateHeader)
+		} else {
+			c.trace, ok = ParseB3(c.Req.Header)
+			if !ok {
+				rand.Read(c.trace.TraceID[:])
+				c.trace.Sampled = true
+			}
+		}
+		rand.Read(c.trace.SpanID[:])
+		c.traceOK = true
+	}
+	return &c.trace
+}
+
+// Set trace headers of current request to outbound request.
+func (c *Context) InjectTrace(req *http.Request) {
+	c.Trace().Inject(req.Header)
+}
@@ -0,0 +1,43 @@
+package router
+
+import (
+	"net/http"
+	"net/textproto"
+)
+
+// Declare trailers in "Trailer" header, it must be called before the first write.
+// Streaming writers such as NDJSONWriter declare them when they write the header.
+func (c *Context) DeclareTrailer(names ...string) {
+	for _, name := range names {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if !hasString(c.res.trailers, name) {
+			c.res.trailers = append(c.res.trailers, name)
+		}
+	}
+}
+
+// Set a trailer sent after body, such as checksum or status of a stream.
+// If it is called before the first write, the trailer is declared, and the value is sent after handlers.
+// Otherwise it is sent even if it is not declared.
+func (c *Context) SetTrailer(name, value string) {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	if c.res.status == 0 {
+		c.DeclareTrailer(name)
+		if c.trailers == nil {
+			c.trailers = make(map[string]string)
+		}
+		c.trailers[name] = value
+		return
+	}
+	if !hasString(c.res.trailers, name) {
+		name = http.TrailerPrefix + name
+	}
+	c.res.Header().Set(name, value)
+}
+
+// Set trailers which are set before the first write, called after handlers.
+func (c *Context) setTrailers() {
+	for name, value := range c.trailers {
+		c.res.Header().Set(name, value)
+	}
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A representation of a resource, such as logo.webp of logo.
+type Variant struct {
+	// Media type without params, such as "image/webp".
+	ContentType string
+	// Serve the variant.
+	Handler HandlerFunc
+}
+
+// Serve one of variants of a resource selected by Accept header, and set "Vary: Accept".
+type VariantHandler struct {
+	// Variants in preference order of server, the first one is used if Accept is empty or nothing is acceptable.
+	Variants []Variant
+}
+
+// Can be use as HandlerFunc.
+func (h *VariantHandler) Handle(c *Context) bool {
+	c.Res.Header().Add("Vary", "Accept")
+	if len(h.Variants) < 1 {
+		c.Res.WriteHeader(http.StatusNotFound)
+		return true
+	}
+	offers := make([]string, len(h.Variants))
+	for i := range h.Variants {
+		offers[i] = h.Variants[i].ContentType
+	}
+	best := c.NegotiateContentType(offers...)
+	for i := range h.Variants {
+		if h.Variants[i].ContentType == best {
+			return h.Variants[i].Handler(c)
+		}
+	}
+	return h.Variants[0].Handler(c)
+}
+
+// Return the best media type in offers by Accept, offers[0] if header is empty or nothing is acceptable.
+// Offers of the same quality are selected in order.
+func (c *Context) NegotiateContentType(offers ...string) string {
+	if len(offers) < 1 {
+		return ""
+	}
+	header := c.Req.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		q := mediaQuality(header, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Return quality of media type in Accept header, the most specific range is used, 0 if not listed.
+func mediaQuality(header, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specific := 0.0, -1
+	for _, s := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		name = strings.TrimSpace(name)
+		n := -1
+		switch {
+		case strings.EqualFold(name, mediaType):
+			n = 2
+		case strings.EqualFold(name, typ+"/*"):
+			n = 1
+		case name == "*/*":
+			n = 0
+		}
+		if n <= specific {
+			continue
+		}
+		v := 1.0
+		for _, p := range strings.Split(params, ";") {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				f, err := strconv.ParseFloat(p[2:], 64)
+				if err == nil {
+					v = f
+				}
+			}
+		}
+		q, specific = v, n
+	}
+	return q
+}
+
+// Try to add a route serves one of files selected by Accept header, Content-Type is detected by file extension.
+// Files are in preference order, such as "logo.avif", "logo.webp", "logo.png".
+// If cache is true, use CacheHandler, else use FileHandler.
+func (r *Router) AddVariants(method, route string, cache bool, files ...string) error {
+	h := new(VariantHandler)
+	var caches []*CacheHandler
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return fmt.Errorf("%s is a directory", file)
+		}
+		contentType, _, _ := strings.Cut(ContentTypeByExtension(filepath.Ext(file)), ";")
+		if contentType == "" {
+			return fmt.Errorf("%s unknown content type", file)
+		}
+		v := Variant{ContentType: contentType}
+		if cache {
+			ch, err := CacheHandlerFromFile(file)
+			if err == nil {
+				err = r.minifyCache(ch)
+			}
+			if err != nil {
+				return err
+			}
+			v.Handler = ch.Handle
+			caches = append(caches, ch)
+		} else {
+			v.Handler = (&FileHandler{File: file}).Handle
+		}
+		h.Variants = append(h.Variants, v)
+	}
+	_, err := r.Add(method, route, r.staticChain(route, h.Handle)...)
+	if err != nil {
+		return err
+	}
+	for _, ch := range caches {
+		r.addStaticCache(route, ch)
+	}
+	return nil
+}
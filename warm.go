@@ -0,0 +1,64 @@
+package router
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+)
+
+// CacheHandler added by AddFile, AddStatic, AddStaticFS or AddVariants.
+type staticCache struct {
+	route   string
+	handler *CacheHandler
+}
+
+// Track h added as route, see WarmStatic. Data of h is shared with identical files.
+func (r *Router) addStaticCache(route string, h *CacheHandler) {
+	r.dedupStatic(h)
+	r.staticCaches = append(r.staticCaches, staticCache{route: route, handler: h})
+}
+
+// Compress cached assets for all encodings and compute their digests, call it before serving.
+// Without it, data is compressed once when first requested, which delays that request.
+// Return integrity manifest of route paths, such as {"/static/app.js": "sha384-..."},
+// it can be set to Renderer.Integrity. Route of AddVariants uses its first file.
+func (r *Router) WarmStatic() (map[string]string, error) {
+	manifest := make(map[string]string)
+	for _, s := range r.staticCaches {
+		err := s.handler.warm()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := manifest[s.route]; !ok {
+			manifest[s.route] = s.handler.integrity
+		}
+	}
+	return manifest, nil
+}
+
+// Compress data by all compressions, and compute digests and integrity.
+func (h *CacheHandler) warm() error {
+	err := h.Precompute()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(h.compressedData); i++ {
+		h.digestOf(i, h.compressedData[i])
+	}
+	h.digestOf(len(h.compressedData), h.Data)
+	h.integrity = Integrity(h.Data)
+	return nil
+}
+
+// Return subresource integrity of origin data, such as "sha384-...".
+func (h *CacheHandler) Integrity() string {
+	if h.integrity == "" {
+		return Integrity(h.Data)
+	}
+	return h.integrity
+}
+
+// Return subresource integrity value of b, such as "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC".
+func Integrity(b []byte) string {
+	sum := sha512.Sum384(b)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
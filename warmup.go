@@ -0,0 +1,69 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// What to do if warmup fails.
+type WarmupPolicy int
+
+const (
+	// Server.Run returns the error, or router is never ready if warmup runs in background.
+	WarmupFailStop WarmupPolicy = iota
+	// Log the error and serve anyway.
+	WarmupFailServe
+)
+
+// Set fn called by Server.Run before the listener accepts, such as preloading templates and WarmStatic.
+// ctx is canceled after timeout, 0 is no timeout. Router is not ready until fn returns, see Ready.
+func (r *Router) SetWarmup(fn func(ctx context.Context) error, timeout time.Duration, policy WarmupPolicy) {
+	r.warmup = fn
+	r.warmupTimeout = timeout
+	r.warmupPolicy = policy
+}
+
+// Call warmup function if it is set, router is ready if it returns nil or policy is WarmupFailServe.
+// It is called by Server.Run, call it before serving if router is served by other servers.
+func (r *Router) Warmup(ctx context.Context) error {
+	var err error
+	if r.warmup != nil {
+		if r.warmupTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, r.warmupTimeout)
+			defer cancel()
+		}
+		start := time.Now()
+		err = r.warmup(ctx)
+		if err != nil {
+			r.Logger().Error("warmup", "latency", time.Since(start), "error", err)
+			if r.warmupPolicy == WarmupFailStop {
+				return err
+			}
+		} else {
+			r.Logger().Info("warmup", "latency", time.Since(start))
+		}
+	}
+	atomic.StoreInt32(&r.warmed, 1)
+	return err
+}
+
+// Return true if warmup is done or not set, and router is not draining.
+func (r *Router) Ready() bool {
+	if r.Draining() {
+		return false
+	}
+	return r.warmup == nil || atomic.LoadInt32(&r.warmed) != 0
+}
+
+// Response 200 if router is ready, else 503. Can be use as HandlerFunc, such as "/readyz".
+func (r *Router) ReadyzHandler(c *Context) bool {
+	if r.Ready() {
+		c.Res.WriteHeader(http.StatusOK)
+	} else {
+		c.Res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fields of security.txt, RFC 9116.
+type SecurityTxt struct {
+	// Required, such as "mailto:security@example.com".
+	Contact []string
+	// Required.
+	Expires            time.Time
+	Encryption         []string
+	Acknowledgments    []string
+	PreferredLanguages []string
+	Canonical          []string
+	Policy             []string
+	Hiring             []string
+}
+
+func (s *SecurityTxt) String() string {
+	var str strings.Builder
+	write := func(name string, values []string) {
+		for _, v := range values {
+			fmt.Fprintf(&str, "%s: %s\n", name, v)
+		}
+	}
+	write("Contact", s.Contact)
+	fmt.Fprintf(&str, "Expires: %s\n", s.Expires.UTC().Format(time.RFC3339))
+	write("Encryption", s.Encryption)
+	write("Acknowledgments", s.Acknowledgments)
+	if len(s.PreferredLanguages) > 0 {
+		fmt.Fprintf(&str, "Preferred-Languages: %s\n", strings.Join(s.PreferredLanguages, ", "))
+	}
+	write("Canonical", s.Canonical)
+	write("Policy", s.Policy)
+	write("Hiring", s.Hiring)
+	return str.String()
+}
+
+// Statement of Android assetlinks.json.
+type AssetLink struct {
+	Relation []string        `json:"relation"`
+	Target   AssetLinkTarget `json:"target"`
+}
+
+type AssetLinkTarget struct {
+	// Such as "android_app" or "web".
+	Namespace              string   `json:"namespace"`
+	PackageName            string   `json:"package_name,omitempty"`
+	SHA256CertFingerprints []string `json:"sha256_cert_fingerprints,omitempty"`
+	Site                   string   `json:"site,omitempty"`
+}
+
+// Documents under "/.well-known/", nil or empty fields are not added.
+type WellKnown struct {
+	// "/.well-known/security.txt".
+	SecurityTxt *SecurityTxt
+	// "/.well-known/change-password" redirects to it.
+	ChangePasswordURL string
+	// "/.well-known/assetlinks.json".
+	AssetLinks []AssetLink
+	// "/.well-known/apple-app-site-association", it is encoded as JSON.
+	AppleAppSiteAssociation interface{}
+}
+
+// Add GET routes of documents in w, documents are served by CacheHandler.
+func (r *Router) AddWellKnown(w *WellKnown) error {
+	const prefix = "/.well-known/"
+	modTime := time.Now()
+	add := func(name, contentType string, data []byte) error {
+		h := &CacheHandler{ContentType: contentType, ModTime: modTime, Data: data}
+		_, err := r.AddGet(prefix+name, h.Handle)
+		return err
+	}
+	if w.SecurityTxt != nil {
+		if len(w.SecurityTxt.Contact) < 1 || w.SecurityTxt.Expires.IsZero() {
+			return fmt.Errorf("security.txt: Contact and Expires are required")
+		}
+		err := add("security.txt", "text/plain; charset=utf-8", []byte(w.SecurityTxt.String()))
+		if err != nil {
+			return err
+		}
+	}
+	if w.ChangePasswordURL != "" {
+		_, err := r.AddGet(prefix+"change-password", func(c *Context) bool {
+			http.Redirect(c.Res, c.Req, w.ChangePasswordURL, http.StatusFound)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(w.AssetLinks) > 0 {
+		data, err := json.Marshal(w.AssetLinks)
+		if err != nil {
+			return err
+		}
+		err = add("assetlinks.json", ContentTypeJSON, data)
+		if err != nil {
+			return err
+		}
+	}
+	if w.AppleAppSiteAssociation != nil {
+		data, err := json.Marshal(w.AppleAppSiteAssociation)
+		if err != nil {
+			return err
+		}
+		err = add("apple-app-site-association", ContentTypeJSON, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}